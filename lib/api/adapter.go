@@ -0,0 +1,129 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/SENERGY-Platform/moses/lib/state"
+	"github.com/julienschmidt/httprouter"
+	"log"
+	"net/http"
+)
+
+func init() {
+	endpoints = append(endpoints, AdapterEndpoints)
+}
+
+func AdapterEndpoints(config config.Config, states *state.StateRepo, router *httprouter.Router) {
+
+	// PUT /world/:id/adapter				//{adapter: "mqtt"}; adapter: "" resets to "platform"
+	router.PUT("/world/:id/adapter", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: PUT /world/:id/adapter GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		msg := state.WorldAdapterRequest{}
+		err = json.NewDecoder(request.Body).Decode(&msg)
+		if err != nil {
+			log.Println("ERROR: PUT /world/:id/adapter Decode", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		msg.World = params.ByName("id")
+		err = states.ValidateAdapter(msg.Adapter)
+		if err != nil {
+			log.Println("ERROR: PUT /world/:id/adapter ValidateAdapter", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		result, access, exists, err := states.UpdateWorldAdapter(jwt, msg)
+		if err != nil {
+			log.Println("ERROR: PUT /world/:id/adapter UpdateWorldAdapter", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown id", http.StatusNotFound)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: PUT /world/:id/adapter Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+
+	// PUT /device/:id/adapter				//{adapter: "mqtt"}; adapter: "" uses the adapter of the world
+	router.PUT("/device/:id/adapter", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: PUT /device/:id/adapter GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		msg := state.DeviceAdapterRequest{}
+		err = json.NewDecoder(request.Body).Decode(&msg)
+		if err != nil {
+			log.Println("ERROR: PUT /device/:id/adapter Decode", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		msg.Device = params.ByName("id")
+		err = states.ValidateAdapter(msg.Adapter)
+		if err != nil {
+			log.Println("ERROR: PUT /device/:id/adapter ValidateAdapter", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		result, access, exists, err := states.UpdateDeviceAdapter(jwt, msg)
+		if err != nil {
+			log.Println("ERROR: PUT /device/:id/adapter UpdateDeviceAdapter", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown id", http.StatusNotFound)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: PUT /device/:id/adapter Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+}
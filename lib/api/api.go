@@ -18,6 +18,8 @@ package api
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/SENERGY-Platform/moses/lib/config"
 	"github.com/SENERGY-Platform/moses/lib/jwt"
 	"github.com/SENERGY-Platform/moses/lib/state"
@@ -25,6 +27,7 @@ import (
 	"net/http"
 	"reflect"
 	"runtime"
+	"strconv"
 	"time"
 
 	"github.com/julienschmidt/httprouter"
@@ -53,6 +56,31 @@ func Start(ctx context.Context, config config.Config, staterepo *state.StateRepo
 	}()
 }
 
+// returns the value of the query parameter 'cascade'; false if not set
+func isCascade(request *http.Request) (bool, error) {
+	cascade := request.URL.Query().Get("cascade")
+	if cascade == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(cascade)
+}
+
+// responds with 502 if some platform devices could not be deleted
+func writeCascadeDeleteResult(resp http.ResponseWriter, result state.CascadeDeleteResult) {
+	b, err := json.Marshal(result)
+	if err != nil {
+		log.Println("ERROR: writeCascadeDeleteResult Marshal", err)
+		http.Error(resp, err.Error(), 500)
+		return
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	if result.Failed > 0 {
+		log.Println("WARNING: unable to delete platform devices", string(b))
+		resp.WriteHeader(http.StatusBadGateway)
+	}
+	fmt.Fprint(resp, string(b))
+}
+
 func isAdmin(jwt jwt.Jwt) bool {
 	for _, role := range jwt.RealmAccess.Roles {
 		if role == "admin" {
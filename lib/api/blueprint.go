@@ -0,0 +1,269 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/SENERGY-Platform/moses/lib/state"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"log"
+	"net/http"
+)
+
+func init() {
+	endpoints = append(endpoints, BlueprintEndpoints)
+}
+
+func BlueprintEndpoints(config config.Config, states *state.StateRepo, router *httprouter.Router) {
+
+	// GET /blueprints
+	router.GET("/blueprints", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: GET /blueprints GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		result, err := states.ReadBlueprints(jwt)
+		if err != nil {
+			log.Println("ERROR: GET /blueprints ReadBlueprints", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: GET /blueprints Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+
+	// POST /blueprint		//{name: "", description: "", external_type_id: "", states: {}, change_routines: [{interval: 0, code: ""}], services: [{name: "", external_ref: "", sensor_interval: 0, code: ""}]}
+	router.POST("/blueprint", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: POST /blueprint GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		msg := state.CreateBlueprintRequest{}
+		err = json.NewDecoder(request.Body).Decode(&msg)
+		if err != nil {
+			log.Println("ERROR: POST /blueprint Decode", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		result, err := states.CreateBlueprint(jwt, msg)
+		if err != nil {
+			log.Println("ERROR: POST /blueprint CreateBlueprint", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: POST /blueprint Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+
+	// PUT /blueprint		//{id: "", ..., rollout: false, parameter: {<<param_name>>: <<param_value>>}}
+	router.PUT("/blueprint", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: PUT /blueprint GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		msg := state.UpdateBlueprintRequest{}
+		err = json.NewDecoder(request.Body).Decode(&msg)
+		if err != nil {
+			log.Println("ERROR: PUT /blueprint Decode", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		result, access, exists, err := states.UpdateBlueprint(jwt, msg)
+		if err != nil {
+			log.Println("ERROR: PUT /blueprint UpdateBlueprint", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown id", http.StatusNotFound)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: PUT /blueprint Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+
+	// GET /blueprint/:id
+	router.GET("/blueprint/:id", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: GET /blueprint/:id GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		result, access, exists, err := states.ReadBlueprint(jwt, params.ByName("id"))
+		if err != nil {
+			log.Println("ERROR: GET /blueprint/:id ReadBlueprint", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown id", http.StatusNotFound)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: GET /blueprint/:id Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+
+	// DELETE /blueprint/:id
+	router.DELETE("/blueprint/:id", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: DELETE /blueprint/:id GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		access, exists, err := states.DeleteBlueprint(jwt, params.ByName("id"))
+		if err != nil {
+			log.Println("ERROR: DELETE /blueprint/:id DeleteBlueprint", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown id", http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(resp, "ok")
+	})
+
+	// POST /blueprint/:id/rollout		//optional {parameter: {<<param_name>>: <<param_value>>}} for parameters added after device creation; applies the blueprint to all devices created from it; returns ids of updated devices
+	router.POST("/blueprint/:id/rollout", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: POST /blueprint/:id/rollout GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		msg := state.RolloutBlueprintRequest{}
+		err = json.NewDecoder(request.Body).Decode(&msg)
+		if err != nil && err != io.EOF {
+			log.Println("ERROR: POST /blueprint/:id/rollout Decode", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		result, access, exists, err := states.RolloutBlueprint(jwt, params.ByName("id"), msg)
+		if err != nil {
+			log.Println("ERROR: POST /blueprint/:id/rollout RolloutBlueprint", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown id", http.StatusNotFound)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: POST /blueprint/:id/rollout Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+
+	// POST /device/byblueprint		//{blueprint: "", room: "", name: "", external_ref: "", parameter: {<<param_name>>: <<param_value>>}}
+	router.POST("/device/byblueprint", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: POST /device/byblueprint GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		msg := state.CreateDeviceByBlueprintRequest{}
+		err = json.NewDecoder(request.Body).Decode(&msg)
+		if err != nil {
+			log.Println("ERROR: POST /device/byblueprint Decode", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		result, access, exists, err := states.CreateDeviceByBlueprint(jwt, msg)
+		if err != nil {
+			log.Println("ERROR: POST /device/byblueprint CreateDeviceByBlueprint", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown id", http.StatusNotFound)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: POST /device/byblueprint Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+}
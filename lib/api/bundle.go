@@ -0,0 +1,129 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/SENERGY-Platform/moses/lib/state"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+func init() {
+	endpoints = append(endpoints, BundleEndpoints)
+}
+
+// returns the bundle format requested by the query parameter 'format' or by the content type of the request
+func getBundleFormat(request *http.Request) string {
+	format := request.URL.Query().Get("format")
+	if format == "" && strings.Contains(request.Header.Get("Content-Type"), "yaml") {
+		format = state.BundleFormatYaml
+	}
+	return format
+}
+
+func BundleEndpoints(config config.Config, states *state.StateRepo, router *httprouter.Router) {
+
+	// GET /world/:id/export?format=json|yaml		//world with rooms, devices, services, routines, referenced templates and graphs
+	router.GET("/world/:id/export", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: GET /world/:id/export GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		result, access, exists, err := states.ExportWorld(jwt, params.ByName("id"))
+		if err != nil {
+			log.Println("ERROR: GET /world/:id/export ExportWorld", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown id", http.StatusNotFound)
+			return
+		}
+		format := getBundleFormat(request)
+		b, err := state.MarshalWorldBundle(result, format)
+		if err != nil {
+			log.Println("ERROR: GET /world/:id/export MarshalWorldBundle", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		if format == state.BundleFormatYaml {
+			resp.Header().Set("Content-Type", "application/yaml")
+		} else {
+			resp.Header().Set("Content-Type", "application/json")
+		}
+		fmt.Fprint(resp, string(b))
+	})
+
+	// POST /world/import?format=json|yaml&name=&external_refs=none|new|keep		//body: bundle of GET /world/:id/export
+	// registered as /world/:id, because httprouter does not allow the static segment next to /world/:id
+	router.POST("/world/:id", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		if params.ByName("id") != "import" {
+			http.NotFound(resp, request)
+			return
+		}
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: POST /world/import GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		data, err := io.ReadAll(request.Body)
+		if err != nil {
+			log.Println("ERROR: POST /world/import ReadAll", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		bundle, err := state.UnmarshalWorldBundle(data, getBundleFormat(request))
+		if err != nil {
+			log.Println("ERROR: POST /world/import UnmarshalWorldBundle", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		msg := state.ImportWorldRequest{
+			Name:         request.URL.Query().Get("name"),
+			ExternalRefs: request.URL.Query().Get("external_refs"),
+		}
+		result, err := states.ImportWorld(jwt, bundle, msg)
+		if err != nil {
+			log.Println("ERROR: POST /world/import ImportWorld", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: POST /world/import Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+}
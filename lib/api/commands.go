@@ -0,0 +1,103 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/SENERGY-Platform/moses/lib/state"
+	"github.com/julienschmidt/httprouter"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func init() {
+	endpoints = append(endpoints, CommandHistoryEndpoints)
+}
+
+// reads the optional query parameters 'from' and 'to' as RFC3339 times
+func getTimeRange(request *http.Request) (from time.Time, to time.Time, err error) {
+	if value := request.URL.Query().Get("from"); value != "" {
+		from, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return from, to, err
+		}
+	}
+	if value := request.URL.Query().Get("to"); value != "" {
+		to, err = time.Parse(time.RFC3339, value)
+	}
+	return from, to, err
+}
+
+func getCommandHistoryQuery(request *http.Request, deviceId string) (result state.CommandHistoryQuery, err error) {
+	result.Device = deviceId
+	result.Service = request.URL.Query().Get("service")
+	result.From, result.To, err = getTimeRange(request)
+	if err != nil {
+		return result, err
+	}
+	if limit := request.URL.Query().Get("limit"); limit != "" {
+		result.Limit, err = strconv.Atoi(limit)
+	}
+	return result, err
+}
+
+func CommandHistoryEndpoints(config config.Config, states *state.StateRepo, router *httprouter.Router) {
+
+	// GET /device/:id/commands?service=&from=&to=&limit=		//from and to as RFC3339; newest first
+	router.GET("/device/:id/commands", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: GET /device/:id/commands GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		query, err := getCommandHistoryQuery(request, params.ByName("id"))
+		if err != nil {
+			log.Println("ERROR: GET /device/:id/commands getCommandHistoryQuery", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		result, access, exists, err := states.ReadCommandHistory(jwt, query)
+		if err != nil {
+			log.Println("ERROR: GET /device/:id/commands ReadCommandHistory", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown id", http.StatusNotFound)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: GET /device/:id/commands Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+}
@@ -72,6 +72,86 @@ func DeviceEndpoints(config config.Config, states *state.StateRepo, router *http
 		}
 	})
 
+	// POST /device/bulk		//{device_type_id: "", count: 100, rooms: [""], name_pattern: "sensor-{{n}}"}
+	router.POST("/device/bulk", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: POST /device/bulk GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		msg := state.CreateDevicesBulkRequest{}
+		err = json.NewDecoder(request.Body).Decode(&msg)
+		if err != nil {
+			log.Println("ERROR: POST /device/bulk Decode", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		result, access, worldAndRoomExists, err := states.CreateDevicesBulk(jwt, msg)
+		if err != nil {
+			log.Println("ERROR: POST /device/bulk CreateDevicesBulk", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !worldAndRoomExists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown world or room id", http.StatusNotFound)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: POST /device/bulk Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+
+	// POST /device/adopt		//{external_id: "", room: "", name: ""}; links an existing platform device
+	router.POST("/device/adopt", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: POST /device/adopt GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		msg := state.AdoptDeviceRequest{}
+		err = json.NewDecoder(request.Body).Decode(&msg)
+		if err != nil {
+			log.Println("ERROR: POST /device/adopt Decode", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		result, access, exists, err := states.AdoptDevice(jwt, msg)
+		if err != nil {
+			log.Println("ERROR: POST /device/adopt AdoptDevice", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown room or platform device id", http.StatusNotFound)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: POST /device/adopt Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+
 	// PUT /device
 	router.PUT("/device", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
 		jwt, err := jwt.GetJwt(request)
@@ -186,7 +266,48 @@ func DeviceEndpoints(config config.Config, states *state.StateRepo, router *http
 		}
 	})
 
-	// DELETE /device/:wid
+	// PUT /device/:id/connection		//{online: true}
+	router.PUT("/device/:id/connection", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: PUT /device/:id/connection GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		msg := state.DeviceConnectionRequest{}
+		err = json.NewDecoder(request.Body).Decode(&msg)
+		if err != nil {
+			log.Println("ERROR: PUT /device/:id/connection Decode", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		msg.Device = params.ByName("id")
+		result, access, exists, err := states.SetDeviceOnline(jwt, msg)
+		if err != nil {
+			log.Println("ERROR: PUT /device/:id/connection SetDeviceOnline", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown id", http.StatusNotFound)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: PUT /device/:id/connection Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+
+	// DELETE /device/:wid?cascade=true		//cascade reports failures of the platform device deletion and keeps the device on failure
 	router.DELETE("/device/:id", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
 		jwt, err := jwt.GetJwt(request)
 		if err != nil {
@@ -195,6 +316,32 @@ func DeviceEndpoints(config config.Config, states *state.StateRepo, router *http
 			return
 		}
 		id := params.ByName("id")
+		cascade, err := isCascade(request)
+		if err != nil {
+			log.Println("ERROR: DELETE /device/:id isCascade", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		if cascade {
+			result, access, exists, err := states.DeleteDeviceCascade(jwt, id)
+			if err != nil {
+				log.Println("ERROR: DELETE /device/:id DeleteDeviceCascade", err)
+				http.Error(resp, err.Error(), 500)
+				return
+			}
+			if !access {
+				log.Println("WARNING: user access denied")
+				http.Error(resp, "access denied", http.StatusUnauthorized)
+				return
+			}
+			if !exists {
+				log.Println("WARNING: 404")
+				http.Error(resp, "unknown id", http.StatusNotFound)
+				return
+			}
+			writeCascadeDeleteResult(resp, result)
+			return
+		}
 		_, access, exists, err := states.DeleteDevice(jwt, id)
 		if err != nil {
 			log.Println("ERROR: DELETE /device/:id DeleteDevice", err)
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/SENERGY-Platform/moses/lib/state"
+	"github.com/julienschmidt/httprouter"
+	"log"
+	"net/http"
+)
+
+func init() {
+	endpoints = append(endpoints, FaultEndpoints)
+}
+
+func FaultEndpoints(config config.Config, states *state.StateRepo, router *httprouter.Router) {
+
+	// PUT /device/:id/faults				//{faults: {noise_std_dev: 0, drift_per_hour: 0, stuck_at: null, drop_rate: 0, duplicate_rate: 0, spike_rate: 0, spike_magnitude: 0, outages: []}, services: {<<service_id>>: {...}}}
+	router.PUT("/device/:id/faults", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: PUT /device/:id/faults GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		msg := state.DeviceFaultsRequest{}
+		err = json.NewDecoder(request.Body).Decode(&msg)
+		if err != nil {
+			log.Println("ERROR: PUT /device/:id/faults Decode", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		msg.Device = params.ByName("id")
+		result, access, exists, err := states.UpdateDeviceFaults(jwt, msg)
+		if err != nil {
+			log.Println("ERROR: PUT /device/:id/faults UpdateDeviceFaults", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown id", http.StatusNotFound)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: PUT /device/:id/faults Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+
+	// DELETE /device/:id/faults			//removes device and service fault profiles
+	router.DELETE("/device/:id/faults", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: DELETE /device/:id/faults GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		_, access, exists, err := states.UpdateDeviceFaults(jwt, state.DeviceFaultsRequest{Device: params.ByName("id")})
+		if err != nil {
+			log.Println("ERROR: DELETE /device/:id/faults UpdateDeviceFaults", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown id", http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(resp, "ok")
+	})
+}
@@ -0,0 +1,196 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/SENERGY-Platform/moses/lib/state"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"github.com/julienschmidt/httprouter"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func init() {
+	endpoints = append(endpoints, LocalEndpoints)
+}
+
+// endpoints of the standalone mode; respond with 404 in platform mode
+func LocalEndpoints(config config.Config, states *state.StateRepo, router *httprouter.Router) {
+
+	// POST /local/command/:device/:service?timeout=10000		//body is the command message; :device and :service are the external refs; responds with the first command response
+	router.POST("/local/command/:device/:service", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		_, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: POST /local/command/:device/:service GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		connector, ok := states.Connector.(*state.LocalConnector)
+		if !ok {
+			http.Error(resp, "only available in standalone mode", http.StatusNotFound)
+			return
+		}
+		var timeout time.Duration
+		if timeoutStr := request.URL.Query().Get("timeout"); timeoutStr != "" {
+			ms, err := strconv.ParseInt(timeoutStr, 10, 64)
+			if err != nil {
+				log.Println("ERROR: POST /local/command/:device/:service ParseInt", err)
+				http.Error(resp, err.Error(), 400)
+				return
+			}
+			timeout = time.Duration(ms) * time.Millisecond
+		}
+		var msg interface{}
+		err = json.NewDecoder(request.Body).Decode(&msg)
+		if err != nil {
+			log.Println("ERROR: POST /local/command/:device/:service Decode", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		result, exists, err := connector.SendCommand(params.ByName("device"), params.ByName("service"), msg, timeout)
+		if err != nil {
+			log.Println("ERROR: POST /local/command/:device/:service SendCommand", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown id", http.StatusNotFound)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: POST /local/command/:device/:service Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+
+	// GET /local/devices		//devices of the local registry
+	router.GET("/local/devices", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		_, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: GET /local/devices GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		connector, ok := states.Connector.(*state.LocalConnector)
+		if !ok {
+			http.Error(resp, "only available in standalone mode", http.StatusNotFound)
+			return
+		}
+		b, err := json.Marshal(connector.ListDevices())
+		if err != nil {
+			log.Println("ERROR: GET /local/devices Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+
+	// GET /local/hubs		//hubs of the local registry
+	router.GET("/local/hubs", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		_, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: GET /local/hubs GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		connector, ok := states.Connector.(*state.LocalConnector)
+		if !ok {
+			http.Error(resp, "only available in standalone mode", http.StatusNotFound)
+			return
+		}
+		b, err := json.Marshal(connector.ListHubs())
+		if err != nil {
+			log.Println("ERROR: GET /local/hubs Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+
+	// GET /local/device-types		//device types of the local registry
+	router.GET("/local/device-types", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: GET /local/device-types GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		connector, ok := states.Connector.(*state.LocalConnector)
+		if !ok {
+			http.Error(resp, "only available in standalone mode", http.StatusNotFound)
+			return
+		}
+		result, err := connector.ListDeviceTypes(jwt.Impersonate)
+		if err != nil {
+			log.Println("ERROR: GET /local/device-types ListDeviceTypes", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: GET /local/device-types Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+
+	// PUT /local/device-types		//creates or replaces a device type of the local registry; missing ids are generated
+	router.PUT("/local/device-types", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		_, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: PUT /local/device-types GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		connector, ok := states.Connector.(*state.LocalConnector)
+		if !ok {
+			http.Error(resp, "only available in standalone mode", http.StatusNotFound)
+			return
+		}
+		msg := model.DeviceType{}
+		err = json.NewDecoder(request.Body).Decode(&msg)
+		if err != nil {
+			log.Println("ERROR: PUT /local/device-types Decode", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		result, err := connector.SetDeviceType(msg)
+		if err != nil {
+			log.Println("ERROR: PUT /local/device-types SetDeviceType", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: PUT /local/device-types Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/SENERGY-Platform/moses/lib/state"
+	"github.com/julienschmidt/httprouter"
+	"log"
+	"net/http"
+)
+
+func init() {
+	endpoints = append(endpoints, MeteringEndpoints)
+}
+
+func MeteringEndpoints(config config.Config, states *state.StateRepo, router *httprouter.Router) {
+
+	// PUT /device/:id/power				//{power: {on_state: "on", standby_watts: 0, on_watts: 0, level_state: "", level_max: 100}}; power: null disables metering
+	router.PUT("/device/:id/power", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: PUT /device/:id/power GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		msg := state.DevicePowerRequest{}
+		err = json.NewDecoder(request.Body).Decode(&msg)
+		if err != nil {
+			log.Println("ERROR: PUT /device/:id/power Decode", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		msg.Device = params.ByName("id")
+		err = state.ValidatePowerModel(msg.Power)
+		if err != nil {
+			log.Println("ERROR: PUT /device/:id/power ValidatePowerModel", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		result, access, exists, err := states.UpdateDevicePower(jwt, msg)
+		if err != nil {
+			log.Println("ERROR: PUT /device/:id/power UpdateDevicePower", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown id", http.StatusNotFound)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: PUT /device/:id/power Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+}
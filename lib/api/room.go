@@ -146,7 +146,7 @@ func RoomEndpoints(config config.Config, states *state.StateRepo, router *httpro
 		}
 	})
 
-	// DELETE /room/:wid
+	// DELETE /room/:wid?cascade=true		//cascade deletes the platform devices of all devices of the room
 	router.DELETE("/room/:id", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
 		jwt, err := jwt.GetJwt(request)
 		if err != nil {
@@ -155,6 +155,32 @@ func RoomEndpoints(config config.Config, states *state.StateRepo, router *httpro
 			return
 		}
 		id := params.ByName("id")
+		cascade, err := isCascade(request)
+		if err != nil {
+			log.Println("ERROR: DELETE /room/:id isCascade", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		if cascade {
+			result, access, exists, err := states.DeleteRoomCascade(jwt, id)
+			if err != nil {
+				log.Println("ERROR: DELETE /room/:id DeleteRoomCascade", err)
+				http.Error(resp, err.Error(), 500)
+				return
+			}
+			if !access {
+				log.Println("WARNING: user access denied")
+				http.Error(resp, "access denied", http.StatusUnauthorized)
+				return
+			}
+			if !exists {
+				log.Println("WARNING: 404")
+				http.Error(resp, "unknown id", http.StatusNotFound)
+				return
+			}
+			writeCascadeDeleteResult(resp, result)
+			return
+		}
 		_, access, exists, err := states.DeleteRoom(jwt, id)
 		if err != nil {
 			log.Println("ERROR: DELETE /room/:id DeleteRoom", err)
@@ -0,0 +1,143 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/SENERGY-Platform/moses/lib/state"
+	"github.com/julienschmidt/httprouter"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func init() {
+	endpoints = append(endpoints, SensorEventEndpoints)
+}
+
+// returns the value of the query parameter 'stream'; false if not set
+func isStream(request *http.Request) (bool, error) {
+	stream := request.URL.Query().Get("stream")
+	if stream == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(stream)
+}
+
+func SensorEventEndpoints(config config.Config, states *state.StateRepo, router *httprouter.Router) {
+
+	// GET /service/:id/events				//newest first
+	// GET /service/:id/events?stream=true	//server-sent events of all following sensor events
+	router.GET("/service/:id/events", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: GET /service/:id/events GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		stream, err := isStream(request)
+		if err != nil {
+			log.Println("ERROR: GET /service/:id/events isStream", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		if stream {
+			streamSensorEvents(resp, request, states, jwt, params.ByName("id"))
+			return
+		}
+		result, access, exists, err := states.ReadSensorEvents(jwt, params.ByName("id"))
+		if err != nil {
+			log.Println("ERROR: GET /service/:id/events ReadSensorEvents", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown id", http.StatusNotFound)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: GET /service/:id/events Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+}
+
+// writes sensor events as server-sent events until the request is canceled
+func streamSensorEvents(resp http.ResponseWriter, request *http.Request, states *state.StateRepo, jwt jwt.Jwt, serviceId string) {
+	events, unsubscribe, access, exists, err := states.SubscribeSensorEvents(jwt, serviceId)
+	if err != nil {
+		log.Println("ERROR: GET /service/:id/events SubscribeSensorEvents", err)
+		http.Error(resp, err.Error(), 500)
+		return
+	}
+	if !access {
+		log.Println("WARNING: user access denied")
+		http.Error(resp, "access denied", http.StatusUnauthorized)
+		return
+	}
+	if !exists {
+		log.Println("WARNING: 404")
+		http.Error(resp, "unknown id", http.StatusNotFound)
+		return
+	}
+	defer unsubscribe()
+	controller := http.NewResponseController(resp)
+	err = controller.SetWriteDeadline(time.Time{}) //disables the write timeout of the server for this stream
+	if err != nil {
+		log.Println("WARNING: GET /service/:id/events SetWriteDeadline", err)
+	}
+	resp.Header().Set("Content-Type", "text/event-stream")
+	resp.Header().Set("Cache-Control", "no-cache")
+	resp.WriteHeader(http.StatusOK)
+	err = controller.Flush()
+	if err != nil {
+		log.Println("ERROR: GET /service/:id/events Flush", err)
+		return
+	}
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case event := <-events:
+			b, err := json.Marshal(event)
+			if err != nil {
+				log.Println("ERROR: GET /service/:id/events Marshal", err)
+				return
+			}
+			_, err = fmt.Fprintf(resp, "data: %s\n\n", b)
+			if err == nil {
+				err = controller.Flush()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/SENERGY-Platform/moses/lib/state"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+func init() {
+	endpoints = append(endpoints, SimulationEndpoints)
+}
+
+func SimulationEndpoints(config config.Config, states *state.StateRepo, router *httprouter.Router) {
+
+	// POST /world/:id/simulation		//{start: "", duration: 0, sample_interval: 0, format: "jsonl|csv"}; streams captured sensor data and sampled states of a simulated copy of the world
+	router.POST("/world/:id/simulation", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: POST /world/:id/simulation GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		msg := state.SimulationRequest{}
+		err = json.NewDecoder(request.Body).Decode(&msg)
+		if err != nil {
+			log.Println("ERROR: POST /world/:id/simulation Decode", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		err = state.ValidateSimulationRequest(msg)
+		if err != nil {
+			log.Println("ERROR: POST /world/:id/simulation ValidateSimulationRequest", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		if msg.Format == state.SimulationFormatCsv {
+			resp.Header().Set("Content-Type", "text/csv")
+		} else {
+			resp.Header().Set("Content-Type", "application/x-ndjson")
+		}
+		//simulations may take longer than the configured write timeout of the server
+		err = http.NewResponseController(resp).SetWriteDeadline(time.Time{})
+		if err != nil {
+			log.Println("WARNING: POST /world/:id/simulation SetWriteDeadline", err)
+		}
+		out := &simulationResponseWriter{writer: resp}
+		access, exists, err := states.SimulateWorld(request.Context(), jwt, params.ByName("id"), msg, out)
+		if err != nil {
+			log.Println("ERROR: POST /world/:id/simulation SimulateWorld", err)
+			//the status is already sent if records were streamed
+			if !out.written {
+				http.Error(resp, err.Error(), 500)
+			}
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown id", http.StatusNotFound)
+			return
+		}
+	})
+}
+
+// remembers if the streaming of the response started
+type simulationResponseWriter struct {
+	writer  io.Writer
+	written bool
+}
+
+func (this *simulationResponseWriter) Write(p []byte) (n int, err error) {
+	this.written = true
+	return this.writer.Write(p)
+}
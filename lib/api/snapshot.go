@@ -0,0 +1,234 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/SENERGY-Platform/moses/lib/state"
+	"github.com/julienschmidt/httprouter"
+	"log"
+	"net/http"
+)
+
+func init() {
+	endpoints = append(endpoints, SnapshotEndpoints)
+}
+
+func SnapshotEndpoints(config config.Config, states *state.StateRepo, router *httprouter.Router) {
+
+	// POST /world/:id/snapshots			//{name: ""}; stores a copy of the current world
+	router.POST("/world/:id/snapshots", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: POST /world/:id/snapshots GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		msg := state.CreateSnapshotRequest{}
+		err = json.NewDecoder(request.Body).Decode(&msg)
+		if err != nil {
+			log.Println("ERROR: POST /world/:id/snapshots Decode", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		result, access, exists, err := states.CreateSnapshot(jwt, params.ByName("id"), msg)
+		if err != nil {
+			log.Println("ERROR: POST /world/:id/snapshots CreateSnapshot", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown id", http.StatusNotFound)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: POST /world/:id/snapshots Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+
+	// GET /world/:id/snapshots				//list of snapshots without data
+	router.GET("/world/:id/snapshots", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: GET /world/:id/snapshots GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		result, access, exists, err := states.ReadSnapshots(jwt, params.ByName("id"))
+		if err != nil {
+			log.Println("ERROR: GET /world/:id/snapshots ReadSnapshots", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown id", http.StatusNotFound)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: GET /world/:id/snapshots Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+
+	// GET /world/:id/snapshots/:snapshot
+	router.GET("/world/:id/snapshots/:snapshot", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: GET /world/:id/snapshots/:snapshot GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		result, access, exists, err := states.ReadSnapshot(jwt, params.ByName("id"), params.ByName("snapshot"))
+		if err != nil {
+			log.Println("ERROR: GET /world/:id/snapshots/:snapshot ReadSnapshot", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown id", http.StatusNotFound)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: GET /world/:id/snapshots/:snapshot Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+
+	// GET /world/:id/snapshots/:snapshot/diff?to=<snapshot-id>	//compares with the current world if "to" is missing
+	router.GET("/world/:id/snapshots/:snapshot/diff", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: GET /world/:id/snapshots/:snapshot/diff GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		result, access, exists, err := states.DiffSnapshot(jwt, params.ByName("id"), params.ByName("snapshot"), request.URL.Query().Get("to"))
+		if err != nil {
+			log.Println("ERROR: GET /world/:id/snapshots/:snapshot/diff DiffSnapshot", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown id", http.StatusNotFound)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: GET /world/:id/snapshots/:snapshot/diff Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+
+	// POST /world/:id/snapshots/:snapshot/restore	//replaces the current world with the snapshot
+	router.POST("/world/:id/snapshots/:snapshot/restore", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: POST /world/:id/snapshots/:snapshot/restore GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		result, access, exists, err := states.RestoreSnapshot(jwt, params.ByName("id"), params.ByName("snapshot"))
+		if err != nil {
+			log.Println("ERROR: POST /world/:id/snapshots/:snapshot/restore RestoreSnapshot", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown id", http.StatusNotFound)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: POST /world/:id/snapshots/:snapshot/restore Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
+
+	// DELETE /world/:id/snapshots/:snapshot
+	router.DELETE("/world/:id/snapshots/:snapshot", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: DELETE /world/:id/snapshots/:snapshot GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		access, exists, err := states.DeleteSnapshot(jwt, params.ByName("id"), params.ByName("snapshot"))
+		if err != nil {
+			log.Println("ERROR: DELETE /world/:id/snapshots/:snapshot DeleteSnapshot", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown id", http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(resp, "ok")
+	})
+}
@@ -168,7 +168,7 @@ func WorldEndpoints(config config.Config, states *state.StateRepo, router *httpr
 		}
 	})
 
-	// DELETE /world/:wid
+	// DELETE /world/:wid?cascade=true		//cascade deletes the platform devices of all devices of the world
 	router.DELETE("/world/:id", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
 		jwt, err := jwt.GetJwt(request)
 		if err != nil {
@@ -177,6 +177,32 @@ func WorldEndpoints(config config.Config, states *state.StateRepo, router *httpr
 			return
 		}
 		id := params.ByName("id")
+		cascade, err := isCascade(request)
+		if err != nil {
+			log.Println("ERROR: DELETE /world/:id isCascade", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		if cascade {
+			result, access, exists, err := states.DeleteWorldCascade(jwt, id)
+			if err != nil {
+				log.Println("ERROR: DELETE /world/:id DeleteWorldCascade", err)
+				http.Error(resp, err.Error(), 500)
+				return
+			}
+			if !access {
+				log.Println("WARNING: user access denied")
+				http.Error(resp, "access denied", http.StatusUnauthorized)
+				return
+			}
+			if !exists {
+				log.Println("WARNING: 404")
+				http.Error(resp, "unknown id", http.StatusNotFound)
+				return
+			}
+			writeCascadeDeleteResult(resp, result)
+			return
+		}
 		access, exists, err := states.DeleteWorld(jwt, id)
 		if err != nil {
 			log.Println("ERROR: DELETE /world/:id DeleteWorld", err)
@@ -195,4 +221,44 @@ func WorldEndpoints(config config.Config, states *state.StateRepo, router *httpr
 		}
 		fmt.Fprint(resp, "ok")
 	})
+
+	// POST /world/:wid/clone		//{name: "", external_refs: "none|new|keep"}
+	router.POST("/world/:id/clone", func(resp http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		jwt, err := jwt.GetJwt(request)
+		if err != nil {
+			log.Println("ERROR: POST /world/:id/clone GetJwt", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		msg := state.CloneWorldRequest{}
+		err = json.NewDecoder(request.Body).Decode(&msg)
+		if err != nil {
+			log.Println("ERROR: POST /world/:id/clone Decode", err)
+			http.Error(resp, err.Error(), 400)
+			return
+		}
+		result, access, exists, err := states.CloneWorld(jwt, params.ByName("id"), msg)
+		if err != nil {
+			log.Println("ERROR: POST /world/:id/clone CloneWorld", err)
+			http.Error(resp, err.Error(), 500)
+			return
+		}
+		if !access {
+			log.Println("WARNING: user access denied")
+			http.Error(resp, "access denied", http.StatusUnauthorized)
+			return
+		}
+		if !exists {
+			log.Println("WARNING: 404")
+			http.Error(resp, "unknown id", http.StatusNotFound)
+			return
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.Println("ERROR: POST /world/:id/clone Marshal", err)
+			http.Error(resp, err.Error(), 500)
+		} else {
+			fmt.Fprint(resp, string(b))
+		}
+	})
 }
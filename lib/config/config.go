@@ -31,15 +31,31 @@ import (
 )
 
 type Config struct {
-	ServerPort             string        `json:"server_port"`
-	LogLevel               string        `json:"log_level"`
-	WorldCollectionName    string        `json:"world_collection_name"`
-	GraphCollectionName    string        `json:"graph_collection_name"`
-	TemplateCollectionName string        `json:"template_collection_name"`
-	MongoUrl               string        `json:"mongo_url" config:"secret"`
-	MongoTable             string        `json:"mongo_table"`
-	JsTimeout              time.Duration `json:"js_timeout"`
-	ProtocolSegmentName    string        `json:"protocol_segment_name"`
+	Mode                    string        `json:"mode"` // "platform" || "standalone"; standalone runs without kafka, keycloak and the device manager
+	ServerPort              string        `json:"server_port"`
+	LogLevel                string        `json:"log_level"`
+	WorldCollectionName     string        `json:"world_collection_name"`
+	GraphCollectionName     string        `json:"graph_collection_name"`
+	TemplateCollectionName  string        `json:"template_collection_name"`
+	ScenarioCollectionName  string        `json:"scenario_collection_name"`
+	SnapshotCollectionName  string        `json:"snapshot_collection_name"`
+	BlueprintCollectionName string        `json:"blueprint_collection_name"`
+	CommandCollectionName   string        `json:"command_collection_name"`
+	StateCollectionName     string        `json:"state_collection_name"` //states of worlds, rooms and devices; stored separately from the world documents
+	MongoUrl                string        `json:"mongo_url" config:"secret"`
+	MongoTable              string        `json:"mongo_table"`
+	Persistence             string        `json:"persistence"` // "mongodb" || "bolt"; "" -> "mongodb"
+	BoltFile                string        `json:"bolt_file"`   //database file of the bolt persistence
+	JsTimeout               time.Duration `json:"js_timeout"`
+	MeteringInterval        int64         `json:"metering_interval"`         //seconds between updates of power and energy states
+	EffectInterval          int64         `json:"effect_interval"`           //seconds between evaluations of device effects
+	SyncInterval            int64         `json:"sync_interval"`             //seconds between reconciliations of devices with the platform; disabled if <= 0
+	CommandHistorySize      int64         `json:"command_history_size"`      //number of recorded commands per device
+	SensorEventHistorySize  int64         `json:"sensor_event_history_size"` //number of sensor events kept in memory per service
+	WebhookRetries          int64         `json:"webhook_retries"`           //retries of failed webhook deliveries before the event becomes a dead letter
+	WebhookRetryDelay       int64         `json:"webhook_retry_delay"`       //milliseconds before the first retry of a webhook delivery; doubled for every further retry
+	ProtocolSegmentName     string        `json:"protocol_segment_name"`     //default segment of service messages
+	ProtocolSegments        []string      `json:"protocol_segments"`         //all segments of the protocol; protocol_segment_name is added if missing
 
 	KafkaUrl           string `json:"kafka_url"`
 	KafkaResponseTopic string `json:"kafka_response_topic"`
@@ -100,6 +116,18 @@ type Config struct {
 
 	NotificationUrl string `json:"notification_url"`
 
+	MqttBrokerUrl     string `json:"mqtt_broker_url"` //e.g. "tcp://localhost:1883"; the mqtt adapter is disabled if empty
+	MqttClientId      string `json:"mqtt_client_id"`
+	MqttUser          string `json:"mqtt_user" config:"secret"`
+	MqttPw            string `json:"mqtt_pw" config:"secret"`
+	MqttQos           int64  `json:"mqtt_qos"`
+	MqttSensorTopic   string `json:"mqtt_sensor_topic"`   //mustache template; defaults to "moses/{{device}}/{{service}}/event"
+	MqttCommandTopic  string `json:"mqtt_command_topic"`  //mustache template; defaults to "moses/{{device}}/{{service}}/cmd"
+	MqttResponseTopic string `json:"mqtt_response_topic"` //mustache template; defaults to "moses/{{device}}/{{service}}/resp"
+
+	LocalRegistryFile string `json:"local_registry_file"` //json file of devices, device types and characteristics in standalone mode; in memory if empty
+	LocalEventFile    string `json:"local_event_file"`    //sensor events are appended as json lines in standalone mode; logged if empty
+
 	KafkaTopicConfigs map[string][]kafka.ConfigEntry `json:"kafka_topic_configs"`
 }
 
@@ -141,6 +141,46 @@ func (this JwtImpersonate) PostJSON(url string, body interface{}, result interfa
 	return
 }
 
+func (this JwtImpersonate) Put(url string, contentType string, body io.Reader) (resp *http.Response, err error) {
+	req, err := http.NewRequest("PUT", url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", string(this))
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err = http.DefaultClient.Do(req)
+
+	if err == nil && resp.StatusCode == 401 {
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(resp.Body)
+		resp.Body.Close()
+		log.Println(buf.String())
+		err = errors.New("access denied")
+	}
+	if err == nil && (resp.StatusCode != 200) {
+		err = errors.New("unexpected statuscode in response for PUT " + url)
+	}
+	return
+}
+
+func (this JwtImpersonate) PutJSON(url string, body interface{}, result interface{}) (err error) {
+	b := new(bytes.Buffer)
+	err = json.NewEncoder(b).Encode(body)
+	if err != nil {
+		return
+	}
+	resp, err := this.Put(url, "application/json", b)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if result != nil {
+		err = json.NewDecoder(resp.Body).Decode(result)
+	}
+	return
+}
+
 func (this JwtImpersonate) Get(url string) (resp *http.Response, err error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
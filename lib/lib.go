@@ -18,6 +18,7 @@ package lib
 
 import (
 	"context"
+	"errors"
 	"github.com/IBM/sarama"
 	"github.com/SENERGY-Platform/moses/lib/api"
 	"github.com/SENERGY-Platform/moses/lib/config"
@@ -30,13 +31,111 @@ import (
 )
 
 func New(config config.Config, ctx context.Context) (err error) {
+	var connector state.PlatformConnector
+	var logger connectionlog.Logger
+	var platformConnector *platform_connector_lib.Connector
+	if config.Mode == state.ModeStandalone {
+		log.Println("start in standalone mode")
+		connector, err = state.NewLocalConnector(config)
+		if err != nil {
+			log.Println("ERROR: unable to load local registry: ", err)
+			return err
+		}
+		logger = state.LocalConnectionLogger{Debug: config.Debug}
+	} else {
+		platformConnector, logger, err = newPlatformConnector(config, ctx)
+		if err != nil {
+			return err
+		}
+		connector = state.NewSenergyConnector(platformConnector, config)
+	}
 
-	asyncFlushFrequency, err := time.ParseDuration(config.AsyncFlushFrequency)
+	log.Println("connect to database")
+	persistence, err := state.NewPersistence(config, config.Persistence)
+	if err != nil {
+		log.Println("ERROR: unable to connect to database: ", err)
+		return err
+	}
+
+	log.Println("load states from database")
+	staterepo := &state.StateRepo{Persistence: persistence, Config: config, Connector: connector, StateLogger: logger}
+	if config.MqttBrokerUrl != "" {
+		log.Println("connect to mqtt broker")
+		mqttAdapter, err := state.NewMqttAdapter(config)
+		if err != nil {
+			log.Println("ERROR: unable to connect to mqtt broker: ", err)
+			return err
+		}
+		staterepo.Adapters = map[string]state.OutputAdapter{state.AdapterMqtt: mqttAdapter}
+		go func() {
+			<-ctx.Done()
+			mqttAdapter.Close()
+		}()
+	}
+	err = staterepo.Load()
+	if err != nil {
+		log.Println("ERROR: unable to load state repo: ", err)
+		return err
+	}
+
+	log.Println("start state routines")
+	staterepo.Start()
+	staterepo.StartReconciliation(ctx)
+
+	if platformConnector != nil {
+		err = platformConnector.Start(ctx, platform_connector_lib.Sync)
+		if err != nil {
+			log.Println("ERROR: unable to start protocol: ", err)
+			return err
+		}
+	}
+
+	log.Println("start api on port: ", config.ServerPort)
+
+	api.Start(ctx, config, staterepo)
+	go func() {
+		<-ctx.Done()
+		staterepo.Shutdown()
+		persistence.Close()
+	}()
+	return nil
+}
+
+// copies all data from the persistence backend from to the backend to; e.g. "mongodb" -> "bolt"
+func Migrate(config config.Config, from string, to string) (err error) {
+	if from == to {
+		return errors.New("source and target persistence are equal")
+	}
+	source, err := state.NewPersistence(config, from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	target, err := state.NewPersistence(config, to)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+	return state.MigratePersistence(source, target)
+}
+
+// moves the states of all worlds from the world documents to the state collection
+func MigrateStates(config config.Config) (err error) {
+	persistence, err := state.NewPersistence(config, config.Persistence)
 	if err != nil {
 		return err
 	}
+	defer persistence.Close()
+	return state.MigrateWorldStates(persistence)
+}
 
-	connector, err := platform_connector_lib.New(platform_connector_lib.Config{
+func newPlatformConnector(config config.Config, ctx context.Context) (connector *platform_connector_lib.Connector, logger connectionlog.Logger, err error) {
+	asyncFlushFrequency, err := time.ParseDuration(config.AsyncFlushFrequency)
+	if err != nil {
+		return connector, logger, err
+	}
+
+	connector, err = platform_connector_lib.New(platform_connector_lib.Config{
 		PartitionsNum:            config.KafkaPartitionNum,
 		ReplicationFactor:        config.KafkaReplicationFactor,
 		FatalKafkaError:          config.FatalKafkaError,
@@ -97,7 +196,7 @@ func New(config config.Config, ctx context.Context) (err error) {
 	})
 	if err != nil {
 		log.Println("ERROR: lib init", err)
-		return err
+		return connector, logger, err
 	}
 
 	if config.Debug {
@@ -108,53 +207,20 @@ func New(config config.Config, ctx context.Context) (err error) {
 	err = connector.InitProducer(ctx, []platform_connector_lib.Qos{platform_connector_lib.Sync})
 	if err != nil {
 		log.Println("ERROR: producer ", err)
-		return err
+		return connector, logger, err
 	}
 
 	logProducer, err := connector.GetProducer(platform_connector_lib.Sync)
 	if err != nil {
 		log.Println("ERROR: logger ", err)
-		return err
+		return connector, logger, err
 	}
-	logger, err := connectionlog.NewWithProducer(logProducer, config.DeviceLogTopic, config.GatewayLogTopic)
+	logger, err = connectionlog.NewWithProducer(logProducer, config.DeviceLogTopic, config.GatewayLogTopic)
 	if err != nil {
 		log.Println("ERROR: logger ", err)
-		return err
-	}
-
-	log.Println("connect to database")
-	persistence, err := state.NewMongoPersistence(config)
-	if err != nil {
-		log.Println("ERROR: unable to connect to database: ", err)
-		return err
-	}
-
-	log.Println("load states from database")
-	staterepo := &state.StateRepo{Persistence: persistence, Config: config, Connector: connector, StateLogger: logger}
-	err = staterepo.Load()
-	if err != nil {
-		log.Println("ERROR: unable to load state repo: ", err)
-		return err
+		return connector, logger, err
 	}
-
-	log.Println("start state routines")
-	staterepo.Start()
-
-	err = connector.Start(ctx, platform_connector_lib.Sync)
-	if err != nil {
-		log.Println("ERROR: unable to start protocol: ", err)
-		return err
-	}
-
-	log.Println("start api on port: ", config.ServerPort)
-
-	api.Start(ctx, config, staterepo)
-	go func() {
-		<-ctx.Done()
-		staterepo.Stop()
-		persistence.Close()
-	}()
-	return nil
+	return connector, logger, nil
 }
 
 func StringToList(str string) []string {
@@ -0,0 +1,217 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"errors"
+	"log"
+
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+)
+
+// names of output adapters
+const (
+	AdapterPlatform = "platform" //default; sensor data and commands are exchanged with the platform connector
+	AdapterMqtt     = "mqtt"
+)
+
+// OutputAdapter receives the sensor data of devices and is the source of their commands
+type OutputAdapter interface {
+	SendSensorData(target AdapterTarget, value interface{}) error
+	// replaces all command subscriptions of the adapter; handler is called for every command to one of the targets
+	SetCommandTargets(targets []AdapterTarget, handler AdapterCommandHandler) error
+}
+
+type AdapterCommandHandler func(target AdapterTarget, cmdMsg interface{}, responder func(respMsg interface{}))
+
+// AdapterTarget identifies a device service for an OutputAdapter
+type AdapterTarget struct {
+	World       string `json:"world"`
+	Device      string `json:"device"`
+	DeviceRef   string `json:"device_ref"`
+	DeviceName  string `json:"device_name"`
+	Service     string `json:"service"`
+	ServiceRef  string `json:"service_ref"`
+	ServiceName string `json:"service_name"`
+}
+
+type WorldAdapterRequest struct {
+	World   string `json:"world"`
+	Adapter string `json:"adapter"` //empty resets to the default adapter
+}
+
+type DeviceAdapterRequest struct {
+	Device  string `json:"device"`
+	Adapter string `json:"adapter"` //empty uses the adapter of the world
+}
+
+func getAdapterTarget(world *World, device *Device, service Service) AdapterTarget {
+	return AdapterTarget{
+		World:       world.Id,
+		Device:      device.Id,
+		DeviceRef:   device.ExternalRef,
+		DeviceName:  device.Name,
+		Service:     service.Id,
+		ServiceRef:  service.ExternalRef,
+		ServiceName: service.Name,
+	}
+}
+
+// parameter for topic templates
+func (this AdapterTarget) templateParameter() map[string]string {
+	return map[string]string{
+		"world":        this.World,
+		"device":       this.Device,
+		"device_ref":   this.DeviceRef,
+		"device_name":  this.DeviceName,
+		"service":      this.Service,
+		"service_ref":  this.ServiceRef,
+		"service_name": this.ServiceName,
+	}
+}
+
+// the adapter of the device overwrites the adapter of the world
+func getDeviceAdapterName(world *World, device *Device) string {
+	if device.Adapter != "" {
+		return device.Adapter
+	}
+	if world.Adapter != "" {
+		return world.Adapter
+	}
+	return AdapterPlatform
+}
+
+func (this *StateRepo) getAdapter(name string) (adapter OutputAdapter, ok bool) {
+	if name == "" || name == AdapterPlatform {
+		return platformAdapter{repo: this}, true
+	}
+	adapter, ok = this.Adapters[name]
+	return adapter, ok
+}
+
+func (this *StateRepo) ValidateAdapter(name string) error {
+	if _, ok := this.getAdapter(name); !ok {
+		return errors.New("unknown adapter: " + name)
+	}
+	return nil
+}
+
+// sets the command targets of all adapters
+// expects the indexes of the state repo to be up to date
+func (this *StateRepo) startAdapters() {
+	targets := map[string][]AdapterTarget{AdapterPlatform: {}}
+	for name := range this.Adapters {
+		targets[name] = []AdapterTarget{}
+	}
+	for _, world := range this.Worlds {
+		for _, room := range world.Rooms {
+			for _, device := range room.Devices {
+				name := getDeviceAdapterName(world, device)
+				for _, service := range device.Services {
+					targets[name] = append(targets[name], getAdapterTarget(world, device, service))
+				}
+			}
+		}
+	}
+	for name, adapterTargets := range targets {
+		adapter, ok := this.getAdapter(name)
+		if !ok {
+			log.Println("WARNING: unknown adapter", name, "used by", len(adapterTargets), "services")
+			continue
+		}
+		err := adapter.SetCommandTargets(adapterTargets, this.handleAdapterCommand)
+		if err != nil {
+			log.Println("ERROR: unable to set command targets of adapter", name, err)
+		}
+	}
+}
+
+func (this *StateRepo) handleAdapterCommand(target AdapterTarget, cmdMsg interface{}, responder func(respMsg interface{})) {
+	this.mux.RLock()
+	defer this.mux.RUnlock()
+	device, ok := this.serviceDeviceIndex[target.Service]
+	if !ok {
+		log.Println("WARNING: no device for service found ", target.Service)
+		return
+	}
+	world, ok := this.deviceWorldIndex[device.Id]
+	if !ok {
+		log.Println("WARNING: no world for device found ", device.Id)
+		return
+	}
+	room, ok := this.deviceRoomIndex[device.Id]
+	if !ok {
+		log.Println("WARNING: no room for device found ", device.Id)
+		return
+	}
+	this.runCommand(world, room, device, device.Services[target.Service], cmdMsg, responder)
+}
+
+func (this *StateRepo) UpdateWorldAdapter(jwt jwt.Jwt, msg WorldAdapterRequest) (world WorldMsg, access bool, exists bool, err error) {
+	world, access, exists, err = this.ReadWorld(jwt, msg.World)
+	if err != nil || !access || !exists {
+		return
+	}
+	err = this.ValidateAdapter(msg.Adapter)
+	if err != nil {
+		return world, true, true, err
+	}
+	world.Adapter = msg.Adapter
+	err = this.DevUpdateWorld(world)
+	return world, true, true, err
+}
+
+func (this *StateRepo) UpdateDeviceAdapter(jwt jwt.Jwt, msg DeviceAdapterRequest) (device DeviceResponse, access bool, exists bool, err error) {
+	device, access, exists, err = this.ReadDevice(jwt, msg.Device)
+	if err != nil || !access || !exists {
+		return
+	}
+	err = this.ValidateAdapter(msg.Adapter)
+	if err != nil {
+		return device, true, true, err
+	}
+	device.Device.Adapter = msg.Adapter
+	err = this.DevUpdateDevice(device.World, device.Room, device.Device)
+	return device, true, true, err
+}
+
+// exchanges sensor data and commands with the platform connector
+type platformAdapter struct {
+	repo *StateRepo
+}
+
+func (this platformAdapter) SendSensorData(target AdapterTarget, value interface{}) error {
+	if target.DeviceRef == "" {
+		log.Println("WARNING: no external ref for device")
+		return errors.New("no external ref for device")
+	}
+	if target.ServiceRef == "" {
+		log.Println("WARNING: no external ref for service")
+		return errors.New("no external ref for service")
+	}
+	return this.repo.Connector.SendEvent(target.DeviceRef, target.ServiceRef, value)
+}
+
+// commands of the platform are addressed by the external refs of devices and services
+// HandleCommand ignores commands of devices which use another adapter
+func (this platformAdapter) SetCommandTargets(targets []AdapterTarget, handler AdapterCommandHandler) error {
+	if this.repo.Connector == nil {
+		return nil
+	}
+	this.repo.Connector.SetCommandHandler(this.repo.HandleCommand)
+	return nil
+}
@@ -0,0 +1,447 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/globalsign/mgo"
+	"github.com/google/uuid"
+)
+
+// DeviceBlueprint is a reusable device definition; code of change routines and services is a mustache template like RoutineTemplate.Template
+type DeviceBlueprint struct {
+	Id             string                   `json:"id" bson:"id"`
+	Owner          string                   `json:"-" bson:"owner"`
+	Name           string                   `json:"name" bson:"name"`
+	Description    string                   `json:"description" bson:"description"`
+	ExternalTypeId string                   `json:"external_type_id" bson:"external_type_id"`
+	States         map[string]interface{}   `json:"states" bson:"states"`
+	ChangeRoutines []BlueprintChangeRoutine `json:"change_routines" bson:"change_routines"`
+	Services       []BlueprintService       `json:"services" bson:"services"`
+	Parameter      []string                 `json:"parameter" bson:"parameter"` //parameter names used in the code templates
+}
+
+type BlueprintChangeRoutine struct {
+	Interval int64  `json:"interval" bson:"interval"`
+	Code     string `json:"code" bson:"code"`
+}
+
+type BlueprintService struct {
+	Name           string `json:"name" bson:"name"`
+	ExternalRef    string `json:"external_ref" bson:"external_ref"` //platform intern service id; identifies the service of an instance on rollout
+	SensorInterval int64  `json:"sensor_interval" bson:"sensor_interval"`
+	Code           string `json:"code" bson:"code"`
+}
+
+// if ExternalTypeId is set and no services are given, the services are generated from the device type
+type CreateBlueprintRequest struct {
+	Name           string                   `json:"name"`
+	Description    string                   `json:"description"`
+	ExternalTypeId string                   `json:"external_type_id"`
+	States         map[string]interface{}   `json:"states"`
+	ChangeRoutines []BlueprintChangeRoutine `json:"change_routines"`
+	Services       []BlueprintService       `json:"services"`
+}
+
+type UpdateBlueprintRequest struct {
+	Id             string                   `json:"id"`
+	Name           string                   `json:"name"`
+	Description    string                   `json:"description"`
+	ExternalTypeId string                   `json:"external_type_id"`
+	States         map[string]interface{}   `json:"states"`
+	ChangeRoutines []BlueprintChangeRoutine `json:"change_routines"`
+	Services       []BlueprintService       `json:"services"`
+	Rollout        bool                     `json:"rollout"`   //apply the updated blueprint to all devices created from it
+	Parameter      map[string]string        `json:"parameter"` //values of new parameters for devices created from the blueprint; used by the rollout
+}
+
+type RolloutBlueprintRequest struct {
+	Parameter map[string]string `json:"parameter"` //values of new parameters for devices which have no value yet
+}
+
+// a new platform device is created if ExternalRef is empty and the blueprint has an ExternalTypeId
+type CreateDeviceByBlueprintRequest struct {
+	Blueprint   string            `json:"blueprint"`
+	Room        string            `json:"room"`
+	Name        string            `json:"name"`
+	ExternalRef string            `json:"external_ref"`
+	Parameter   map[string]string `json:"parameter"`
+}
+
+func getBlueprintParameterList(blueprint DeviceBlueprint) (result []string, err error) {
+	codes := []string{}
+	for _, routine := range blueprint.ChangeRoutines {
+		codes = append(codes, routine.Code)
+	}
+	for _, service := range blueprint.Services {
+		codes = append(codes, service.Code)
+	}
+	known := map[string]bool{}
+	result = []string{}
+	for _, code := range codes {
+		parameter, err := GetTemplateParameterList(code)
+		if err != nil {
+			return result, err
+		}
+		for _, name := range parameter {
+			if !known[name] {
+				known[name] = true
+				result = append(result, name)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// checks that exactly the parameters used by the blueprint are given
+func validateBlueprintParameter(blueprint DeviceBlueprint, parameter map[string]string) error {
+	known := map[string]bool{}
+	for _, name := range blueprint.Parameter {
+		known[name] = true
+		if _, ok := parameter[name]; !ok {
+			return errors.New("missing blueprint parameter: " + name)
+		}
+	}
+	for name := range parameter {
+		if !known[name] {
+			return errors.New("unknown blueprint parameter: " + name)
+		}
+	}
+	return nil
+}
+
+// returns the parameters of a device for the rollout of the blueprint
+// parameters no longer used by the blueprint are dropped; missing parameters are taken from defaults
+func getRolloutParameter(blueprint DeviceBlueprint, current map[string]string, defaults map[string]string) map[string]string {
+	result := map[string]string{}
+	for _, name := range blueprint.Parameter {
+		if value, ok := current[name]; ok {
+			result[name] = value
+		} else if value, ok := defaults[name]; ok {
+			result[name] = value
+		}
+	}
+	return result
+}
+
+// returns the ids of the change routines created from the blueprint, by blueprint index
+// devices created before the ids were recorded treat all their routines as blueprint routines
+func getBlueprintRoutineIds(device DeviceMsg, blueprint DeviceBlueprint) []string {
+	if device.BlueprintRoutines != nil || device.Blueprint != blueprint.Id {
+		return device.BlueprintRoutines
+	}
+	result := []string{}
+	for id := range device.ChangeRoutines {
+		result = append(result, id)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// applies the blueprint to the device:
+// missing states are added, change routines from the blueprint are updated while keeping their ids and other routines are kept,
+// services are replaced while keeping the ids of services with the same external ref, or with the same name if they have no external ref
+func applyBlueprint(device DeviceMsg, blueprint DeviceBlueprint, parameter map[string]string) (result DeviceMsg, err error) {
+	err = validateBlueprintParameter(blueprint, parameter)
+	if err != nil {
+		return device, err
+	}
+	result = device
+	result.Blueprint = blueprint.Id
+	result.BlueprintParameter = parameter
+	if result.ExternalTypeId == "" {
+		result.ExternalTypeId = blueprint.ExternalTypeId
+	}
+	if result.States == nil {
+		result.States = map[string]interface{}{}
+	}
+	for key, value := range blueprint.States {
+		if _, ok := result.States[key]; !ok {
+			result.States[key] = value
+		}
+	}
+	blueprintRoutineIds := getBlueprintRoutineIds(device, blueprint)
+	fromBlueprint := map[string]bool{}
+	for _, id := range blueprintRoutineIds {
+		fromBlueprint[id] = true
+	}
+	result.ChangeRoutines = map[string]ChangeRoutine{}
+	for id, routine := range device.ChangeRoutines {
+		if !fromBlueprint[id] {
+			result.ChangeRoutines[id] = routine
+		}
+	}
+	result.BlueprintRoutines = []string{}
+	for index, blueprintRoutine := range blueprint.ChangeRoutines {
+		routine := ChangeRoutine{}
+		if index < len(blueprintRoutineIds) {
+			routine = device.ChangeRoutines[blueprintRoutineIds[index]] //keeps Disabled and Template
+			routine.Id = blueprintRoutineIds[index]
+		}
+		if routine.Id == "" {
+			routine.Id = uuid.NewString()
+		}
+		routine.Interval = blueprintRoutine.Interval
+		routine.Code, err = RenderTempl(blueprintRoutine.Code, parameter)
+		if err != nil {
+			return result, err
+		}
+		result.ChangeRoutines[routine.Id] = routine
+		result.BlueprintRoutines = append(result.BlueprintRoutines, routine.Id)
+	}
+	serviceIds := map[string]string{}
+	serviceIdsByName := map[string][]string{}
+	for _, service := range sortedServices(device.Services) {
+		if service.ExternalRef != "" {
+			serviceIds[service.ExternalRef] = service.Id
+		} else {
+			serviceIdsByName[service.Name] = append(serviceIdsByName[service.Name], service.Id)
+		}
+	}
+	result.Services = map[string]Service{}
+	for _, blueprintService := range blueprint.Services {
+		service := Service{
+			Name:           blueprintService.Name,
+			ExternalRef:    blueprintService.ExternalRef,
+			SensorInterval: blueprintService.SensorInterval,
+		}
+		if blueprintService.ExternalRef != "" {
+			service.Id = serviceIds[blueprintService.ExternalRef]
+		} else if ids := serviceIdsByName[blueprintService.Name]; len(ids) > 0 {
+			service.Id = ids[0]
+			serviceIdsByName[blueprintService.Name] = ids[1:]
+		}
+		if service.Id == "" {
+			service.Id = uuid.NewString()
+		}
+		service.Code, err = RenderTempl(blueprintService.Code, parameter)
+		if err != nil {
+			return result, err
+		}
+		result.Services[service.Id] = service
+	}
+	return result, nil
+}
+
+func (this *StateRepo) blueprintServicesFromDeviceType(jwt jwt.Jwt, deviceTypeId string) (result []BlueprintService, states map[string]interface{}, err error) {
+	services, states, err := this.prepareServices(jwt, deviceTypeId)
+	if err != nil {
+		return result, states, err
+	}
+	for _, service := range services {
+		result = append(result, BlueprintService{Name: service.Name, ExternalRef: service.ExternalRef, Code: service.Code})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, states, nil
+}
+
+func (this *StateRepo) CreateBlueprint(jwt jwt.Jwt, msg CreateBlueprintRequest) (result DeviceBlueprint, err error) {
+	result = DeviceBlueprint{
+		Id:             uuid.NewString(),
+		Owner:          jwt.UserId,
+		Name:           msg.Name,
+		Description:    msg.Description,
+		ExternalTypeId: msg.ExternalTypeId,
+		States:         msg.States,
+		ChangeRoutines: msg.ChangeRoutines,
+		Services:       msg.Services,
+	}
+	if result.ExternalTypeId != "" && len(result.Services) == 0 {
+		var states map[string]interface{}
+		result.Services, states, err = this.blueprintServicesFromDeviceType(jwt, result.ExternalTypeId)
+		if err != nil {
+			return result, err
+		}
+		if result.States == nil {
+			result.States = map[string]interface{}{}
+		}
+		for key, value := range states {
+			if _, ok := result.States[key]; !ok {
+				result.States[key] = value
+			}
+		}
+	}
+	result.Parameter, err = getBlueprintParameterList(result)
+	if err != nil {
+		return result, err
+	}
+	err = this.Persistence.PersistBlueprint(result)
+	return result, err
+}
+
+func (this *StateRepo) ReadBlueprint(jwt jwt.Jwt, id string) (result DeviceBlueprint, access bool, exists bool, err error) {
+	result, err = this.Persistence.GetBlueprint(id)
+	if err == mgo.ErrNotFound {
+		return result, false, false, nil
+	}
+	if err != nil {
+		return result, false, false, err
+	}
+	if result.Owner != jwt.UserId {
+		return DeviceBlueprint{}, false, true, nil
+	}
+	return result, true, true, nil
+}
+
+func (this *StateRepo) ReadBlueprints(jwt jwt.Jwt) (result []DeviceBlueprint, err error) {
+	result, err = this.Persistence.GetBlueprints(jwt.UserId)
+	if result == nil {
+		result = []DeviceBlueprint{}
+	}
+	return result, err
+}
+
+func (this *StateRepo) UpdateBlueprint(jwt jwt.Jwt, msg UpdateBlueprintRequest) (result DeviceBlueprint, access bool, exists bool, err error) {
+	result, access, exists, err = this.ReadBlueprint(jwt, msg.Id)
+	if err != nil || !access || !exists {
+		return
+	}
+	result.Name = msg.Name
+	result.Description = msg.Description
+	result.ExternalTypeId = msg.ExternalTypeId
+	result.States = msg.States
+	result.ChangeRoutines = msg.ChangeRoutines
+	result.Services = msg.Services
+	result.Parameter, err = getBlueprintParameterList(result)
+	if err != nil {
+		return result, true, true, err
+	}
+	//the rollout is prepared before the blueprint is persisted, so devices without values for new parameters reject the update
+	var changedWorlds []WorldMsg
+	if msg.Rollout {
+		changedWorlds, _, err = this.getBlueprintRollout(jwt, result, msg.Parameter)
+		if err != nil {
+			return result, true, true, err
+		}
+	}
+	err = this.Persistence.PersistBlueprint(result)
+	if err != nil {
+		return result, true, true, err
+	}
+	if len(changedWorlds) > 0 {
+		err = this.DevUpdateWorlds(changedWorlds)
+	}
+	return result, true, true, err
+}
+
+// devices created from the blueprint keep their current behavior
+func (this *StateRepo) DeleteBlueprint(jwt jwt.Jwt, id string) (access bool, exists bool, err error) {
+	_, access, exists, err = this.ReadBlueprint(jwt, id)
+	if err != nil || !access || !exists {
+		return access, exists, err
+	}
+	err = this.Persistence.DeleteBlueprint(id)
+	return true, true, err
+}
+
+func (this *StateRepo) CreateDeviceByBlueprint(jwt jwt.Jwt, msg CreateDeviceByBlueprintRequest) (result DeviceResponse, access bool, exists bool, err error) {
+	blueprint, access, exists, err := this.ReadBlueprint(jwt, msg.Blueprint)
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	room, access, exists, err := this.ReadRoom(jwt, msg.Room)
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	device := DeviceMsg{Id: uuid.NewString(), Name: msg.Name, ExternalRef: msg.ExternalRef}
+	device, err = applyBlueprint(device, blueprint, msg.Parameter)
+	if err != nil {
+		return result, true, true, err
+	}
+	createdExternalDevices := []string{}
+	if device.ExternalRef == "" && blueprint.ExternalTypeId != "" {
+		externalDevice, err := this.GenerateExternalDevice(jwt, CreateDeviceByTypeRequest{DeviceTypeId: blueprint.ExternalTypeId, Name: msg.Name})
+		if err != nil {
+			return result, true, true, err
+		}
+		device.ExternalRef = externalDevice.Id
+		createdExternalDevices = append(createdExternalDevices, externalDevice.Id)
+	}
+	result = DeviceResponse{World: room.World, Room: room.Room.Id, Device: device}
+	err = this.DevUpdateDevice(result.World, result.Room, result.Device)
+	if err != nil {
+		this.removeExternalDevices(jwt, createdExternalDevices)
+		return result, true, true, err
+	}
+	this.refreshHubs(jwt, result.World)
+	return result, true, true, nil
+}
+
+// applies the current blueprint to all devices of the user which have been created from it; returns the ids of the updated devices
+// msg.Parameter provides values of parameters which have been added to the blueprint after the creation of a device
+// no device is updated if the blueprint can not be applied to all devices
+func (this *StateRepo) RolloutBlueprint(jwt jwt.Jwt, id string, msg RolloutBlueprintRequest) (devices []string, access bool, exists bool, err error) {
+	blueprint, access, exists, err := this.ReadBlueprint(jwt, id)
+	if err != nil || !access || !exists {
+		return devices, access, exists, err
+	}
+	changedWorlds, devices, err := this.getBlueprintRollout(jwt, blueprint, msg.Parameter)
+	if err != nil {
+		return devices, true, true, err
+	}
+	if len(changedWorlds) > 0 {
+		err = this.DevUpdateWorlds(changedWorlds)
+	}
+	return devices, true, true, err
+}
+
+// returns the worlds of the user with the blueprint applied to all devices created from it and the ids of these devices
+func (this *StateRepo) getBlueprintRollout(jwt jwt.Jwt, blueprint DeviceBlueprint, parameter map[string]string) (changedWorlds []WorldMsg, devices []string, err error) {
+	devices = []string{}
+	worlds, err := this.ReadWorlds(jwt)
+	if err != nil {
+		return changedWorlds, devices, err
+	}
+	for _, world := range worlds {
+		changed := false
+		for roomId, room := range world.Rooms {
+			for deviceId, device := range room.Devices {
+				if device.Blueprint != blueprint.Id {
+					continue
+				}
+				room.Devices[deviceId], err = applyBlueprint(device, blueprint, getRolloutParameter(blueprint, device.BlueprintParameter, parameter))
+				if err != nil {
+					return changedWorlds, devices, errors.New("device " + deviceId + ": " + err.Error())
+				}
+				devices = append(devices, deviceId)
+				changed = true
+			}
+			world.Rooms[roomId] = room
+		}
+		if changed {
+			changedWorlds = append(changedWorlds, world)
+		}
+	}
+	sort.Strings(devices)
+	return changedWorlds, devices, nil
+}
+
+func sortedServices(services map[string]Service) (result []Service) {
+	for _, service := range services {
+		result = append(result, service)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id < result[j].Id
+	})
+	return result
+}
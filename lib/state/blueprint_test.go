@@ -0,0 +1,263 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+)
+
+func TestApplyBlueprint(t *testing.T) {
+	blueprint := DeviceBlueprint{
+		Id:             "bp",
+		ExternalTypeId: "type",
+		States:         map[string]interface{}{"on": false, "brightness": float64(0)},
+		ChangeRoutines: []BlueprintChangeRoutine{{Interval: 10, Code: `moses.device.state.set("max", {{max}})`}},
+		Services: []BlueprintService{
+			{Name: "set", ExternalRef: "s1", Code: `moses.device.state.set("on", true)`},
+			{Name: "get", ExternalRef: "s2", SensorInterval: 60, Code: `moses.service.send({"brightness": {{scale}} * moses.device.state.get("brightness")})`},
+		},
+	}
+	parameter, err := getBlueprintParameterList(blueprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parameter, []string{"max", "scale"}) {
+		t.Error(parameter)
+	}
+	blueprint.Parameter = parameter
+
+	device, err := applyBlueprint(DeviceMsg{Id: "d", Name: "lamp"}, blueprint, map[string]string{"max": "100", "scale": "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.Blueprint != "bp" || device.ExternalTypeId != "type" || device.States["on"] != false || len(device.ChangeRoutines) != 1 || len(device.Services) != 2 {
+		t.Fatal(device)
+	}
+	for _, routine := range device.ChangeRoutines {
+		if routine.Code != `moses.device.state.set("max", 100)` {
+			t.Error(routine.Code)
+		}
+	}
+	serviceIds := map[string]string{}
+	for id, service := range device.Services {
+		serviceIds[service.ExternalRef] = id
+		if service.ExternalRef == "s2" && service.Code != `moses.service.send({"brightness": 2 * moses.device.state.get("brightness")})` {
+			t.Error(service.Code)
+		}
+	}
+
+	//rollout keeps states, service ids and parameter
+	device.States["on"] = true
+	blueprint.States["color"] = "white"
+	blueprint.Services = blueprint.Services[1:]
+	updated, err := applyBlueprint(device, blueprint, device.BlueprintParameter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.States["on"] != true || updated.States["color"] != "white" || len(updated.Services) != 1 {
+		t.Error(updated)
+	}
+	if _, ok := updated.Services[serviceIds["s2"]]; !ok {
+		t.Error(updated.Services, serviceIds)
+	}
+
+	if _, ok := updated.ChangeRoutines[device.BlueprintRoutines[0]]; !ok || len(updated.ChangeRoutines) != 1 {
+		t.Error("rollout should keep the routine id", updated.ChangeRoutines, device.BlueprintRoutines)
+	}
+}
+
+func TestApplyBlueprintRoutines(t *testing.T) {
+	blueprint := DeviceBlueprint{
+		Id:        "bp",
+		Parameter: []string{"max"},
+		ChangeRoutines: []BlueprintChangeRoutine{
+			{Interval: 10, Code: `moses.device.state.set("max", {{max}})`},
+			{Interval: 20, Code: `moses.device.state.set("min", 0)`},
+		},
+	}
+	_, err := applyBlueprint(DeviceMsg{Id: "d"}, blueprint, map[string]string{})
+	if err == nil {
+		t.Error("expected error for missing parameter")
+	}
+	_, err = applyBlueprint(DeviceMsg{Id: "d"}, blueprint, map[string]string{"max": "1", "other": "2"})
+	if err == nil {
+		t.Error("expected error for unknown parameter")
+	}
+
+	device, err := applyBlueprint(DeviceMsg{Id: "d"}, blueprint, map[string]string{"max": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(device.BlueprintRoutines) != 2 {
+		t.Fatal(device.BlueprintRoutines)
+	}
+	first, second := device.BlueprintRoutines[0], device.BlueprintRoutines[1]
+	routine := device.ChangeRoutines[first]
+	routine.Disabled = true
+	routine.Template = "tmpl"
+	device.ChangeRoutines[first] = routine
+	device.ChangeRoutines["own"] = ChangeRoutine{Id: "own", Interval: 5, Code: "own"}
+
+	blueprint.ChangeRoutines[0].Interval = 15
+	blueprint.ChangeRoutines = blueprint.ChangeRoutines[:1]
+	updated, err := applyBlueprint(device, blueprint, map[string]string{"max": "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(updated.BlueprintRoutines, []string{first}) || len(updated.ChangeRoutines) != 2 {
+		t.Fatal(updated.BlueprintRoutines, updated.ChangeRoutines)
+	}
+	if _, ok := updated.ChangeRoutines[second]; ok {
+		t.Error("routine removed from the blueprint should be removed")
+	}
+	expected := ChangeRoutine{Id: first, Interval: 15, Code: `moses.device.state.set("max", 2)`, Disabled: true, Template: "tmpl"}
+	if updated.ChangeRoutines[first] != expected {
+		t.Error(updated.ChangeRoutines[first])
+	}
+	if updated.ChangeRoutines["own"].Code != "own" {
+		t.Error("routine not created from the blueprint should be kept", updated.ChangeRoutines)
+	}
+
+	//devices created before routine ids were recorded reuse their routines
+	legacy := DeviceMsg{Id: "legacy", Blueprint: "bp", ChangeRoutines: map[string]ChangeRoutine{"old": {Id: "old", Interval: 10}}}
+	updated, err = applyBlueprint(legacy, blueprint, map[string]string{"max": "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(updated.BlueprintRoutines, []string{"old"}) || len(updated.ChangeRoutines) != 1 || updated.ChangeRoutines["old"].Interval != 15 {
+		t.Error(updated.BlueprintRoutines, updated.ChangeRoutines)
+	}
+}
+
+type blueprintTestPersistence struct {
+	failingWorldPersistence
+	blueprint DeviceBlueprint
+}
+
+func (this blueprintTestPersistence) GetBlueprint(id string) (DeviceBlueprint, error) {
+	return this.blueprint, nil
+}
+
+func TestCreateDeviceByBlueprintRemovesPlatformDevice(t *testing.T) {
+	connector, err := NewLocalConnector(config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deviceType, err := connector.SetDeviceType(model.DeviceType{Name: "lamp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &StateRepo{
+		Connector:   connector,
+		Persistence: blueprintTestPersistence{blueprint: DeviceBlueprint{Id: "bp", Owner: "user", ExternalTypeId: deviceType.Id, Parameter: []string{}}},
+		StateLogger: nopLogger{},
+		Worlds: map[string]*World{"w": {Id: "w", Owner: "user", mux: &sync.Mutex{}, Rooms: map[string]*Room{
+			"r1": {Id: "r1", Devices: map[string]*Device{}},
+		}}},
+	}
+	repo.Start()
+	defer repo.Stop()
+
+	_, _, _, err = repo.CreateDeviceByBlueprint(jwt.Jwt{UserId: "user"}, CreateDeviceByBlueprintRequest{Blueprint: "bp", Room: "r1", Name: "lamp"})
+	if err == nil {
+		t.Fatal("expected persistence error")
+	}
+	if len(connector.ListDevices()) != 0 {
+		t.Error("created platform device should be removed", connector.ListDevices())
+	}
+}
+
+// stores a single blueprint
+type rolloutTestPersistence struct {
+	nopPersistence
+	blueprint DeviceBlueprint
+}
+
+func (this *rolloutTestPersistence) GetBlueprint(id string) (DeviceBlueprint, error) {
+	return this.blueprint, nil
+}
+
+func (this *rolloutTestPersistence) PersistBlueprint(blueprint DeviceBlueprint) error {
+	this.blueprint = blueprint
+	return nil
+}
+
+func TestUpdateBlueprintRollout(t *testing.T) {
+	blueprint := DeviceBlueprint{
+		Id:       "bp",
+		Owner:    "user",
+		Services: []BlueprintService{{Name: "get", Code: `moses.service.send({{scale}})`}},
+	}
+	blueprint.Parameter, _ = getBlueprintParameterList(blueprint)
+	device, err := applyBlueprint(DeviceMsg{Id: "d", Name: "lamp"}, blueprint, map[string]string{"scale": "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	serviceId := sortedServices(device.Services)[0].Id
+	persistence := &rolloutTestPersistence{blueprint: blueprint}
+	repo := &StateRepo{
+		Persistence: persistence,
+		StateLogger: nopLogger{},
+		Worlds: map[string]*World{"w": {Id: "w", Owner: "user", mux: &sync.Mutex{}, Rooms: map[string]*Room{
+			"r": {Id: "r", Devices: map[string]*Device{}},
+		}}},
+	}
+	deviceModel, err := device.ToModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.Worlds["w"].Rooms["r"].Devices["d"] = &deviceModel
+	repo.Start()
+	defer repo.Stop()
+	user := jwt.Jwt{UserId: "user"}
+
+	update := UpdateBlueprintRequest{
+		Id:       "bp",
+		Services: []BlueprintService{{Name: "get", Code: `moses.service.send({{factor}})`}},
+		Rollout:  true,
+	}
+	_, _, _, err = repo.UpdateBlueprint(user, update)
+	if err == nil {
+		t.Fatal("expected error for missing parameter")
+	}
+	if !reflect.DeepEqual(persistence.blueprint.Parameter, []string{"scale"}) {
+		t.Error("invalid update should not be persisted", persistence.blueprint)
+	}
+
+	update.Parameter = map[string]string{"factor": "3"}
+	_, _, _, err = repo.UpdateBlueprint(user, update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, _, _, err := repo.ReadDevice(user, "d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Device.BlueprintParameter, map[string]string{"factor": "3"}) {
+		t.Error(result.Device.BlueprintParameter)
+	}
+	service, ok := result.Device.Services[serviceId]
+	if !ok || len(result.Device.Services) != 1 || service.Code != `moses.service.send(3)` {
+		t.Error("rollout should keep the service id", serviceId, result.Device.Services)
+	}
+}
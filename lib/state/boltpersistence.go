@@ -0,0 +1,457 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"go.etcd.io/bbolt"
+)
+
+// BoltPersistence stores all documents bson encoded in an embedded bbolt database file; one bucket per mongodb collection
+// missing documents are reported as mgo.ErrNotFound to behave like MongoPersistence
+type BoltPersistence struct {
+	db              *bbolt.DB
+	worldBucket     []byte
+	graphBucket     []byte
+	templateBucket  []byte
+	scenarioBucket  []byte
+	snapshotBucket  []byte
+	blueprintBucket []byte
+	commandBucket   []byte //keys are prefixed with the device id
+	stateBucket     []byte //keys are prefixed with the world id
+}
+
+func NewBoltPersistence(config config.Config) (result BoltPersistence, err error) {
+	result.worldBucket = []byte(config.WorldCollectionName)
+	result.graphBucket = []byte(config.GraphCollectionName)
+	result.templateBucket = []byte(config.TemplateCollectionName)
+	result.scenarioBucket = []byte(config.ScenarioCollectionName)
+	result.snapshotBucket = []byte(config.SnapshotCollectionName)
+	result.blueprintBucket = []byte(config.BlueprintCollectionName)
+	result.commandBucket = []byte(config.CommandCollectionName)
+	result.stateBucket = []byte(config.StateCollectionName)
+	result.db, err = bbolt.Open(config.BoltFile, 0600, &bbolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		return result, err
+	}
+	err = result.db.Update(func(tx *bbolt.Tx) error {
+		for _, bucket := range [][]byte{result.worldBucket, result.graphBucket, result.templateBucket, result.scenarioBucket, result.snapshotBucket, result.blueprintBucket, result.commandBucket, result.stateBucket} {
+			_, err := tx.CreateBucketIfNotExists(bucket)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		result.db.Close()
+	}
+	return result, err
+}
+
+func (this *BoltPersistence) Close() {
+	this.db.Close()
+}
+
+func (this BoltPersistence) put(bucket []byte, key string, value interface{}) error {
+	data, err := bson.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return this.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucket).Put([]byte(key), data)
+	})
+}
+
+func (this BoltPersistence) get(bucket []byte, key string, result interface{}) error {
+	return this.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(bucket).Get([]byte(key))
+		if data == nil {
+			return mgo.ErrNotFound
+		}
+		return bson.Unmarshal(data, result)
+	})
+}
+
+func (this BoltPersistence) remove(bucket []byte, key string) error {
+	return this.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucket).Delete([]byte(key))
+	})
+}
+
+// calls f with every document of the bucket whose key starts with prefix
+func (this BoltPersistence) each(bucket []byte, prefix string, f func(data []byte) error) error {
+	return this.db.View(func(tx *bbolt.Tx) error {
+		cursor := tx.Bucket(bucket).Cursor()
+		for key, data := cursor.Seek([]byte(prefix)); key != nil && strings.HasPrefix(string(key), prefix); key, data = cursor.Next() {
+			err := f(data)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func getBoltCommandKey(device string, id string) string {
+	return device + "/" + id
+}
+
+func getBoltStateKey(world string, refId string) string {
+	return world + "/" + refId
+}
+
+// the states of the world are stored in the state bucket; the world document is stored without states
+func (this BoltPersistence) PersistWorld(world World) (err error) {
+	world.CleanStates()
+	data, err := bson.Marshal(getWorldDocument(world))
+	if err != nil {
+		return err
+	}
+	return this.db.Update(func(tx *bbolt.Tx) error {
+		err := this.putStates(tx, getEntityStates(world))
+		if err != nil {
+			return err
+		}
+		known := map[string]bool{}
+		for _, id := range getEntityStateIds(world) {
+			known[getBoltStateKey(world.Id, id)] = true
+		}
+		err = this.removeStates(tx, world.Id, known)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(this.worldBucket).Put([]byte(world.Id), data)
+	})
+}
+
+func (this BoltPersistence) PersistStates(states []EntityStates) (err error) {
+	return this.db.Update(func(tx *bbolt.Tx) error {
+		return this.putStates(tx, states)
+	})
+}
+
+func (this BoltPersistence) putStates(tx *bbolt.Tx, states []EntityStates) error {
+	for _, element := range states {
+		data, err := bson.Marshal(element)
+		if err != nil {
+			return err
+		}
+		err = tx.Bucket(this.stateBucket).Put([]byte(getBoltStateKey(element.World, element.RefId)), data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removes the states of the world, except the states with a key in keep
+func (this BoltPersistence) removeStates(tx *bbolt.Tx, world string, keep map[string]bool) error {
+	prefix := getBoltStateKey(world, "")
+	outdated := [][]byte{}
+	cursor := tx.Bucket(this.stateBucket).Cursor()
+	for key, _ := cursor.Seek([]byte(prefix)); key != nil && strings.HasPrefix(string(key), prefix); key, _ = cursor.Next() {
+		if !keep[string(key)] {
+			outdated = append(outdated, append([]byte{}, key...))
+		}
+	}
+	for _, key := range outdated {
+		err := tx.Bucket(this.stateBucket).Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (this BoltPersistence) PersistGraph(graph Graph) (err error) {
+	return this.put(this.graphBucket, graph.Id, graph)
+}
+
+func (this BoltPersistence) PersistTemplate(templ RoutineTemplate) (err error) {
+	return this.put(this.templateBucket, templ.Id, templ)
+}
+
+func (this BoltPersistence) GetTemplate(id string) (templ RoutineTemplate, err error) {
+	err = this.get(this.templateBucket, id, &templ)
+	return
+}
+
+func (this BoltPersistence) GetTemplates() (templ []RoutineTemplate, err error) {
+	err = this.each(this.templateBucket, "", func(data []byte) error {
+		element := RoutineTemplate{}
+		err := bson.Unmarshal(data, &element)
+		templ = append(templ, element)
+		return err
+	})
+	return
+}
+
+func (this BoltPersistence) LoadWorlds() (result map[string]*World, err error) {
+	result = map[string]*World{}
+	worldStates := map[string][]EntityStates{}
+	err = this.each(this.stateBucket, "", func(data []byte) error {
+		element := EntityStates{}
+		err := bson.Unmarshal(data, &element)
+		worldStates[element.World] = append(worldStates[element.World], element)
+		return err
+	})
+	if err != nil {
+		return result, err
+	}
+	err = this.each(this.worldBucket, "", func(data []byte) error {
+		world := World{}
+		err := bson.Unmarshal(data, &world)
+		if err != nil {
+			return err
+		}
+		world.mux = &sync.Mutex{}
+		applyEntityStates(&world, worldStates[world.Id])
+		world.CleanStates()
+		result[world.Id] = &world
+		return nil
+	})
+	return
+}
+
+func (this BoltPersistence) LoadGraphs() (result map[string]*Graph, err error) {
+	result = map[string]*Graph{}
+	err = this.each(this.graphBucket, "", func(data []byte) error {
+		graph := Graph{}
+		err := bson.Unmarshal(data, &graph)
+		result[graph.Id] = &graph
+		return err
+	})
+	return
+}
+
+func (this BoltPersistence) DeleteWorld(id string) (err error) {
+	return this.db.Update(func(tx *bbolt.Tx) error {
+		err := this.removeStates(tx, id, nil)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(this.worldBucket).Delete([]byte(id))
+	})
+}
+
+func (this BoltPersistence) DeleteGraph(id string) (err error) {
+	return this.remove(this.graphBucket, id)
+}
+
+func (this BoltPersistence) DeleteTemplate(id string) (err error) {
+	return this.remove(this.templateBucket, id)
+}
+
+func (this BoltPersistence) PersistScenario(scenario Scenario) (err error) {
+	return this.put(this.scenarioBucket, scenario.Id, scenario)
+}
+
+func (this BoltPersistence) GetScenario(id string) (scenario Scenario, err error) {
+	err = this.get(this.scenarioBucket, id, &scenario)
+	return
+}
+
+func (this BoltPersistence) GetScenarios(owner string) (scenarios []Scenario, err error) {
+	err = this.each(this.scenarioBucket, "", func(data []byte) error {
+		element := Scenario{}
+		err := bson.Unmarshal(data, &element)
+		if err == nil && element.Owner == owner {
+			scenarios = append(scenarios, element)
+		}
+		return err
+	})
+	return
+}
+
+func (this BoltPersistence) DeleteScenario(id string) (err error) {
+	return this.remove(this.scenarioBucket, id)
+}
+
+func (this BoltPersistence) PersistSnapshot(snapshot WorldSnapshot) (err error) {
+	return this.put(this.snapshotBucket, snapshot.Id, snapshot)
+}
+
+func (this BoltPersistence) GetSnapshot(id string) (snapshot WorldSnapshot, err error) {
+	err = this.get(this.snapshotBucket, id, &snapshot)
+	return
+}
+
+func (this BoltPersistence) GetSnapshots(worldId string) (snapshots []WorldSnapshot, err error) {
+	err = this.each(this.snapshotBucket, "", func(data []byte) error {
+		element := WorldSnapshot{}
+		err := bson.Unmarshal(data, &element)
+		if err == nil && element.World == worldId {
+			element.Data = nil
+			snapshots = append(snapshots, element)
+		}
+		return err
+	})
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Created.Before(snapshots[j].Created)
+	})
+	return
+}
+
+func (this BoltPersistence) DeleteSnapshot(id string) (err error) {
+	return this.remove(this.snapshotBucket, id)
+}
+
+func (this BoltPersistence) DeleteWorldSnapshots(worldId string) (err error) {
+	snapshots, err := this.GetSnapshots(worldId)
+	if err != nil {
+		return err
+	}
+	return this.db.Update(func(tx *bbolt.Tx) error {
+		for _, snapshot := range snapshots {
+			err := tx.Bucket(this.snapshotBucket).Delete([]byte(snapshot.Id))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (this BoltPersistence) PersistBlueprint(blueprint DeviceBlueprint) (err error) {
+	return this.put(this.blueprintBucket, blueprint.Id, blueprint)
+}
+
+func (this BoltPersistence) GetBlueprint(id string) (blueprint DeviceBlueprint, err error) {
+	err = this.get(this.blueprintBucket, id, &blueprint)
+	return
+}
+
+func (this BoltPersistence) GetBlueprints(owner string) (blueprints []DeviceBlueprint, err error) {
+	err = this.each(this.blueprintBucket, "", func(data []byte) error {
+		element := DeviceBlueprint{}
+		err := bson.Unmarshal(data, &element)
+		if err == nil && element.Owner == owner {
+			blueprints = append(blueprints, element)
+		}
+		return err
+	})
+	return
+}
+
+func (this BoltPersistence) DeleteBlueprint(id string) (err error) {
+	return this.remove(this.blueprintBucket, id)
+}
+
+// returns the command records of the device, newest first
+func (this BoltPersistence) getDeviceCommandRecords(device string) (records []CommandRecord, err error) {
+	err = this.each(this.commandBucket, getBoltCommandKey(device, ""), func(data []byte) error {
+		element := CommandRecord{}
+		err := bson.Unmarshal(data, &element)
+		records = append(records, element)
+		return err
+	})
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.After(records[j].Time)
+	})
+	return
+}
+
+func (this BoltPersistence) PersistCommandRecord(record CommandRecord, historySize int) (err error) {
+	err = this.put(this.commandBucket, getBoltCommandKey(record.Device, record.Id), record)
+	if err != nil {
+		return err
+	}
+	records, err := this.getDeviceCommandRecords(record.Device)
+	if err != nil || len(records) <= historySize {
+		return err
+	}
+	return this.db.Update(func(tx *bbolt.Tx) error {
+		for _, outdated := range records[historySize:] {
+			err := tx.Bucket(this.commandBucket).Delete([]byte(getBoltCommandKey(outdated.Device, outdated.Id)))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (this BoltPersistence) GetCommandRecords(query CommandHistoryQuery) (records []CommandRecord, err error) {
+	all, err := this.getDeviceCommandRecords(query.Device)
+	if err != nil {
+		return records, err
+	}
+	for _, record := range all {
+		if query.Service != "" && record.Service != query.Service {
+			continue
+		}
+		if !query.From.IsZero() && record.Time.Before(query.From) {
+			continue
+		}
+		if !query.To.IsZero() && record.Time.After(query.To) {
+			continue
+		}
+		records = append(records, record)
+		if query.Limit > 0 && len(records) >= query.Limit {
+			break
+		}
+	}
+	return records, nil
+}
+
+func (this BoltPersistence) exportScenarios() (scenarios []Scenario, err error) {
+	err = this.each(this.scenarioBucket, "", func(data []byte) error {
+		element := Scenario{}
+		err := bson.Unmarshal(data, &element)
+		scenarios = append(scenarios, element)
+		return err
+	})
+	return
+}
+
+func (this BoltPersistence) exportSnapshots() (snapshots []WorldSnapshot, err error) {
+	err = this.each(this.snapshotBucket, "", func(data []byte) error {
+		element := WorldSnapshot{}
+		err := bson.Unmarshal(data, &element)
+		snapshots = append(snapshots, element)
+		return err
+	})
+	return
+}
+
+func (this BoltPersistence) exportBlueprints() (blueprints []DeviceBlueprint, err error) {
+	err = this.each(this.blueprintBucket, "", func(data []byte) error {
+		element := DeviceBlueprint{}
+		err := bson.Unmarshal(data, &element)
+		blueprints = append(blueprints, element)
+		return err
+	})
+	return
+}
+
+func (this BoltPersistence) exportCommandRecords() (records []CommandRecord, err error) {
+	err = this.each(this.commandBucket, "", func(data []byte) error {
+		element := CommandRecord{}
+		err := bson.Unmarshal(data, &element)
+		records = append(records, element)
+		return err
+	})
+	return
+}
@@ -0,0 +1,198 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/globalsign/mgo"
+)
+
+func newTestBoltPersistence(t *testing.T, file string) *BoltPersistence {
+	persistence, err := NewPersistence(config.Config{
+		WorldCollectionName:     "worlds",
+		GraphCollectionName:     "graphs",
+		TemplateCollectionName:  "templates",
+		ScenarioCollectionName:  "scenarios",
+		SnapshotCollectionName:  "snapshots",
+		BlueprintCollectionName: "blueprints",
+		CommandCollectionName:   "commands",
+		StateCollectionName:     "states",
+		BoltFile:                file,
+	}, PersistenceBolt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(persistence.Close)
+	return persistence.(*BoltPersistence)
+}
+
+func TestBoltPersistenceWorlds(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "moses.db")
+	persistence := newTestBoltPersistence(t, file)
+	world := World{Id: "w", Owner: "user", Name: "world", States: map[string]interface{}{"temperature": 20.5}, Rooms: map[string]*Room{
+		"r": {Id: "r", Name: "room", Devices: map[string]*Device{
+			"d": {Id: "d", Name: "device", ExternalRef: "ref", States: map[string]interface{}{"on": true}},
+		}},
+	}}
+	err := persistence.PersistWorld(world)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = persistence.PersistGraph(Graph{Id: "g", Name: "graph", Values: []Point{{X: 1, Y: 2}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	persistence.Close()
+
+	persistence = newTestBoltPersistence(t, file)
+	worlds, err := persistence.LoadWorlds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, ok := worlds["w"]
+	if !ok || loaded.mux == nil || loaded.Owner != "user" || loaded.States["temperature"] != 20.5 {
+		t.Fatal(worlds)
+	}
+	if device := loaded.Rooms["r"].Devices["d"]; device.ExternalRef != "ref" || device.States["on"] != true {
+		t.Error(device)
+	}
+	graphs, err := persistence.LoadGraphs()
+	if err != nil || len(graphs) != 1 || graphs["g"].Values[0].Y != 2 {
+		t.Error(graphs, err)
+	}
+
+	err = persistence.DeleteWorld("w")
+	if err != nil {
+		t.Fatal(err)
+	}
+	worlds, err = persistence.LoadWorlds()
+	if err != nil || len(worlds) != 0 {
+		t.Error(worlds, err)
+	}
+	_, err = persistence.GetTemplate("unknown")
+	if err != mgo.ErrNotFound {
+		t.Error(err)
+	}
+}
+
+func TestBoltPersistenceQueries(t *testing.T) {
+	persistence := newTestBoltPersistence(t, filepath.Join(t.TempDir(), "moses.db"))
+	for _, blueprint := range []DeviceBlueprint{{Id: "b1", Owner: "user"}, {Id: "b2", Owner: "other"}} {
+		err := persistence.PersistBlueprint(blueprint)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	blueprints, err := persistence.GetBlueprints("user")
+	if err != nil || len(blueprints) != 1 || blueprints[0].Id != "b1" {
+		t.Error(blueprints, err)
+	}
+
+	now := time.Now().Truncate(time.Millisecond)
+	for _, snapshot := range []WorldSnapshot{
+		{Id: "s2", World: "w", Created: now.Add(time.Minute), Data: &WorldMsg{Id: "w"}},
+		{Id: "s1", World: "w", Created: now, Data: &WorldMsg{Id: "w"}},
+		{Id: "s3", World: "other", Created: now},
+	} {
+		err = persistence.PersistSnapshot(snapshot)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshots, err := persistence.GetSnapshots("w")
+	if err != nil || len(snapshots) != 2 || snapshots[0].Id != "s1" || snapshots[0].Data != nil {
+		t.Fatal(snapshots, err)
+	}
+	snapshot, err := persistence.GetSnapshot("s1")
+	if err != nil || snapshot.Data == nil || snapshot.Data.Id != "w" {
+		t.Error(snapshot, err)
+	}
+	err = persistence.DeleteWorldSnapshots("w")
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err = persistence.exportSnapshots()
+	if err != nil || len(snapshots) != 1 || snapshots[0].Id != "s3" {
+		t.Error(snapshots, err)
+	}
+
+	for i, id := range []string{"c1", "c2", "c3"} {
+		err = persistence.PersistCommandRecord(CommandRecord{Id: id, Device: "d", Service: "s", Time: now.Add(time.Duration(i) * time.Second)}, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = persistence.PersistCommandRecord(CommandRecord{Id: "c4", Device: "d2", Service: "s", Time: now}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := persistence.GetCommandRecords(CommandHistoryQuery{Device: "d"})
+	if err != nil || len(records) != 2 || records[0].Id != "c3" || records[1].Id != "c2" {
+		t.Error(records, err)
+	}
+	records, err = persistence.GetCommandRecords(CommandHistoryQuery{Device: "d", To: now.Add(time.Second)})
+	if err != nil || len(records) != 1 || records[0].Id != "c2" {
+		t.Error(records, err)
+	}
+}
+
+func TestMigratePersistence(t *testing.T) {
+	dir := t.TempDir()
+	source := newTestBoltPersistence(t, filepath.Join(dir, "source.db"))
+	target := newTestBoltPersistence(t, filepath.Join(dir, "target.db"))
+	err := source.PersistWorld(World{Id: "w", Owner: "user", Rooms: map[string]*Room{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = source.PersistTemplate(RoutineTemplate{Id: "t", Name: "template"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = source.PersistScenario(Scenario{Id: "s", Owner: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = source.PersistCommandRecord(CommandRecord{Id: "c", Device: "d", Time: time.Now()}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = MigratePersistence(source, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worlds, err := target.LoadWorlds()
+	if err != nil || worlds["w"] == nil || worlds["w"].Owner != "user" {
+		t.Error(worlds, err)
+	}
+	templ, err := target.GetTemplate("t")
+	if err != nil || templ.Name != "template" {
+		t.Error(templ, err)
+	}
+	scenarios, err := target.GetScenarios("user")
+	if err != nil || len(scenarios) != 1 {
+		t.Error(scenarios, err)
+	}
+	records, err := target.GetCommandRecords(CommandHistoryQuery{Device: "d"})
+	if err != nil || len(records) != 1 {
+		t.Error(records, err)
+	}
+}
@@ -0,0 +1,185 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/google/uuid"
+)
+
+const maxBulkDeviceCount = 10000
+const bulkDeviceConcurrency = 10
+const defaultBulkDeviceNamePattern = "device-{{n}}"
+
+// {device_type_id: "", count: 100, rooms: [""], name_pattern: "sensor-{{n}}"}
+type CreateDevicesBulkRequest struct {
+	DeviceTypeId string   `json:"device_type_id"`
+	Count        int      `json:"count"`
+	Rooms        []string `json:"rooms"`        //devices are distributed round-robin over the rooms
+	NamePattern  string   `json:"name_pattern"` //mustache template with the parameter n (1..count); defaults to "device-{{n}}"
+}
+
+type CreateDevicesBulkResponse struct {
+	Created int                `json:"created"`
+	Failed  int                `json:"failed"`
+	Results []BulkDeviceResult `json:"results"`
+}
+
+type BulkDeviceResult struct {
+	N      int    `json:"n"`
+	Name   string `json:"name"`
+	World  string `json:"world"`
+	Room   string `json:"room"`
+	Device string `json:"device,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// creates count devices of the device type; external devices are created concurrently and all devices are applied with a single restart
+// errors of single devices are reported per item of the result
+func (this *StateRepo) CreateDevicesBulk(jwt jwt.Jwt, msg CreateDevicesBulkRequest) (result CreateDevicesBulkResponse, access bool, exists bool, err error) {
+	if msg.Count <= 0 || msg.Count > maxBulkDeviceCount {
+		return result, true, true, errors.New("expect 0 < count <= " + strconv.Itoa(maxBulkDeviceCount))
+	}
+	if len(msg.Rooms) == 0 {
+		return result, true, true, errors.New("missing rooms")
+	}
+	if msg.NamePattern == "" {
+		msg.NamePattern = defaultBulkDeviceNamePattern
+	}
+	rooms := []RoomResponse{}
+	for _, roomId := range msg.Rooms {
+		room, access, exists, err := this.ReadRoom(jwt, roomId)
+		if err != nil || !access || !exists {
+			return result, access, exists, err
+		}
+		rooms = append(rooms, room)
+	}
+	services, states, err := this.prepareServices(jwt, msg.DeviceTypeId)
+	if err != nil {
+		return result, true, true, err
+	}
+
+	devices := make([]DeviceMsg, msg.Count)
+	result.Results = make([]BulkDeviceResult, msg.Count)
+	for i := range result.Results {
+		room := rooms[i%len(rooms)]
+		item := BulkDeviceResult{N: i + 1, World: room.World, Room: room.Room.Id}
+		item.Name, err = RenderTempl(msg.NamePattern, map[string]string{"n": strconv.Itoa(item.N)})
+		if err != nil {
+			return result, true, true, err
+		}
+		result.Results[i] = item
+	}
+
+	wg := sync.WaitGroup{}
+	limit := make(chan bool, bulkDeviceConcurrency)
+	for i := range result.Results {
+		wg.Add(1)
+		limit <- true
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-limit }()
+			item := &result.Results[i]
+			externalDevice, err := this.GenerateExternalDevice(jwt, CreateDeviceByTypeRequest{DeviceTypeId: msg.DeviceTypeId, Name: item.Name})
+			if err != nil {
+				item.Error = err.Error()
+				return
+			}
+			device := DeviceMsg{
+				Id:             uuid.NewString(),
+				Name:           item.Name,
+				ExternalTypeId: externalDevice.DeviceTypeId,
+				ExternalRef:    externalDevice.Id,
+				Services:       map[string]Service{},
+				States:         map[string]interface{}{},
+			}
+			for key, value := range states {
+				device.States[key] = value
+			}
+			for _, service := range services {
+				service.Id = uuid.NewString()
+				device.Services[service.Id] = service
+			}
+			devices[i] = device
+			item.Device = device.Id
+		}(i)
+	}
+	wg.Wait()
+
+	createdExternalDevices := []string{}
+	for i, item := range result.Results {
+		if item.Error == "" {
+			createdExternalDevices = append(createdExternalDevices, devices[i].ExternalRef)
+		}
+	}
+	applied := false
+	defer func() {
+		if !applied {
+			this.removeExternalDevices(jwt, createdExternalDevices)
+		}
+	}()
+
+	worlds := map[string]WorldMsg{}
+	for i, item := range result.Results {
+		if item.Error != "" {
+			result.Failed++
+			continue
+		}
+		world, ok := worlds[item.World]
+		if !ok {
+			world, exists, err = this.DevGetWorld(item.World)
+			if err != nil {
+				return result, true, true, err
+			}
+			if !exists {
+				return result, true, false, nil
+			}
+		}
+		room := world.Rooms[item.Room]
+		if room.Devices == nil {
+			room.Devices = map[string]DeviceMsg{}
+		}
+		room.Devices[item.Device] = devices[i]
+		world.Rooms[item.Room] = room
+		worlds[item.World] = world
+		result.Created++
+	}
+	if len(worlds) == 0 {
+		return result, true, true, nil
+	}
+	worldList := []WorldMsg{}
+	for _, world := range worlds {
+		worldList = append(worldList, world)
+	}
+	sort.Slice(worldList, func(i, j int) bool {
+		return worldList[i].Id < worldList[j].Id
+	})
+	err = this.DevUpdateWorlds(worldList)
+	if err != nil {
+		return result, true, true, err
+	}
+	applied = true
+	for _, world := range worldList {
+		this.refreshHubs(jwt, world.Id)
+	}
+	return result, true, true, nil
+}
@@ -0,0 +1,165 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/SENERGY-Platform/platform-connector-lib/connectionlog"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+)
+
+// records persisted worlds; persisting the world with the id fail returns an error
+type bulkTestPersistence struct {
+	nopPersistence
+	fail      string
+	mux       sync.Mutex
+	persisted []World
+	deleted   []string
+}
+
+func (this *bulkTestPersistence) PersistWorld(world World) error {
+	if world.Id == this.fail {
+		return errors.New("test error")
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.persisted = append(this.persisted, world)
+	return nil
+}
+
+func (this *bulkTestPersistence) DeleteWorld(id string) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.deleted = append(this.deleted, id)
+	return nil
+}
+
+func getBulkTestRepo(t *testing.T, persistence PersistenceInterface, logger connectionlog.Logger) (*StateRepo, *LocalConnector, string) {
+	connector, err := NewLocalConnector(config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deviceType, err := connector.SetDeviceType(model.DeviceType{Name: "sensor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &StateRepo{
+		Connector:   connector,
+		Persistence: persistence,
+		StateLogger: logger,
+		Worlds: map[string]*World{
+			"w1": {Id: "w1", Owner: "user", mux: &sync.Mutex{}, Rooms: map[string]*Room{
+				"r1": {Id: "r1", Devices: map[string]*Device{"existing": {Id: "existing", ExternalRef: "existing_ref", States: map[string]interface{}{}}}},
+			}},
+			"w2": {Id: "w2", Owner: "user", mux: &sync.Mutex{}, Rooms: map[string]*Room{
+				"r2": {Id: "r2", Devices: map[string]*Device{}},
+			}},
+		},
+	}
+	return repo, connector, deviceType.Id
+}
+
+func TestCreateDevicesBulk(t *testing.T) {
+	recorder := &connectionRecorder{}
+	persistence := &bulkTestPersistence{}
+	repo, connector, deviceTypeId := getBulkTestRepo(t, persistence, recorder)
+	repo.Start()
+	defer repo.Stop()
+
+	result, access, exists, err := repo.CreateDevicesBulk(jwt.Jwt{UserId: "user"}, CreateDevicesBulkRequest{DeviceTypeId: deviceTypeId, Count: 5, Rooms: []string{"r1", "r2"}, NamePattern: "sensor-{{n}}"})
+	if err != nil || !access || !exists {
+		t.Fatal(access, exists, err)
+	}
+	if result.Created != 5 || result.Failed != 0 || len(result.Results) != 5 {
+		t.Fatal(result)
+	}
+	if result.Results[0].Name != "sensor-1" || result.Results[0].Room != "r1" || result.Results[1].Room != "r2" || result.Results[2].Room != "r1" {
+		t.Error(result.Results)
+	}
+	if len(connector.ListDevices()) != 5 {
+		t.Error(connector.ListDevices())
+	}
+	if len(repo.Worlds["w1"].Rooms["r1"].Devices) != 4 || len(repo.Worlds["w2"].Rooms["r2"].Devices) != 2 {
+		t.Error(repo.Worlds["w1"].Rooms["r1"].Devices, repo.Worlds["w2"].Rooms["r2"].Devices)
+	}
+	if len(persistence.persisted) != 2 {
+		t.Error("expected all devices to be applied with one persist per world", len(persistence.persisted))
+	}
+	for _, item := range result.Results {
+		device := repo.Worlds[item.World].Rooms[item.Room].Devices[item.Device]
+		if device == nil || device.Name != item.Name || device.ExternalRef == "" {
+			t.Error(item, device)
+		}
+	}
+	if entries := recorder.get(); len(entries) != 6 {
+		t.Error("expected connection log of existing and created devices", entries)
+	}
+}
+
+func TestCreateDevicesBulkErrors(t *testing.T) {
+	persistence := &bulkTestPersistence{}
+	repo, connector, deviceTypeId := getBulkTestRepo(t, persistence, nopLogger{})
+	repo.Start()
+	defer repo.Stop()
+	user := jwt.Jwt{UserId: "user"}
+
+	_, _, _, err := repo.CreateDevicesBulk(user, CreateDevicesBulkRequest{DeviceTypeId: deviceTypeId, Count: 0, Rooms: []string{"r1"}})
+	if err == nil {
+		t.Error("expected error for count 0")
+	}
+	_, _, _, err = repo.CreateDevicesBulk(user, CreateDevicesBulkRequest{DeviceTypeId: deviceTypeId, Count: 1})
+	if err == nil {
+		t.Error("expected error for missing rooms")
+	}
+	_, access, _, err := repo.CreateDevicesBulk(jwt.Jwt{UserId: "other"}, CreateDevicesBulkRequest{DeviceTypeId: deviceTypeId, Count: 1, Rooms: []string{"r1"}})
+	if err != nil || access {
+		t.Error(access, err)
+	}
+	_, _, _, err = repo.CreateDevicesBulk(user, CreateDevicesBulkRequest{DeviceTypeId: deviceTypeId, Count: 1, Rooms: []string{"r1"}, NamePattern: "{{#n}"})
+	if err == nil {
+		t.Error("expected error for invalid name pattern")
+	}
+	if len(connector.ListDevices()) != 0 {
+		t.Fatal(connector.ListDevices())
+	}
+
+	//the second world can not be persisted: created platform devices are removed and the first world is restored
+	persistence.fail = "w2"
+	_, _, _, err = repo.CreateDevicesBulk(user, CreateDevicesBulkRequest{DeviceTypeId: deviceTypeId, Count: 4, Rooms: []string{"r1", "r2"}})
+	if err == nil || !strings.Contains(err.Error(), "test error") {
+		t.Fatal(err)
+	}
+	if len(connector.ListDevices()) != 0 {
+		t.Error("created platform devices should be removed", connector.ListDevices())
+	}
+	if len(repo.Worlds["w1"].Rooms["r1"].Devices) != 1 || len(repo.Worlds["w2"].Rooms["r2"].Devices) != 0 {
+		t.Error("worlds should not change", repo.Worlds["w1"].Rooms["r1"].Devices, repo.Worlds["w2"].Rooms["r2"].Devices)
+	}
+	if len(persistence.persisted) == 0 {
+		t.Fatal("expected persisted world")
+	}
+	last := persistence.persisted[len(persistence.persisted)-1]
+	if last.Id != "w1" || len(last.Rooms["r1"].Devices) != 1 {
+		t.Error("first world should be restored", last)
+	}
+}
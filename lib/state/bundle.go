@@ -0,0 +1,308 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+const WorldBundleVersion = 1
+
+const (
+	BundleFormatJson = "json"
+	BundleFormatYaml = "yaml"
+)
+
+// WorldBundle is a self-contained description of a world which may be imported into another moses instance
+// templates are referenced by ChangeRoutine.Template; graphs are referenced by their id as string literal in routine or service code
+// graphs have no owner, so every graph referenced by the world is exported, regardless of who created it
+type WorldBundle struct {
+	Version   int               `json:"version"`
+	World     WorldMsg          `json:"world"`
+	Templates []RoutineTemplate `json:"templates"`
+	Graphs    []Graph           `json:"graphs"`
+}
+
+type ImportWorldRequest struct {
+	Name         string //defaults to the name of the bundled world
+	ExternalRefs string //"none" || "new" || "keep"; see CloneWorldRequest
+}
+
+func (this *StateRepo) ExportWorld(jwt jwt.Jwt, worldId string) (result WorldBundle, access bool, exists bool, err error) {
+	world, access, exists, err := this.ReadWorld(jwt, worldId)
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	world.Webhooks = nil //webhooks and their secrets are not exported
+	result = WorldBundle{Version: WorldBundleVersion, World: world, Templates: []RoutineTemplate{}, Graphs: []Graph{}}
+	templateIds := map[string]bool{}
+	codes := []string{}
+	mapWorldChangeRoutines(&world, func(routine ChangeRoutine) ChangeRoutine {
+		if routine.Template != "" {
+			templateIds[routine.Template] = true
+		}
+		codes = append(codes, routine.Code)
+		return routine
+	})
+	mapWorldServices(&world, func(service Service) Service {
+		codes = append(codes, service.Code)
+		return service
+	})
+	for id := range templateIds {
+		templ, exists, err := this.ReadTemplate(jwt, id)
+		if err != nil {
+			return result, true, true, err
+		}
+		if exists {
+			result.Templates = append(result.Templates, templ)
+		}
+	}
+	sort.Slice(result.Templates, func(i, j int) bool {
+		return result.Templates[i].Id < result.Templates[j].Id
+	})
+	this.mux.RLock()
+	for _, graph := range this.Graphs {
+		if graph.Id == "" {
+			continue
+		}
+		for _, code := range codes {
+			if referencesGraph(code, graph.Id) {
+				result.Graphs = append(result.Graphs, *graph)
+				break
+			}
+		}
+	}
+	this.mux.RUnlock()
+	sort.Slice(result.Graphs, func(i, j int) bool {
+		return result.Graphs[i].Id < result.Graphs[j].Id
+	})
+	return result, true, true, nil
+}
+
+// creates the bundled world for the user with new ids; templates are reused if an identical template exists
+// created platform devices, templates and graphs are removed if the import fails
+func (this *StateRepo) ImportWorld(jwt jwt.Jwt, bundle WorldBundle, msg ImportWorldRequest) (result WorldMsg, err error) {
+	if bundle.Version > WorldBundleVersion {
+		return result, errors.New("unsupported bundle version")
+	}
+	createdExternalDevices := []string{}
+	createdTemplates := []string{}
+	createdGraphs := []string{}
+	defer func() {
+		if err != nil {
+			this.removeExternalDevices(jwt, createdExternalDevices)
+			this.removeImportedTemplates(jwt, createdTemplates)
+			this.removeImportedGraphs(createdGraphs)
+		}
+	}()
+	externalRef, err := this.getCloneExternalRefFunc(jwt, msg.ExternalRefs, &createdExternalDevices)
+	if err != nil {
+		return result, err
+	}
+
+	templateIds := map[string]string{}
+	for _, templ := range bundle.Templates {
+		id, created, err := this.importTemplate(jwt, templ)
+		if err != nil {
+			return result, err
+		}
+		if created {
+			createdTemplates = append(createdTemplates, id)
+		}
+		templateIds[templ.Id] = id
+	}
+	graphIds := map[string]string{}
+	for _, graph := range bundle.Graphs {
+		if graph.Id == "" {
+			continue
+		}
+		oldId := graph.Id
+		graph.Id = uuid.NewString()
+		err = this.Persistence.PersistGraph(graph)
+		if err != nil {
+			return result, err
+		}
+		createdGraphs = append(createdGraphs, graph.Id)
+		this.mux.Lock()
+		if this.Graphs == nil {
+			this.Graphs = map[string]*Graph{}
+		}
+		this.Graphs[graph.Id] = &graph
+		this.mux.Unlock()
+		graphIds[oldId] = graph.Id
+	}
+	replaceGraphIds := func(code string) string {
+		for oldId, newId := range graphIds {
+			code = strings.ReplaceAll(code, oldId, newId)
+		}
+		return code
+	}
+
+	world := bundle.World
+	mapWorldChangeRoutines(&world, func(routine ChangeRoutine) ChangeRoutine {
+		if routine.Template != "" {
+			routine.Template = templateIds[routine.Template]
+		}
+		routine.Code = replaceGraphIds(routine.Code)
+		return routine
+	})
+	mapWorldServices(&world, func(service Service) Service {
+		service.Code = replaceGraphIds(service.Code)
+		return service
+	})
+
+	result, err = cloneWorldMsg(world, externalRef)
+	if err != nil {
+		return result, err
+	}
+	result.Owner = jwt.UserId
+	if msg.Name != "" {
+		result.Name = msg.Name
+	}
+	err = this.DevUpdateWorld(result)
+	return result, err
+}
+
+// returns the id of an existing template with the same content or creates a new template
+func (this *StateRepo) importTemplate(jwt jwt.Jwt, templ RoutineTemplate) (id string, created bool, err error) {
+	if templ.Id != "" {
+		existing, exists, err := this.ReadTemplate(jwt, templ.Id)
+		if err != nil {
+			return id, false, err
+		}
+		if exists && existing.Template == templ.Template {
+			return existing.Id, false, nil
+		}
+	}
+	templates, err := this.ReadTemplates(jwt)
+	if err != nil {
+		return id, false, err
+	}
+	for _, existing := range templates {
+		if existing.Template == templ.Template {
+			return existing.Id, false, nil
+		}
+	}
+	result, err := this.CreateTemplate(jwt, CreateTemplateRequest{Name: templ.Name, Description: templ.Description, Template: templ.Template})
+	if err != nil {
+		return id, false, err
+	}
+	return result.Id, true, nil
+}
+
+// removes templates which have been created for a failed import
+func (this *StateRepo) removeImportedTemplates(jwt jwt.Jwt, ids []string) {
+	for _, id := range ids {
+		err := this.DeleteTemplate(jwt, id)
+		if err != nil {
+			log.Println("WARNING: unable to remove imported template", id, err)
+		}
+	}
+}
+
+// returns true if the code contains the graph id as string literal
+func referencesGraph(code string, graphId string) bool {
+	for _, quote := range []string{"\"", "'", "`"} {
+		if strings.Contains(code, quote+graphId+quote) {
+			return true
+		}
+	}
+	return false
+}
+
+// removes graphs which have been created for a failed import
+func (this *StateRepo) removeImportedGraphs(ids []string) {
+	for _, id := range ids {
+		err := this.Persistence.DeleteGraph(id)
+		if err != nil {
+			log.Println("WARNING: unable to remove imported graph", id, err)
+			continue
+		}
+		this.mux.Lock()
+		delete(this.Graphs, id)
+		this.mux.Unlock()
+	}
+}
+
+// replaces every change routine of the world, its rooms and devices with the result of f
+func mapWorldChangeRoutines(world *WorldMsg, f func(routine ChangeRoutine) ChangeRoutine) {
+	mapRoutines := func(routines map[string]ChangeRoutine) {
+		for key, routine := range routines {
+			routines[key] = f(routine)
+		}
+	}
+	mapRoutines(world.ChangeRoutines)
+	for _, room := range world.Rooms {
+		mapRoutines(room.ChangeRoutines)
+		for _, device := range room.Devices {
+			mapRoutines(device.ChangeRoutines)
+		}
+	}
+}
+
+// replaces every service of the world with the result of f
+func mapWorldServices(world *WorldMsg, f func(service Service) Service) {
+	for _, room := range world.Rooms {
+		for _, device := range room.Devices {
+			for key, service := range device.Services {
+				device.Services[key] = f(service)
+			}
+		}
+	}
+}
+
+func MarshalWorldBundle(bundle WorldBundle, format string) (result []byte, err error) {
+	switch format {
+	case "", BundleFormatJson:
+		return json.Marshal(bundle)
+	case BundleFormatYaml:
+		//use a generic copy to keep the json field names
+		var temp interface{}
+		err = jsonCopy(bundle, &temp)
+		if err != nil {
+			return result, err
+		}
+		return yaml.Marshal(temp)
+	default:
+		return result, errors.New("unknown format: " + format)
+	}
+}
+
+func UnmarshalWorldBundle(data []byte, format string) (result WorldBundle, err error) {
+	switch format {
+	case "", BundleFormatJson:
+		err = json.Unmarshal(data, &result)
+	case BundleFormatYaml:
+		var temp interface{}
+		err = yaml.Unmarshal(data, &temp)
+		if err != nil {
+			return result, err
+		}
+		err = jsonCopy(temp, &result)
+	default:
+		err = errors.New("unknown format: " + format)
+	}
+	return result, err
+}
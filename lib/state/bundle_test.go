@@ -0,0 +1,160 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+)
+
+func TestWorldBundleFormats(t *testing.T) {
+	bundle := WorldBundle{
+		Version: WorldBundleVersion,
+		World: WorldMsg{
+			Id:             "w",
+			Name:           "world",
+			States:         map[string]interface{}{"temperature": float64(20)},
+			ChangeRoutines: map[string]ChangeRoutine{"r": {Id: "r", Interval: 10, Code: "code", Template: "t"}},
+			Rooms: map[string]RoomMsg{
+				"room": {Id: "room", Name: "room", States: map[string]interface{}{}, Devices: map[string]DeviceMsg{
+					"d": {Id: "d", Name: "device", ExternalTypeId: "type", States: map[string]interface{}{}, Services: map[string]Service{"s": {Id: "s", Name: "service", Code: "code"}}},
+				}},
+			},
+		},
+		Templates: []RoutineTemplate{{Id: "t", Name: "templ", Template: "code", Parameter: []string{}}},
+		Graphs:    []Graph{{Id: "g", Name: "graph", Values: []Point{{X: 1, Y: 2}}}},
+	}
+	for _, format := range []string{BundleFormatJson, BundleFormatYaml} {
+		data, err := MarshalWorldBundle(bundle, format)
+		if err != nil {
+			t.Fatal(format, err)
+		}
+		if format == BundleFormatYaml && !strings.Contains(string(data), "change_routines:") {
+			t.Error(string(data))
+		}
+		result, err := UnmarshalWorldBundle(data, format)
+		if err != nil {
+			t.Fatal(format, err)
+		}
+		if !reflect.DeepEqual(result, bundle) {
+			t.Errorf("%v\n%#v\n%#v", format, result, bundle)
+		}
+	}
+}
+
+func TestMapWorldChangeRoutines(t *testing.T) {
+	world := WorldMsg{
+		ChangeRoutines: map[string]ChangeRoutine{"w": {Id: "w"}},
+		Rooms: map[string]RoomMsg{"r": {
+			ChangeRoutines: map[string]ChangeRoutine{"r": {Id: "r"}},
+			Devices:        map[string]DeviceMsg{"d": {ChangeRoutines: map[string]ChangeRoutine{"d": {Id: "d"}}}},
+		}},
+	}
+	mapWorldChangeRoutines(&world, func(routine ChangeRoutine) ChangeRoutine {
+		routine.Code = "mapped " + routine.Id
+		return routine
+	})
+	if world.ChangeRoutines["w"].Code != "mapped w" || world.Rooms["r"].ChangeRoutines["r"].Code != "mapped r" || world.Rooms["r"].Devices["d"].ChangeRoutines["d"].Code != "mapped d" {
+		t.Error(world)
+	}
+}
+
+// records templates and graphs; persisting worlds fails
+type bundleTestPersistence struct {
+	nopPersistence
+	templates map[string]RoutineTemplate
+	graphs    map[string]Graph
+}
+
+func (this *bundleTestPersistence) PersistWorld(world World) error {
+	return errors.New("test error")
+}
+
+func (this *bundleTestPersistence) PersistTemplate(templ RoutineTemplate) error {
+	this.templates[templ.Id] = templ
+	return nil
+}
+
+func (this *bundleTestPersistence) DeleteTemplate(id string) error {
+	delete(this.templates, id)
+	return nil
+}
+
+func (this *bundleTestPersistence) GetTemplates() (result []RoutineTemplate, err error) {
+	for _, templ := range this.templates {
+		result = append(result, templ)
+	}
+	return result, nil
+}
+
+func (this *bundleTestPersistence) PersistGraph(graph Graph) error {
+	this.graphs[graph.Id] = graph
+	return nil
+}
+
+func (this *bundleTestPersistence) DeleteGraph(id string) error {
+	delete(this.graphs, id)
+	return nil
+}
+
+func TestImportWorldRemovesCreatedTemplatesAndGraphs(t *testing.T) {
+	persistence := &bundleTestPersistence{
+		templates: map[string]RoutineTemplate{"existing": {Id: "existing", Template: "existing code"}},
+		graphs:    map[string]Graph{},
+	}
+	repo := &StateRepo{Persistence: persistence, StateLogger: nopLogger{}, Worlds: map[string]*World{}, Graphs: map[string]*Graph{}}
+	bundle := WorldBundle{
+		Version: WorldBundleVersion,
+		World: WorldMsg{
+			Id:   "w",
+			Name: "world",
+			ChangeRoutines: map[string]ChangeRoutine{
+				"r1": {Id: "r1", Interval: 10, Code: "code g", Template: "t1"},
+				"r2": {Id: "r2", Interval: 10, Code: "existing code", Template: "t2"},
+			},
+			Rooms: map[string]RoomMsg{},
+		},
+		Templates: []RoutineTemplate{{Id: "t1", Name: "new", Template: "code {{graph}}"}, {Id: "t2", Name: "existing", Template: "existing code"}},
+		Graphs:    []Graph{{Id: "g", Name: "graph", Values: []Point{{X: 1, Y: 2}}}},
+	}
+	_, err := repo.ImportWorld(jwt.Jwt{UserId: "user"}, bundle, ImportWorldRequest{})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if len(persistence.templates) != 1 || persistence.templates["existing"].Id != "existing" {
+		t.Error(persistence.templates)
+	}
+	if len(persistence.graphs) != 0 || len(repo.Graphs) != 0 {
+		t.Error(persistence.graphs, repo.Graphs)
+	}
+	if len(repo.Worlds) != 0 {
+		t.Error(repo.Worlds)
+	}
+}
+
+func TestReferencesGraph(t *testing.T) {
+	code := `var values = graph("g1"); var other = graph('g2'); var third = graph(` + "`g3`" + `); var g4 = 1;`
+	for id, expected := range map[string]bool{"g1": true, "g2": true, "g3": true, "g4": false, "g": false} {
+		if referencesGraph(code, id) != expected {
+			t.Error(id, expected)
+		}
+	}
+}
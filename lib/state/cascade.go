@@ -0,0 +1,204 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+)
+
+type CascadeDeleteResult struct {
+	Deleted bool                     `json:"deleted"` //false if the world, room or device is kept because some platform devices could not be deleted
+	Failed  int                      `json:"failed"`
+	Devices []ExternalDeviceDeletion `json:"devices"`
+}
+
+type ExternalDeviceDeletion struct {
+	Device      string `json:"device"`
+	ExternalRef string `json:"external_ref"`
+	Skipped     bool   `json:"skipped,omitempty"` //the platform device is kept because it is used by another moses device
+	Error       string `json:"error,omitempty"`
+}
+
+// deletes the world, the platform devices of its devices and its platform hubs
+// if a platform device can not be deleted, the world is kept with the devices whose platform devices could not be deleted
+func (this *StateRepo) DeleteWorldCascade(jwt jwt.Jwt, id string) (result CascadeDeleteResult, access bool, exists bool, err error) {
+	world, access, exists, err := this.ReadWorld(jwt, id)
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	devices := []DeviceMsg{}
+	for _, room := range world.Rooms {
+		for _, device := range room.Devices {
+			devices = append(devices, device)
+		}
+	}
+	result, failed := this.deleteExternalDevicesOf(jwt, devices)
+	if len(failed) == 0 {
+		_, _, err = this.DeleteWorld(jwt, id)
+		result.Deleted = err == nil
+		if result.Deleted {
+			hubs := []*Hub{world.Hub}
+			for _, room := range world.Rooms {
+				hubs = append(hubs, room.Hub)
+			}
+			this.removeHubs(jwt, hubs...)
+		}
+		return result, true, true, err
+	}
+	for roomId, room := range world.Rooms {
+		for deviceId := range room.Devices {
+			if !failed[deviceId] {
+				delete(room.Devices, deviceId)
+			}
+		}
+		world.Rooms[roomId] = room
+	}
+	err = this.DevUpdateWorld(world)
+	if err == nil {
+		this.refreshHubs(jwt, world.Id)
+	}
+	return result, true, true, err
+}
+
+// deletes the room, the platform devices of its devices and its platform hub
+// if a platform device can not be deleted, the room is kept with the devices whose platform devices could not be deleted
+func (this *StateRepo) DeleteRoomCascade(jwt jwt.Jwt, id string) (result CascadeDeleteResult, access bool, exists bool, err error) {
+	room, access, exists, err := this.ReadRoom(jwt, id)
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	devices := []DeviceMsg{}
+	for _, device := range room.Room.Devices {
+		devices = append(devices, device)
+	}
+	result, failed := this.deleteExternalDevicesOf(jwt, devices)
+	world, exists, err := this.DevGetWorld(room.World)
+	if err != nil {
+		return result, true, true, err
+	}
+	if !exists {
+		return result, true, true, errors.New("inconsistent world existence read")
+	}
+	if len(failed) == 0 {
+		delete(world.Rooms, room.Room.Id)
+	} else {
+		worldRoom := world.Rooms[room.Room.Id]
+		for deviceId := range worldRoom.Devices {
+			if !failed[deviceId] {
+				delete(worldRoom.Devices, deviceId)
+			}
+		}
+		world.Rooms[room.Room.Id] = worldRoom
+	}
+	err = this.DevUpdateWorld(world)
+	result.Deleted = err == nil && len(failed) == 0
+	if result.Deleted {
+		this.removeHubs(jwt, room.Room.Hub)
+	}
+	if err == nil {
+		this.refreshHubs(jwt, world.Id)
+	}
+	return result, true, true, err
+}
+
+// deletes the device and its platform device; the device is kept if the platform device can not be deleted
+func (this *StateRepo) DeleteDeviceCascade(jwt jwt.Jwt, id string) (result CascadeDeleteResult, access bool, exists bool, err error) {
+	device, access, exists, err := this.ReadDevice(jwt, id)
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	result, failed := this.deleteExternalDevicesOf(jwt, []DeviceMsg{device.Device})
+	if len(failed) > 0 {
+		return result, true, true, nil
+	}
+	world, exists, err := this.DevGetWorld(device.World)
+	if err != nil {
+		return result, true, true, err
+	}
+	if !exists {
+		return result, true, true, errors.New("inconsistent world existence read")
+	}
+	delete(world.Rooms[device.Room].Devices, device.Device.Id)
+	err = this.DevUpdateWorld(world)
+	result.Deleted = err == nil
+	if result.Deleted {
+		this.refreshHubs(jwt, world.Id)
+	}
+	return result, true, true, err
+}
+
+// deletes the platform devices of the given devices; platform devices which are used by other moses devices are skipped
+// returns the ids of the devices whose platform devices could not be deleted
+func (this *StateRepo) deleteExternalDevicesOf(jwt jwt.Jwt, devices []DeviceMsg) (result CascadeDeleteResult, failed map[string]bool) {
+	result.Devices = []ExternalDeviceDeletion{}
+	failed = map[string]bool{}
+	deleted := map[string]bool{}
+	for _, device := range devices {
+		deleted[device.Id] = true
+	}
+	usage := this.getExternalRefUsage()
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].Id < devices[j].Id
+	})
+	handled := map[string]bool{}
+	failedRefs := map[string]string{}
+	for _, device := range devices {
+		if device.ExternalRef == "" {
+			continue
+		}
+		deletion := ExternalDeviceDeletion{Device: device.Id, ExternalRef: device.ExternalRef}
+		for _, user := range usage[device.ExternalRef] {
+			if !deleted[user] {
+				deletion.Skipped = true
+			}
+		}
+		if !deletion.Skipped && !handled[device.ExternalRef] {
+			handled[device.ExternalRef] = true
+			err := this.DeleteExternalDevice(jwt, device.ExternalRef)
+			if err != nil {
+				failedRefs[device.ExternalRef] = err.Error()
+			}
+		}
+		if msg, ok := failedRefs[device.ExternalRef]; ok {
+			deletion.Error = msg
+			failed[device.Id] = true
+			result.Failed++
+		}
+		result.Devices = append(result.Devices, deletion)
+	}
+	return result, failed
+}
+
+// returns the ids of all moses devices by their external ref
+func (this *StateRepo) getExternalRefUsage() (result map[string][]string) {
+	this.mux.RLock()
+	defer this.mux.RUnlock()
+	result = map[string][]string{}
+	for _, world := range this.Worlds {
+		for _, room := range world.Rooms {
+			for _, device := range room.Devices {
+				if device.ExternalRef != "" {
+					result[device.ExternalRef] = append(result[device.ExternalRef], device.Id)
+				}
+			}
+		}
+	}
+	return result
+}
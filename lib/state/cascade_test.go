@@ -0,0 +1,113 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+)
+
+func TestDeleteExternalDevicesOf(t *testing.T) {
+	deletedRefs := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodDelete {
+			t.Error(request.Method)
+		}
+		if request.URL.Path == "/devices/broken" {
+			http.Error(writer, "error", http.StatusInternalServerError)
+			return
+		}
+		if request.URL.Path == "/devices/gone" {
+			http.Error(writer, "not found", http.StatusNotFound)
+			return
+		}
+		deletedRefs = append(deletedRefs, request.URL.Path)
+	}))
+	defer server.Close()
+
+	shared := &Device{Id: "other", ExternalRef: "shared"}
+	repo := &StateRepo{
+		Connector: NewSenergyConnector(nil, config.Config{DeviceManagerUrl: server.URL}),
+		Worlds:    map[string]*World{"w2": {Id: "w2", Rooms: map[string]*Room{"r": {Id: "r", Devices: map[string]*Device{"other": shared}}}}},
+	}
+	devices := []DeviceMsg{
+		{Id: "d1", ExternalRef: "ext1"},
+		{Id: "d2", ExternalRef: "broken"},
+		{Id: "d3", ExternalRef: "shared"},
+		{Id: "d4"},
+		{Id: "d5", ExternalRef: "broken"},
+		{Id: "d6", ExternalRef: "gone"},
+	}
+	result, failed := repo.deleteExternalDevicesOf(jwt.Jwt{Impersonate: "Bearer test"}, devices)
+	if len(deletedRefs) != 1 || deletedRefs[0] != "/devices/ext1" {
+		t.Error(deletedRefs)
+	}
+	if len(failed) != 2 || !failed["d2"] || !failed["d5"] || result.Failed != 2 {
+		t.Error(failed, result)
+	}
+	if len(result.Devices) != 5 || result.Devices[0].Error != "" || result.Devices[1].Error == "" || !result.Devices[2].Skipped || result.Devices[3].Device != "d5" {
+		t.Error(result.Devices)
+	}
+	if result.Devices[4].Device != "d6" || result.Devices[4].Error != "" {
+		t.Error("already deleted platform device should count as deleted", result.Devices)
+	}
+}
+
+func TestDeleteDeviceKeepsPlatformDevice(t *testing.T) {
+	repo, connector, deviceTypeId := getBulkTestRepo(t, nopPersistence{}, nopLogger{})
+	platformDevice, err := connector.CreateDevice("", model.Device{Name: "lamp", LocalId: "lamp", DeviceTypeId: deviceTypeId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.Worlds["w1"].Rooms["r1"].Devices["a"] = &Device{Id: "a", ExternalRef: platformDevice.Id, States: map[string]interface{}{}}
+	repo.Worlds["w2"].Rooms["r2"].Devices["b"] = &Device{Id: "b", ExternalRef: platformDevice.Id, States: map[string]interface{}{}}
+	repo.Start()
+	defer repo.Stop()
+	user := jwt.Jwt{UserId: "user"}
+
+	_, _, _, err = repo.DeleteDevice(user, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(connector.ListDevices()) != 1 {
+		t.Error("delete without cascade should keep the platform device", connector.ListDevices())
+	}
+
+	repo.Stop()
+	repo.Worlds["w1"].Rooms["r1"].Devices["a"] = &Device{Id: "a", ExternalRef: platformDevice.Id, States: map[string]interface{}{}}
+	repo.Start()
+	result, _, _, err := repo.DeleteDeviceCascade(user, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Deleted || len(result.Devices) != 1 || !result.Devices[0].Skipped || len(connector.ListDevices()) != 1 {
+		t.Error("platform device used by another device should be kept", result, connector.ListDevices())
+	}
+
+	result, _, _, err = repo.DeleteDeviceCascade(user, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Deleted || len(result.Devices) != 1 || result.Devices[0].Skipped || len(connector.ListDevices()) != 0 {
+		t.Error(result, connector.ListDevices())
+	}
+}
@@ -0,0 +1,182 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"errors"
+	"log"
+
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/google/uuid"
+)
+
+const (
+	CloneExternalRefsNone = "none" //cloned devices have no external ref
+	CloneExternalRefsNew  = "new"  //cloned devices get new platform devices of the same device type; devices without type get no external ref
+	CloneExternalRefsKeep = "keep" //cloned devices share the external ref of the original device; commands are handled by only one of them
+)
+
+type CloneWorldRequest struct {
+	Name         string `json:"name"`          //defaults to the name of the original world
+	ExternalRefs string `json:"external_refs"` //"none" || "new" || "keep"; defaults to "none"
+}
+
+func (this *StateRepo) CloneWorld(jwt jwt.Jwt, worldId string, msg CloneWorldRequest) (result WorldMsg, access bool, exists bool, err error) {
+	world, access, exists, err := this.ReadWorld(jwt, worldId)
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	createdExternalDevices := []string{}
+	externalRef, err := this.getCloneExternalRefFunc(jwt, msg.ExternalRefs, &createdExternalDevices)
+	if err != nil {
+		return result, true, true, err
+	}
+	result, err = cloneWorldMsg(world, externalRef)
+	if err == nil {
+		result.Owner = jwt.UserId
+		if msg.Name != "" {
+			result.Name = msg.Name
+		}
+		err = this.DevUpdateWorld(result)
+	}
+	if err != nil {
+		this.removeExternalDevices(jwt, createdExternalDevices)
+		return result, true, true, err
+	}
+	return result, true, true, nil
+}
+
+// returns the function which decides the external ref of cloned devices; ids of new platform devices are appended to createdExternalDevices
+func (this *StateRepo) getCloneExternalRefFunc(jwt jwt.Jwt, option string, createdExternalDevices *[]string) (externalRef func(device DeviceMsg) (string, error), err error) {
+	switch option {
+	case "", CloneExternalRefsNone:
+		return func(device DeviceMsg) (string, error) {
+			return "", nil
+		}, nil
+	case CloneExternalRefsKeep:
+		return func(device DeviceMsg) (string, error) {
+			return device.ExternalRef, nil
+		}, nil
+	case CloneExternalRefsNew:
+		return func(device DeviceMsg) (string, error) {
+			if device.ExternalTypeId == "" {
+				return "", nil
+			}
+			externalDevice, err := this.GenerateExternalDevice(jwt, CreateDeviceByTypeRequest{DeviceTypeId: device.ExternalTypeId, Name: device.Name})
+			if err != nil {
+				return "", err
+			}
+			*createdExternalDevices = append(*createdExternalDevices, externalDevice.Id)
+			return externalDevice.Id, nil
+		}, nil
+	default:
+		return nil, errors.New("unknown external_refs option: " + option)
+	}
+}
+
+// removes platform devices which have been created for a failed clone or import
+func (this *StateRepo) removeExternalDevices(jwt jwt.Jwt, ids []string) {
+	for _, id := range ids {
+		err := this.DeleteExternalDevice(jwt, id)
+		if err != nil {
+			log.Println("WARNING: unable to remove external device", id, err)
+		}
+	}
+}
+
+// returns a deep copy of the world without webhooks and platform hubs and with new ids for the world, rooms, devices, services and change routines
+// externalRef is called for every device to determine the external ref of the copy
+func cloneWorldMsg(world WorldMsg, externalRef func(device DeviceMsg) (string, error)) (result WorldMsg, err error) {
+	err = jsonCopy(world, &result)
+	if err != nil {
+		return result, err
+	}
+	result.Owner = world.Owner
+	result.Id = uuid.NewString()
+	result.Webhooks = nil
+	if result.Hub != nil {
+		result.Hub.ExternalRef = ""
+	}
+	result.ChangeRoutines, _ = cloneChangeRoutines(result.ChangeRoutines)
+	rooms := map[string]RoomMsg{}
+	for _, room := range result.Rooms {
+		room.Id = uuid.NewString()
+		room.ChangeRoutines, _ = cloneChangeRoutines(room.ChangeRoutines)
+		if room.Hub != nil {
+			room.Hub.ExternalRef = ""
+		}
+		devices := map[string]DeviceMsg{}
+		for _, device := range room.Devices {
+			device, err = cloneDeviceMsg(device, externalRef)
+			if err != nil {
+				return result, err
+			}
+			devices[device.Id] = device
+		}
+		room.Devices = devices
+		rooms[room.Id] = room
+	}
+	result.Rooms = rooms
+	return result, nil
+}
+
+// expects a device which is not shared with other references (e.g. by jsonCopy)
+func cloneDeviceMsg(device DeviceMsg, externalRef func(device DeviceMsg) (string, error)) (result DeviceMsg, err error) {
+	result = device
+	result.ExternalRef, err = externalRef(device)
+	if err != nil {
+		return result, err
+	}
+	result.Id = uuid.NewString()
+	var routineIds map[string]string
+	result.ChangeRoutines, routineIds = cloneChangeRoutines(device.ChangeRoutines)
+	if device.BlueprintRoutines != nil {
+		result.BlueprintRoutines = []string{}
+		for _, id := range device.BlueprintRoutines {
+			result.BlueprintRoutines = append(result.BlueprintRoutines, routineIds[id])
+		}
+	}
+	result.Services = map[string]Service{}
+	serviceFaults := map[string]FaultProfile{}
+	for _, service := range device.Services {
+		oldId := service.Id
+		service.Id = uuid.NewString()
+		result.Services[service.Id] = service
+		if profile, ok := device.ServiceFaults[oldId]; ok {
+			serviceFaults[service.Id] = profile
+		}
+	}
+	if len(device.ServiceFaults) > 0 {
+		result.ServiceFaults = serviceFaults
+	}
+	return result, nil
+}
+
+// returns the copied routines and the new routine ids by old id
+func cloneChangeRoutines(routines map[string]ChangeRoutine) (result map[string]ChangeRoutine, ids map[string]string) {
+	ids = map[string]string{}
+	if routines == nil {
+		return nil, ids
+	}
+	result = map[string]ChangeRoutine{}
+	for oldId, routine := range routines {
+		routine.Id = uuid.NewString()
+		ids[oldId] = routine.Id
+		result[routine.Id] = routine
+	}
+	return result, ids
+}
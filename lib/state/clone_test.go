@@ -0,0 +1,93 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"testing"
+)
+
+func TestCloneWorldMsg(t *testing.T) {
+	world := WorldMsg{
+		Id:             "w",
+		Name:           "world",
+		States:         map[string]interface{}{"temperature": 20},
+		ChangeRoutines: map[string]ChangeRoutine{"wr": {Id: "wr", Interval: 10, Code: "code"}},
+		Rooms: map[string]RoomMsg{
+			"r": {
+				Id:   "r",
+				Name: "room",
+				Devices: map[string]DeviceMsg{
+					"d": {
+						Id:             "d",
+						Name:           "device",
+						ExternalRef:    "ext",
+						ExternalTypeId: "type",
+						ChangeRoutines: map[string]ChangeRoutine{"dr": {Id: "dr", Interval: 10}},
+						Services:       map[string]Service{"s": {Id: "s", Name: "service", ExternalRef: "ext-service"}},
+						ServiceFaults:  map[string]FaultProfile{"s": {DropRate: 0.5}},
+					},
+				},
+			},
+		},
+	}
+	result, err := cloneWorldMsg(world, func(device DeviceMsg) (string, error) {
+		return device.ExternalRef + "-clone", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Id == world.Id || result.Name != world.Name || result.States["temperature"] != float64(20) {
+		t.Error(result)
+	}
+	for id, routine := range result.ChangeRoutines {
+		if id == "wr" || routine.Id != id || routine.Code != "code" {
+			t.Error(result.ChangeRoutines)
+		}
+	}
+	if len(result.Rooms) != 1 {
+		t.Fatal(result.Rooms)
+	}
+	for roomId, room := range result.Rooms {
+		if roomId == "r" || room.Id != roomId || len(room.Devices) != 1 {
+			t.Fatal(room)
+		}
+		for deviceId, device := range room.Devices {
+			if deviceId == "d" || device.Id != deviceId || device.ExternalRef != "ext-clone" || device.ExternalTypeId != "type" {
+				t.Error(device)
+			}
+			for id := range device.ChangeRoutines {
+				if id == "dr" {
+					t.Error(device.ChangeRoutines)
+				}
+			}
+			if len(device.Services) != 1 {
+				t.Fatal(device.Services)
+			}
+			for serviceId, service := range device.Services {
+				if serviceId == "s" || service.Id != serviceId || service.ExternalRef != "ext-service" {
+					t.Error(service)
+				}
+				if device.ServiceFaults[serviceId].DropRate != 0.5 {
+					t.Error(device.ServiceFaults)
+				}
+			}
+		}
+	}
+	if world.Rooms["r"].Devices["d"].Services["s"].Id != "s" {
+		t.Error("original world was changed")
+	}
+}
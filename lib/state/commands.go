@@ -0,0 +1,132 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/google/uuid"
+)
+
+const defaultCommandHistorySize = 100
+
+// CommandRecord describes a command received by a device service and its response
+type CommandRecord struct {
+	Id       string      `json:"id" bson:"id"`
+	Device   string      `json:"device" bson:"device"`
+	Service  string      `json:"service" bson:"service"`
+	Time     time.Time   `json:"time" bson:"time"`
+	Input    interface{} `json:"input" bson:"input"`
+	Response interface{} `json:"response" bson:"response"`
+	Duration int64       `json:"duration" bson:"duration"` //milliseconds until the response or until the end of the js run if no response has been sent
+	Error    string      `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+// empty fields are ignored
+type CommandHistoryQuery struct {
+	Device  string
+	Service string
+	From    time.Time
+	To      time.Time
+	Limit   int //defaults to the command history size
+}
+
+// records a single command; responses sent after the js run (e.g. by moses.service.sendLater()) update the persisted record
+type commandRecorder struct {
+	repo      *StateRepo
+	mux       sync.Mutex
+	record    CommandRecord
+	responded bool
+	finished  bool
+}
+
+func (this *StateRepo) getCommandHistorySize() int {
+	if this.Config.CommandHistorySize > 0 {
+		return int(this.Config.CommandHistorySize)
+	}
+	return defaultCommandHistorySize
+}
+
+func (this *StateRepo) newCommandRecorder(device *Device, service Service, input interface{}) *commandRecorder {
+	return &commandRecorder{repo: this, record: CommandRecord{
+		Id:      uuid.NewString(),
+		Device:  device.Id,
+		Service: service.Id,
+		Time:    time.Now(),
+		Input:   input,
+	}}
+}
+
+// returns a responder which records the response before passing it to responder
+func (this *commandRecorder) wrap(responder func(respMsg interface{})) func(respMsg interface{}) {
+	return func(respMsg interface{}) {
+		this.mux.Lock()
+		if !this.responded {
+			this.responded = true
+			this.record.Response = respMsg
+			this.record.Duration = time.Since(this.record.Time).Milliseconds()
+			if this.finished {
+				this.persist()
+			}
+		}
+		this.mux.Unlock()
+		responder(respMsg)
+	}
+}
+
+// called after the js run of the command
+func (this *commandRecorder) finish(err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.finished = true
+	if err != nil {
+		this.record.Error = err.Error()
+	}
+	if !this.responded {
+		this.record.Duration = time.Since(this.record.Time).Milliseconds()
+	}
+	this.persist()
+}
+
+// expects a locked recorder
+func (this *commandRecorder) persist() {
+	if this.repo.Persistence == nil {
+		return
+	}
+	err := this.repo.Persistence.PersistCommandRecord(this.record, this.repo.getCommandHistorySize())
+	if err != nil {
+		log.Println("ERROR: unable to persist command record", this.record.Device, this.record.Service, err)
+	}
+}
+
+func (this *StateRepo) ReadCommandHistory(jwt jwt.Jwt, query CommandHistoryQuery) (result []CommandRecord, access bool, exists bool, err error) {
+	_, access, exists, err = this.ReadDevice(jwt, query.Device)
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	if query.Limit <= 0 || query.Limit > this.getCommandHistorySize() {
+		query.Limit = this.getCommandHistorySize()
+	}
+	result, err = this.Persistence.GetCommandRecords(query)
+	if result == nil {
+		result = []CommandRecord{}
+	}
+	return result, true, true, err
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type commandRecordPersistence struct {
+	nopPersistence
+	mux     sync.Mutex
+	records map[string]CommandRecord
+}
+
+func (this *commandRecordPersistence) PersistCommandRecord(record CommandRecord, historySize int) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.records[record.Id] = record
+	return nil
+}
+
+func (this *commandRecordPersistence) get(id string) CommandRecord {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return this.records[id]
+}
+
+func TestCommandRecorder(t *testing.T) {
+	persistence := &commandRecordPersistence{records: map[string]CommandRecord{}}
+	repo := &StateRepo{Persistence: persistence}
+	responses := []interface{}{}
+	recorder := repo.newCommandRecorder(&Device{Id: "d"}, Service{Id: "s"}, map[string]interface{}{"on": true})
+	responder := recorder.wrap(func(respMsg interface{}) {
+		responses = append(responses, respMsg)
+	})
+	responder("ok")
+	responder("ignored")
+	recorder.finish(nil)
+	record := persistence.get(recorder.record.Id)
+	if record.Device != "d" || record.Service != "s" || record.Response != "ok" || record.Error != "" || record.Input.(map[string]interface{})["on"] != true {
+		t.Error(record)
+	}
+	if len(responses) != 2 {
+		t.Error(responses)
+	}
+}
+
+func TestCommandRecorderLateResponse(t *testing.T) {
+	persistence := &commandRecordPersistence{records: map[string]CommandRecord{}}
+	repo := &StateRepo{Persistence: persistence}
+	recorder := repo.newCommandRecorder(&Device{Id: "d"}, Service{Id: "s"}, nil)
+	responder := recorder.wrap(func(respMsg interface{}) {})
+	recorder.finish(errors.New("timeout"))
+	record := persistence.get(recorder.record.Id)
+	if record.Response != nil || record.Error != "timeout" {
+		t.Error(record)
+	}
+	time.Sleep(10 * time.Millisecond)
+	responder(42)
+	record = persistence.get(recorder.record.Id)
+	if record.Response != 42 || record.Error != "timeout" || record.Duration < 10 {
+		t.Error(record)
+	}
+}
@@ -0,0 +1,211 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"errors"
+	"log"
+
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+)
+
+type DeviceConnectionRequest struct {
+	Device string `json:"device"`
+	Online bool   `json:"online"`
+}
+
+// logs the current connection state of the device, if it differs from the last logged state
+// devices behind an offline hub are logged as disconnected
+// expects the world of the device to be locked
+func (this *StateRepo) logDeviceConnection(world *World, device *Device) {
+	if device.ExternalRef == "" {
+		return
+	}
+	online := isDeviceReachable(world, device)
+	this.connectionMux.Lock()
+	defer this.connectionMux.Unlock()
+	if this.connectedDevices == nil {
+		this.connectedDevices = map[string]bool{}
+	}
+	if known, ok := this.connectedDevices[device.ExternalRef]; ok && known == online {
+		return
+	}
+	var err error
+	if online {
+		err = this.StateLogger.LogDeviceConnect(device.ExternalRef)
+	} else {
+		err = this.StateLogger.LogDeviceDisconnect(device.ExternalRef)
+	}
+	if err != nil {
+		log.Println("WARNING: unable to log device connection state", device.ExternalRef, online, err)
+		return
+	}
+	this.connectedDevices[device.ExternalRef] = online
+}
+
+// logs the current connection state of the hub, if it differs from the last logged state
+// expects the world of the hub to be locked
+func (this *StateRepo) logHubConnection(hub *Hub) {
+	if hub.ExternalRef == "" {
+		return
+	}
+	online := !hub.Offline
+	this.connectionMux.Lock()
+	defer this.connectionMux.Unlock()
+	if this.connectedHubs == nil {
+		this.connectedHubs = map[string]bool{}
+	}
+	if known, ok := this.connectedHubs[hub.ExternalRef]; ok && known == online {
+		return
+	}
+	var err error
+	if online {
+		err = this.StateLogger.LogHubConnect(hub.ExternalRef)
+	} else {
+		err = this.StateLogger.LogHubDisconnect(hub.ExternalRef)
+	}
+	if err != nil {
+		log.Println("WARNING: unable to log hub connection state", hub.ExternalRef, online, err)
+		return
+	}
+	this.connectedHubs[hub.ExternalRef] = online
+}
+
+// logs all hubs as disconnected which are logged as connected but are no longer part of a world
+func (this *StateRepo) logRemovedHubsDisconnected() {
+	current := map[string]bool{}
+	for _, world := range this.Worlds {
+		if world.Hub != nil {
+			current[world.Hub.ExternalRef] = true
+		}
+		for _, room := range world.Rooms {
+			if room.Hub != nil {
+				current[room.Hub.ExternalRef] = true
+			}
+		}
+	}
+	this.connectionMux.Lock()
+	defer this.connectionMux.Unlock()
+	for ref, online := range this.connectedHubs {
+		if current[ref] {
+			continue
+		}
+		if online {
+			err := this.StateLogger.LogHubDisconnect(ref)
+			if err != nil {
+				log.Println("WARNING: unable to log hub as offline", ref, err)
+				continue
+			}
+		}
+		delete(this.connectedHubs, ref)
+	}
+}
+
+// logs all devices as disconnected which are logged as connected but are no longer part of a world
+// expects to be called after the externalRefDeviceIndex has been populated
+func (this *StateRepo) logRemovedDevicesDisconnected() {
+	this.connectionMux.Lock()
+	defer this.connectionMux.Unlock()
+	for ref, online := range this.connectedDevices {
+		if _, ok := this.externalRefDeviceIndex[ref]; ok {
+			continue
+		}
+		if online {
+			err := this.StateLogger.LogDeviceDisconnect(ref)
+			if err != nil {
+				log.Println("WARNING: unable to log device as offline", ref, err)
+				continue
+			}
+		}
+		delete(this.connectedDevices, ref)
+	}
+}
+
+// logs all known devices and hubs as disconnected; used on shutdown
+func (this *StateRepo) logAllDevicesDisconnected() {
+	this.connectionMux.Lock()
+	defer this.connectionMux.Unlock()
+	for ref, online := range this.connectedDevices {
+		if online {
+			err := this.StateLogger.LogDeviceDisconnect(ref)
+			if err != nil {
+				log.Println("WARNING: unable to log device as offline", ref, err)
+			}
+		}
+	}
+	this.connectedDevices = nil
+	for ref, online := range this.connectedHubs {
+		if online {
+			err := this.StateLogger.LogHubDisconnect(ref)
+			if err != nil {
+				log.Println("WARNING: unable to log hub as offline", ref, err)
+			}
+		}
+	}
+	this.connectedHubs = nil
+}
+
+// sets the device online or offline without restarting change routines
+// expects the world of the device to be locked
+func (this *StateRepo) setDeviceOnline(world *World, device *Device, online bool) (err error) {
+	device.Offline = !online
+	this.logDeviceConnection(world, device)
+	if world == nil {
+		return nil
+	}
+	return this.persistWorld(*world)
+}
+
+func (this *StateRepo) SetDeviceOnline(jwt jwt.Jwt, msg DeviceConnectionRequest) (device DeviceResponse, access bool, exists bool, err error) {
+	this.mux.RLock()
+	defer this.mux.RUnlock()
+	world, exists := this.deviceWorldIndex[msg.Device]
+	if !exists {
+		return device, false, exists, nil
+	}
+	world.mux.Lock()
+	defer world.mux.Unlock()
+	if world.Owner != jwt.UserId {
+		return device, false, exists, nil
+	}
+	room, exists := this.deviceRoomIndex[msg.Device]
+	if !exists {
+		return device, false, exists, errors.New("inconsistent deviceRoomIndex")
+	}
+	devicep := room.Devices[msg.Device]
+	err = this.setDeviceOnline(world, devicep, msg.Online)
+	if err != nil {
+		return device, true, true, err
+	}
+	device.World = world.Id
+	device.Room = room.Id
+	device.Device, err = devicep.ToMsg()
+	device.Status = this.getDeviceStatus(world, devicep)
+	return device, true, true, err
+}
+
+// stops all change routines and scenarios and logs all devices as disconnected
+func (this *StateRepo) Shutdown() (err error) {
+	this.stopAllScenarios()
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	err = this.Stop()
+	this.pendingResponses.cancelAll()
+	this.webhookQueues.stopAll()
+	this.logAllDevicesDisconnected()
+	return err
+}
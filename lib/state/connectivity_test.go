@@ -0,0 +1,141 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+)
+
+func getConnectivityTestRepo(recorder *connectionRecorder, sensorData chan interface{}) *StateRepo {
+	return &StateRepo{
+		Config:      config.Config{JsTimeout: 2 * time.Second},
+		Persistence: nopPersistence{},
+		StateLogger: recorder,
+		Worlds: map[string]*World{"w": {Id: "w", Owner: "user", mux: &sync.Mutex{}, Rooms: map[string]*Room{"r": {Id: "r", Devices: map[string]*Device{
+			"d": {Id: "d", ExternalRef: "d_ref", States: map[string]interface{}{}, Services: map[string]Service{
+				"sensor":   {Id: "sensor", ExternalRef: "sensor_ref", Code: `moses.service.send({"value": 1});`},
+				"actuator": {Id: "actuator", ExternalRef: "actuator_ref", Code: `moses.service.send({"ok": true});`},
+			}},
+		}}}}},
+		sensorDataHandler: func(device *Device, service Service, value interface{}) {
+			sensorData <- value
+		},
+	}
+}
+
+func TestDeviceConnectionLogging(t *testing.T) {
+	recorder := &connectionRecorder{}
+	repo := getConnectivityTestRepo(recorder, make(chan interface{}, 10))
+	repo.Start()
+
+	_, _, _, err := repo.SetDeviceOnline(jwt.Jwt{UserId: "user"}, DeviceConnectionRequest{Device: "d", Online: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, _, err = repo.SetDeviceOnline(jwt.Jwt{UserId: "user"}, DeviceConnectionRequest{Device: "d", Online: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	world := repo.Worlds["w"]
+	err = run(`moses.device.setOnline(true)`, repo.getJsDeviceApi(world, world.Rooms["r"], world.Rooms["r"].Devices["d"]), time.Second, world.mux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.Shutdown()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"device connect d_ref", "device disconnect d_ref", "device connect d_ref", "device disconnect d_ref"}
+	if !sameEntries(recorder.get(), expected) || recorder.get()[1] != "device disconnect d_ref" {
+		t.Error(recorder.get())
+	}
+}
+
+func TestOfflineDeviceSuppression(t *testing.T) {
+	sensorData := make(chan interface{}, 10)
+	repo := getConnectivityTestRepo(&connectionRecorder{}, sensorData)
+	repo.Start()
+	defer repo.Shutdown()
+
+	_, _, _, err := repo.SetDeviceOnline(jwt.Jwt{UserId: "user"}, DeviceConnectionRequest{Device: "d", Online: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	responses := make(chan interface{}, 10)
+	repo.HandleCommand("d_ref", "actuator_ref", map[string]interface{}{}, func(respMsg interface{}) {
+		responses <- respMsg
+	})
+	if len(responses) != 0 {
+		t.Error("offline device handled command")
+	}
+	_, err = repo.RunService("actuator", nil)
+	if err == nil {
+		t.Error("expected error for offline device")
+	}
+	world := repo.Worlds["w"]
+	device := world.Rooms["r"].Devices["d"]
+	err = run(device.Services["sensor"].Code, repo.getJsSensorApi(world, world.Rooms["r"], device, device.Services["sensor"]), time.Second, world.mux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sensorData) != 0 {
+		t.Error("offline device sent sensor data")
+	}
+
+	_, _, _, err = repo.SetDeviceOnline(jwt.Jwt{UserId: "user"}, DeviceConnectionRequest{Device: "d", Online: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.HandleCommand("d_ref", "actuator_ref", map[string]interface{}{}, func(respMsg interface{}) {
+		responses <- respMsg
+	})
+	if len(responses) != 1 {
+		t.Error("online device did not handle command")
+	}
+	err = run(device.Services["sensor"].Code, repo.getJsSensorApi(world, world.Rooms["r"], device, device.Services["sensor"]), time.Second, world.mux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sensorData) != 1 {
+		t.Error("online device did not send sensor data")
+	}
+}
+
+func TestConcurrentSetOnlineAndCommands(t *testing.T) {
+	repo := getConnectivityTestRepo(&connectionRecorder{}, make(chan interface{}, 10))
+	repo.Start()
+	defer repo.Shutdown()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(online bool) {
+			defer wg.Done()
+			repo.SetDeviceOnline(jwt.Jwt{UserId: "user"}, DeviceConnectionRequest{Device: "d", Online: online})
+		}(i%2 == 0)
+		go func() {
+			defer wg.Done()
+			repo.HandleCommand("d_ref", "actuator_ref", nil, func(respMsg interface{}) {})
+			repo.RunService("actuator", nil)
+		}()
+	}
+	wg.Wait()
+}
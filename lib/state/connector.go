@@ -0,0 +1,107 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+)
+
+// values of config.Mode
+const (
+	ModePlatform   = "platform"   //default; devices, device types and events are handled by the platform
+	ModeStandalone = "standalone" //devices and device types are kept in a local registry; no kafka, keycloak or device manager needed
+)
+
+// PlatformConnector encapsulates all access of moses to the platform
+// tokens are authorization header values like "Bearer <jwt>"
+type PlatformConnector interface {
+	// returns a token of moses itself
+	Access() (token jwt.JwtImpersonate, err error)
+	// returns the id of the protocol with the given handler; the protocol is created if it does not exist
+	EnsureProtocol(handler string, segments []model.ProtocolSegment) (protocol model.Protocol, err error)
+
+	GetDeviceType(token jwt.JwtImpersonate, id string) (deviceType model.DeviceType, err error)
+	ListDeviceTypes(token jwt.JwtImpersonate) (deviceTypes []model.DeviceType, err error)
+	ListDeviceTypeIds() (ids []string, err error)
+	ListProtocolDeviceTypeIds(protocolId string) (ids []string, err error)
+	GetCharacteristic(token jwt.JwtImpersonate, id string) (characteristic model.Characteristic, err error)
+
+	CreateDevice(token jwt.JwtImpersonate, device model.Device) (result model.Device, err error)
+	GetDevice(token jwt.JwtImpersonate, id string) (device model.Device, err error)
+	// checks read and execute permissions of the device
+	CheckDevicePermission(token jwt.JwtImpersonate, id string) (access bool, exists bool, err error)
+	DeleteDevice(token jwt.JwtImpersonate, id string) (err error)
+
+	CreateHub(token jwt.JwtImpersonate, hub model.Hub) (result model.Hub, err error)
+	UpdateHub(token jwt.JwtImpersonate, hub model.Hub) (result model.Hub, err error)
+	DeleteHub(token jwt.JwtImpersonate, id string) (err error)
+
+	// lookups of devices and device types which distinguish missing elements from errors; used to sync worlds
+	LookupDevice(token jwt.JwtImpersonate, id string) (device model.Device, exists bool, err error)
+	LookupDeviceType(token jwt.JwtImpersonate, id string) (deviceType model.DeviceType, exists bool, err error)
+
+	// sends sensor data of the device service
+	SendEvent(deviceRef string, serviceRef string, value interface{}) (err error)
+	// handler is called for every command to a device service and may respond multiple times
+	SetCommandHandler(handler func(deviceRef string, serviceRef string, cmdMsg interface{}, responder func(respMsg interface{})))
+}
+
+func (this *StateRepo) GetIotDeviceType(jwt jwt.Jwt, id string) (dt model.DeviceType, err error) {
+	return this.Connector.GetDeviceType(jwt.Impersonate, id)
+}
+
+func (this *StateRepo) GetIotCharacteristic(jwt jwt.Jwt, id string) (characteristic model.Characteristic, err error) {
+	return this.Connector.GetCharacteristic(jwt.Impersonate, id)
+}
+
+func (this *StateRepo) GetIotDeviceTypes(jwt jwt.Jwt) (result []model.DeviceType, err error) {
+	return this.Connector.ListDeviceTypes(jwt.Impersonate)
+}
+
+func (this *StateRepo) GetIotDeviceTypesIds(jwt jwt.Jwt) (result []string, err error) {
+	return this.Connector.ListDeviceTypeIds()
+}
+
+func (this *StateRepo) GetMosesDeviceTypesIds(jwt jwt.Jwt) (result []string, err error) {
+	return this.Connector.ListProtocolDeviceTypeIds(this.MosesProtocolId)
+}
+
+func (this *StateRepo) GenerateExternalDevice(jwt jwt.Jwt, request CreateDeviceByTypeRequest) (device model.Device, err error) {
+	return this.Connector.CreateDevice(jwt.Impersonate, model.Device{Name: request.Name, DeviceTypeId: request.DeviceTypeId})
+}
+
+// returns the platform device if the user may read and execute it
+func (this *StateRepo) GetExternalDevice(jwt jwt.Jwt, id string) (device model.Device, access bool, exists bool, err error) {
+	access, exists, err = this.Connector.CheckDevicePermission(jwt.Impersonate, id)
+	if err != nil || !access || !exists {
+		return device, access, exists, err
+	}
+	device, err = this.Connector.GetDevice(jwt.Impersonate, id)
+	return device, true, true, err
+}
+
+func (this *StateRepo) DeleteExternalDevice(jwt jwt.Jwt, id string) (err error) {
+	if id != "" {
+		err = this.Connector.DeleteDevice(jwt.Impersonate, id)
+	}
+	return
+}
+
+func (this *StateRepo) EnsureProtocol(handler string, segments []model.ProtocolSegment) (protocol model.Protocol, err error) {
+	return this.Connector.EnsureProtocol(handler, segments)
+}
@@ -21,11 +21,9 @@ import (
 	"errors"
 	"github.com/SENERGY-Platform/moses/lib/jwt"
 	"github.com/SENERGY-Platform/platform-connector-lib/model"
-	"github.com/cbroglie/mustache"
 	"github.com/globalsign/mgo"
 	"github.com/google/uuid"
 	"log"
-	"strings"
 )
 
 func (this *StateRepo) ReadWorlds(jwt jwt.Jwt) (worlds []WorldMsg, err error) {
@@ -37,6 +35,7 @@ func (this *StateRepo) ReadWorlds(jwt jwt.Jwt) (worlds []WorldMsg, err error) {
 			if err != nil {
 				return worlds, err
 			}
+			msg.Webhooks = maskWebhookSecrets(msg.Webhooks)
 			worlds = append(worlds, msg)
 		}
 	}
@@ -53,6 +52,7 @@ func (this *StateRepo) CreateWorld(jwt jwt.Jwt, msg CreateWorldRequest) (world W
 	return
 }
 
+// webhook secrets are masked; DevUpdateWorld keeps the current secrets if the world is written back
 func (this *StateRepo) ReadWorld(jwt jwt.Jwt, id string) (world WorldMsg, access bool, exists bool, err error) {
 	world, exists, err = this.DevGetWorld(id)
 	if err != nil || !exists {
@@ -61,6 +61,7 @@ func (this *StateRepo) ReadWorld(jwt jwt.Jwt, id string) (world WorldMsg, access
 	if world.Owner != jwt.UserId {
 		return WorldMsg{}, false, exists, err
 	}
+	world.Webhooks = maskWebhookSecrets(world.Webhooks)
 	return world, true, true, err
 }
 
@@ -88,6 +89,10 @@ func (this *StateRepo) DeleteWorld(jwt jwt.Jwt, id string) (access bool, exists
 		return false, exists, err
 	}
 	err = this.DevDeleteWorld(id)
+	if err != nil {
+		return true, exists, err
+	}
+	err = this.Persistence.DeleteWorldSnapshots(id)
 	return true, exists, err
 }
 
@@ -162,6 +167,9 @@ func (this *StateRepo) DeleteRoom(jwt jwt.Jwt, id string) (room RoomResponse, ac
 	}
 	delete(world.Rooms, room.Room.Id)
 	err = this.DevUpdateWorld(world)
+	if err == nil {
+		this.refreshHubs(jwt, room.World)
+	}
 	return room, true, exists, err
 }
 
@@ -186,6 +194,7 @@ func (this *StateRepo) ReadDevice(jwt jwt.Jwt, id string) (device DeviceResponse
 	device.World = world.Id
 	device.Room = room.Id
 	device.Device, err = room.Devices[id].ToMsg()
+	device.Status = this.getDeviceStatus(world, room.Devices[id])
 	return device, true, true, err
 }
 
@@ -207,6 +216,9 @@ func (this *StateRepo) CreateDevice(jwt jwt.Jwt, msg CreateDeviceRequest) (devic
 	device.Room = msg.Room
 	device.Device.ChangeRoutines = map[string]ChangeRoutine{}
 	err = this.DevUpdateDevice(device.World, device.Room, device.Device)
+	if err == nil {
+		this.refreshHubs(jwt, device.World)
+	}
 	return device, true, true, err
 }
 
@@ -229,9 +241,13 @@ func (this *StateRepo) UpdateDevice(jwt jwt.Jwt, msg UpdateDeviceRequest) (devic
 		}
 	}
 	err = this.DevUpdateDevice(device.World, device.Room, device.Device)
+	if err == nil {
+		this.refreshHubs(jwt, device.World)
+	}
 	return device, true, true, err
 }
 
+// the platform device is kept; use DeleteDeviceCascade to delete it as well
 func (this *StateRepo) DeleteDevice(jwt jwt.Jwt, id string) (device DeviceResponse, access bool, exists bool, err error) {
 	device, access, exists, err = this.ReadDevice(jwt, id)
 	if err != nil || !access || !exists {
@@ -249,7 +265,7 @@ func (this *StateRepo) DeleteDevice(jwt jwt.Jwt, id string) (device DeviceRespon
 	delete(world.Rooms[device.Room].Devices, device.Device.Id)
 	err = this.DevUpdateWorld(world) //update world is more efficient than update room
 	if err == nil {
-		this.DeleteExternalDevice(jwt, device.Device.ExternalRef)
+		this.refreshHubs(jwt, device.World)
 	}
 	return device, true, true, err
 }
@@ -367,7 +383,7 @@ func (this *StateRepo) CreateDeviceByType(jwt jwt.Jwt, msg CreateDeviceByTypeReq
 	if err != nil || !access || !worldAndExists {
 		return result, access, worldAndExists, err
 	}
-	services, err := this.prepareServices(jwt, msg.DeviceTypeId)
+	services, states, err := this.prepareServices(jwt, msg.DeviceTypeId)
 	if err != nil {
 		return result, access, worldAndExists, err
 	}
@@ -386,58 +402,107 @@ func (this *StateRepo) CreateDeviceByType(jwt jwt.Jwt, msg CreateDeviceByTypeReq
 	result.World = room.World
 	result.Room = msg.Room
 	result.Device.Services = services
+	result.Device.States = states
 	err = this.DevUpdateDevice(result.World, result.Room, result.Device)
+	if err == nil {
+		this.refreshHubs(jwt, result.World)
+	}
 	return result, true, true, err
 }
 
-func (this *StateRepo) prepareServices(jwt jwt.Jwt, deviceTypeId string) (result map[string]Service, err error) {
+// links an existing platform device to a new moses device; nothing is created in the platform
+func (this *StateRepo) AdoptDevice(jwt jwt.Jwt, msg AdoptDeviceRequest) (result DeviceResponse, access bool, exists bool, err error) {
+	room, access, exists, err := this.ReadRoom(jwt, msg.Room)
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	externalDevice, access, exists, err := this.GetExternalDevice(jwt, msg.ExternalId)
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	if id, ok := this.getDeviceIdByExternalRef(externalDevice.Id); ok {
+		return result, true, true, errors.New("platform device is already used by moses device " + id)
+	}
+	services, states, err := this.prepareServices(jwt, externalDevice.DeviceTypeId)
+	if err != nil {
+		return result, true, true, err
+	}
+	result.Device.Id = uuid.NewString()
+	result.Device.Name = msg.Name
+	if result.Device.Name == "" {
+		result.Device.Name = externalDevice.Name
+	}
+	result.Device.ExternalTypeId = externalDevice.DeviceTypeId
+	result.Device.ExternalRef = externalDevice.Id
+	result.World = room.World
+	result.Room = msg.Room
+	result.Device.Services = services
+	result.Device.States = states
+	err = this.DevUpdateDevice(result.World, result.Room, result.Device)
+	if err == nil {
+		this.refreshHubs(jwt, result.World)
+	}
+	return result, true, true, err
+}
+
+func (this *StateRepo) getDeviceIdByExternalRef(externalRef string) (id string, ok bool) {
+	this.mux.RLock()
+	defer this.mux.RUnlock()
+	device, ok := this.externalRefDeviceIndex[externalRef]
+	if !ok {
+		return "", false
+	}
+	return device.Id, true
+}
+
+// returns services with generated code for all services of the device type and the initial device states used by the code
+func (this *StateRepo) prepareServices(jwt jwt.Jwt, deviceTypeId string) (result map[string]Service, states map[string]interface{}, err error) {
 	result = map[string]Service{}
+	states = map[string]interface{}{}
 	devicetype, err := this.GetIotDeviceType(jwt, deviceTypeId)
 	if err != nil {
-		return result, err
+		return result, states, err
 	}
+	characteristics := this.getCharacteristicLookup(jwt)
 	for _, externalService := range devicetype.Services {
 		uid, err := uuid.NewRandom()
 		if err != nil {
-			return result, err
+			return result, states, err
 		}
 		service := Service{Id: uid.String(), Name: externalService.Name, ExternalRef: externalService.Id}
-		service.Code, err = this.createServiceCodeSkeleton(externalService)
+		var serviceStates map[string]interface{}
+		service.Code, serviceStates, err = createServiceCode(externalService, characteristics, this.getSegmentLookup())
 		if err != nil {
-			return result, err
+			return result, states, err
+		}
+		for key, value := range serviceStates {
+			if _, ok := states[key]; !ok {
+				states[key] = value
+			}
 		}
 		result[service.Id] = service
 	}
-	return result, err
+	return result, states, err
 }
 
-func (this *StateRepo) createServiceCodeSkeleton(service model.Service) (result string, err error) {
-	templateParamer := map[string]string{}
-	if len(service.Outputs) > 0 {
-		output := service.Outputs[0]
-		formatedOutput, err := inputOutputSkeletonString(output.ContentVariable)
-		if err != nil {
-			return result, err
+// returns a cached lookup of characteristics; unknown characteristics are logged and not retried
+func (this *StateRepo) getCharacteristicLookup(jwt jwt.Jwt) characteristicLookup {
+	cache := map[string]*model.Characteristic{}
+	return func(id string) (model.Characteristic, bool) {
+		if characteristic, ok := cache[id]; ok {
+			if characteristic == nil {
+				return model.Characteristic{}, false
+			}
+			return *characteristic, true
 		}
-		templateParamer["output"] = strings.TrimSpace(formatedOutput)
-	}
-
-	if len(service.Inputs) > 0 {
-		input := service.Inputs[0]
-		formatedInput, err := inputOutputSkeletonString(input.ContentVariable)
+		characteristic, err := this.GetIotCharacteristic(jwt, id)
 		if err != nil {
-			return result, err
+			cache[id] = nil
+			return characteristic, false
 		}
-		templateParamer["input"] = strings.TrimSpace(formatedInput)
+		cache[id] = &characteristic
+		return characteristic, true
 	}
-
-	template := `{{#input}}/*
-{{{input}}}
-*/
-var input = moses.service.input; 
-{{/input}}{{#output}}var output = {{{output}}};
-moses.service.send(output);{{/output}}`
-	return mustache.Render(template, templateParamer)
 }
 
 func (this *StateRepo) CreateChangeRoutine(jwt jwt.Jwt, msg CreateChangeRoutineRequest) (result ChangeRoutineResponse, access bool, exists bool, err error) {
@@ -445,8 +510,8 @@ func (this *StateRepo) CreateChangeRoutine(jwt jwt.Jwt, msg CreateChangeRoutineR
 	if err != nil {
 		return result, access, exists, err
 	}
-	routine := ChangeRoutine{Interval: msg.Interval, Code: msg.Code, Id: uid.String()}
-	result = ChangeRoutineResponse{Id: routine.Id, Code: routine.Code, Interval: routine.Interval, RefId: msg.RefId, RefType: msg.RefType}
+	routine := ChangeRoutine{Interval: msg.Interval, Code: msg.Code, Id: uid.String(), Template: msg.Template}
+	result = ChangeRoutineResponse{Id: routine.Id, Code: routine.Code, Interval: routine.Interval, RefId: msg.RefId, RefType: msg.RefType, Template: routine.Template}
 	switch msg.RefType {
 	case "world":
 		world, access, exists, err := this.ReadWorld(jwt, msg.RefId)
@@ -489,9 +554,11 @@ func (this *StateRepo) UpdateChangeRoutine(jwt jwt.Jwt, msg UpdateChangeRoutineR
 	if err != nil || !access || !exists {
 		return routine, access, exists, err
 	}
-	changeRoutine := ChangeRoutine{Interval: msg.Interval, Code: msg.Code, Id: msg.Id}
+	changeRoutine := ChangeRoutine{Interval: msg.Interval, Code: msg.Code, Id: msg.Id, Disabled: msg.Disabled, Template: msg.Template}
 	routine.Code = changeRoutine.Code
 	routine.Interval = changeRoutine.Interval
+	routine.Disabled = changeRoutine.Disabled
+	routine.Template = changeRoutine.Template
 	switch routine.RefType {
 	case "world":
 		world, access, exists, err := this.ReadWorld(jwt, routine.RefId)
@@ -547,6 +614,8 @@ func (this *StateRepo) ReadChangeRoutine(jwt jwt.Jwt, id string) (routine Change
 		}
 		routine.Code = worldRoutine.Code
 		routine.Interval = worldRoutine.Interval
+		routine.Disabled = worldRoutine.Disabled
+		routine.Template = worldRoutine.Template
 	case "room":
 		room, access, exists, err := this.ReadRoom(jwt, routine.RefId)
 		if err != nil || !access || !exists {
@@ -558,6 +627,8 @@ func (this *StateRepo) ReadChangeRoutine(jwt jwt.Jwt, id string) (routine Change
 		}
 		routine.Code = roomRoutine.Code
 		routine.Interval = roomRoutine.Interval
+		routine.Disabled = roomRoutine.Disabled
+		routine.Template = roomRoutine.Template
 	case "device":
 		device, access, exists, err := this.ReadDevice(jwt, routine.RefId)
 		if err != nil || !access || !exists {
@@ -569,6 +640,8 @@ func (this *StateRepo) ReadChangeRoutine(jwt jwt.Jwt, id string) (routine Change
 		}
 		routine.Code = deviceRoutine.Code
 		routine.Interval = deviceRoutine.Interval
+		routine.Disabled = deviceRoutine.Disabled
+		routine.Template = deviceRoutine.Template
 	default:
 		err = errors.New("unknown ref type")
 	}
@@ -667,12 +740,19 @@ func (this *StateRepo) ReadTemplates(jwt jwt.Jwt) (result []RoutineTemplate, err
 }
 
 func (this *StateRepo) UpdateChangeRoutineByTemplate(jwt jwt.Jwt, msg UpdateChangeRoutineByTemplateRequest) (routine ChangeRoutineResponse, access bool, exists bool, err error) {
+	current, access, exists, err := this.ReadChangeRoutine(jwt, msg.RoutineId)
+	if err != nil || !access || !exists {
+		return routine, access, exists, err
+	}
 	templ, exists, err := this.ReadTemplate(jwt, msg.TemplId)
 	if err != nil || !exists {
 		return routine, true, exists, err
 	}
-	updateRequest := UpdateChangeRoutineRequest{Id: msg.RoutineId, Interval: msg.Interval}
+	updateRequest := UpdateChangeRoutineRequest{Id: msg.RoutineId, Interval: msg.Interval, Template: templ.Id, Disabled: current.Disabled}
 	updateRequest.Code, err = RenderTempl(templ.Template, msg.Parameter)
+	if err != nil {
+		return routine, true, true, err
+	}
 	return this.UpdateChangeRoutine(jwt, updateRequest)
 }
 
@@ -681,7 +761,7 @@ func (this *StateRepo) CreateChangeRoutineByTemplate(jwt jwt.Jwt, msg CreateChan
 	if err != nil || !exists {
 		return routine, true, exists, err
 	}
-	createRequest := CreateChangeRoutineRequest{RefId: msg.RefId, RefType: msg.RefType, Interval: msg.Interval}
+	createRequest := CreateChangeRoutineRequest{RefId: msg.RefId, RefType: msg.RefType, Interval: msg.Interval, Template: templ.Id}
 	createRequest.Code, err = RenderTempl(templ.Template, msg.Parameter)
 	return this.CreateChangeRoutine(jwt, createRequest)
 }
@@ -0,0 +1,242 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+)
+
+const defaultEffectInterval = 10 * time.Second
+
+// DeviceEffect changes a state of the device, its room or its world while the condition on the device states is met
+// e.g. {condition: {state: "on", operator: "==", value: true}, target: "room", target_state: "temperature", rate_per_minute: 0.2, limit_state: "setpoint"}
+type DeviceEffect struct {
+	Condition     EffectCondition `json:"condition" bson:"condition"`
+	Target        string          `json:"target" bson:"target"` // "device" || "room" || "world"
+	TargetState   string          `json:"target_state" bson:"target_state"`
+	RatePerMinute float64         `json:"rate_per_minute" bson:"rate_per_minute"` //negative values decrease the target state
+	LimitState    string          `json:"limit_state" bson:"limit_state"`         //device state limiting the target state; upper limit for positive rates, lower limit for negative rates
+	Limit         *float64        `json:"limit" bson:"limit"`                     //fixed limit; used if LimitState is empty or not a number
+}
+
+// an empty State is always met
+type EffectCondition struct {
+	State    string      `json:"state" bson:"state"`
+	Operator string      `json:"operator" bson:"operator"` // "==" || "!=" || ">" || ">=" || "<" || "<="
+	Value    interface{} `json:"value" bson:"value"`
+}
+
+type DeviceEffectsRequest struct {
+	Device  string         `json:"device"`
+	Effects []DeviceEffect `json:"effects"`
+}
+
+// EffectChange describes a state change caused by an effect
+type EffectChange struct {
+	Device  string      `json:"device"` //device with the effect
+	Effect  int         `json:"effect"` //index of the effect
+	RefType string      `json:"ref_type"`
+	RefId   string      `json:"ref_id"`
+	Key     string      `json:"key"`
+	From    interface{} `json:"from"`
+	To      float64     `json:"to"`
+}
+
+func ValidateDeviceEffects(effects []DeviceEffect) error {
+	for _, effect := range effects {
+		switch effect.Target {
+		case "device", "room", "world":
+		default:
+			return errors.New("unknown effect target: " + effect.Target)
+		}
+		if effect.TargetState == "" {
+			return errors.New("missing effect target_state")
+		}
+		if effect.Condition.State != "" {
+			switch effect.Condition.Operator {
+			case "==", "!=", ">", ">=", "<", "<=":
+			default:
+				return errors.New("unknown effect condition operator: " + effect.Condition.Operator)
+			}
+		}
+	}
+	return nil
+}
+
+func (this EffectCondition) isMet(states map[string]interface{}) bool {
+	if this.State == "" {
+		return true
+	}
+	value := states[this.State]
+	switch this.Operator {
+	case "==":
+		return effectValuesEqual(value, this.Value)
+	case "!=":
+		return !effectValuesEqual(value, this.Value)
+	}
+	a, ok := toFloat(value)
+	if !ok {
+		return false
+	}
+	b, ok := toFloat(this.Value)
+	if !ok {
+		return false
+	}
+	switch this.Operator {
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	}
+	return false
+}
+
+func effectValuesEqual(a interface{}, b interface{}) bool {
+	_, aIsBool := a.(bool)
+	_, bIsBool := b.(bool)
+	if aFloat, ok := toFloat(a); ok && !aIsBool && !bIsBool {
+		if bFloat, ok := toFloat(b); ok {
+			return aFloat == bFloat
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// returns the new value of the target state or false if the state is unchanged
+func (this DeviceEffect) apply(deviceStates map[string]interface{}, target interface{}, interval time.Duration) (result float64, changed bool) {
+	if !this.Condition.isMet(deviceStates) || this.RatePerMinute == 0 {
+		return 0, false
+	}
+	current, ok := toFloat(target)
+	if target == nil {
+		current, ok = 0, true
+	}
+	if !ok {
+		return 0, false
+	}
+	result = current + this.RatePerMinute*interval.Minutes()
+	limit, hasLimit := toFloat(deviceStates[this.LimitState])
+	if this.LimitState == "" || !hasLimit {
+		hasLimit = this.Limit != nil
+		if hasLimit {
+			limit = *this.Limit
+		}
+	}
+	if hasLimit {
+		if this.RatePerMinute > 0 {
+			if current >= limit {
+				return 0, false
+			}
+			if result > limit {
+				result = limit
+			}
+		} else {
+			if current <= limit {
+				return 0, false
+			}
+			if result < limit {
+				result = limit
+			}
+		}
+	}
+	return result, true
+}
+
+func hasDeviceEffects(world *World) bool {
+	for _, room := range world.Rooms {
+		for _, device := range room.Devices {
+			if len(device.Effects) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (this *StateRepo) getEffectInterval() time.Duration {
+	if this.Config.EffectInterval > 0 {
+		return time.Duration(this.Config.EffectInterval) * time.Second
+	}
+	return defaultEffectInterval
+}
+
+// applies the effects of all online devices for the given interval
+// devices are evaluated in id order for reproducible results
+// expects the world to be locked
+func applyWorldEffects(world *World, interval time.Duration) (changes []EffectChange) {
+	for _, room := range sortedRooms(world.Rooms) {
+		for _, device := range sortedDevices(room.Devices) {
+			if device.Offline {
+				continue
+			}
+			for i, effect := range device.Effects {
+				var refId string
+				var states *map[string]interface{}
+				switch effect.Target {
+				case "device":
+					refId, states = device.Id, &device.States
+				case "room":
+					refId, states = room.Id, &room.States
+				case "world":
+					refId, states = world.Id, &world.States
+				default:
+					continue
+				}
+				if *states == nil {
+					*states = map[string]interface{}{}
+				}
+				from := (*states)[effect.TargetState]
+				to, changed := effect.apply(device.States, from, interval)
+				if !changed {
+					continue
+				}
+				(*states)[effect.TargetState] = to
+				changes = append(changes, EffectChange{Device: device.Id, Effect: i, RefType: effect.Target, RefId: refId, Key: effect.TargetState, From: from, To: to})
+			}
+		}
+	}
+	return changes
+}
+
+func (this *StateRepo) startEffects(world *World) (ticker *time.Ticker, stop chan bool) {
+	interval := this.getEffectInterval()
+	return this.startWorldUpdates(world, interval, "startEffects()", func(now time.Time) {
+		applyWorldEffects(world, interval)
+	})
+}
+
+func (this *StateRepo) UpdateDeviceEffects(jwt jwt.Jwt, msg DeviceEffectsRequest) (device DeviceResponse, access bool, exists bool, err error) {
+	device, access, exists, err = this.ReadDevice(jwt, msg.Device)
+	if err != nil || !access || !exists {
+		return
+	}
+	err = ValidateDeviceEffects(msg.Effects)
+	if err != nil {
+		return device, true, true, err
+	}
+	device.Device.Effects = msg.Effects
+	err = this.DevUpdateDevice(device.World, device.Room, device.Device)
+	return device, true, true, err
+}
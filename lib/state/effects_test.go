@@ -0,0 +1,173 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeviceEffectApply(t *testing.T) {
+	effect := DeviceEffect{
+		Condition:     EffectCondition{State: "on", Operator: "==", Value: true},
+		Target:        "room",
+		TargetState:   "temperature",
+		RatePerMinute: 0.2,
+		LimitState:    "setpoint",
+	}
+	states := map[string]interface{}{"on": true, "setpoint": float64(21)}
+	if result, changed := effect.apply(states, float64(20), 5*time.Minute); !changed || math.Abs(result-21) > 0.0000001 {
+		t.Error(result, changed)
+	}
+	if result, changed := effect.apply(states, float64(20.9), 5*time.Minute); !changed || result != 21 {
+		t.Error(result, changed)
+	}
+	if _, changed := effect.apply(states, float64(21), 5*time.Minute); changed {
+		t.Error("limit exceeded")
+	}
+	if _, changed := effect.apply(map[string]interface{}{"on": false, "setpoint": float64(21)}, float64(20), 5*time.Minute); changed {
+		t.Error("condition ignored")
+	}
+	if _, changed := effect.apply(states, "warm", 5*time.Minute); changed {
+		t.Error("non number target changed")
+	}
+
+	limit := float64(15)
+	cooling := DeviceEffect{Target: "room", TargetState: "temperature", RatePerMinute: -1, Limit: &limit}
+	if result, changed := cooling.apply(nil, float64(16), 5*time.Minute); !changed || result != 15 {
+		t.Error(result, changed)
+	}
+	if result, changed := cooling.apply(nil, nil, time.Minute); changed {
+		t.Error(result, changed)
+	}
+}
+
+func TestEffectConditionIsMet(t *testing.T) {
+	states := map[string]interface{}{"on": true, "level": float64(50), "mode": "eco"}
+	tests := []struct {
+		condition EffectCondition
+		expected  bool
+	}{
+		{EffectCondition{}, true},
+		{EffectCondition{State: "on", Operator: "==", Value: true}, true},
+		{EffectCondition{State: "on", Operator: "==", Value: float64(1)}, false},
+		{EffectCondition{State: "level", Operator: "==", Value: 50}, true},
+		{EffectCondition{State: "level", Operator: ">", Value: float64(50)}, false},
+		{EffectCondition{State: "level", Operator: ">=", Value: float64(50)}, true},
+		{EffectCondition{State: "mode", Operator: "!=", Value: "comfort"}, true},
+		{EffectCondition{State: "mode", Operator: "<", Value: float64(1)}, false},
+		{EffectCondition{State: "missing", Operator: "==", Value: nil}, true},
+	}
+	for i, test := range tests {
+		if result := test.condition.isMet(states); result != test.expected {
+			t.Error(i, result, test.expected)
+		}
+	}
+}
+
+func TestValidateDeviceEffects(t *testing.T) {
+	if err := ValidateDeviceEffects([]DeviceEffect{{Target: "room", TargetState: "temperature"}}); err != nil {
+		t.Error(err)
+	}
+	if err := ValidateDeviceEffects([]DeviceEffect{{Target: "house", TargetState: "temperature"}}); err == nil {
+		t.Error("missing error for unknown target")
+	}
+	if err := ValidateDeviceEffects([]DeviceEffect{{Target: "room"}}); err == nil {
+		t.Error("missing error for missing target state")
+	}
+	if err := ValidateDeviceEffects([]DeviceEffect{{Target: "room", TargetState: "temperature", Condition: EffectCondition{State: "on", Operator: "~"}}}); err == nil {
+		t.Error("missing error for unknown operator")
+	}
+}
+
+func TestApplyWorldEffects(t *testing.T) {
+	heater := &Device{
+		Id:     "heater",
+		States: map[string]interface{}{"on": true, "setpoint": float64(21)},
+		Effects: []DeviceEffect{{
+			Condition:     EffectCondition{State: "on", Operator: "==", Value: true},
+			Target:        "room",
+			TargetState:   "temperature",
+			RatePerMinute: 0.2,
+			LimitState:    "setpoint",
+		}},
+	}
+	window := &Device{
+		Id:      "window",
+		Offline: true,
+		Effects: []DeviceEffect{{Target: "room", TargetState: "temperature", RatePerMinute: -1}},
+	}
+	room := &Room{Id: "r", States: map[string]interface{}{"temperature": float64(20)}, Devices: map[string]*Device{"heater": heater, "window": window}}
+	world := &World{Id: "w", Rooms: map[string]*Room{"r": room}}
+	if !hasDeviceEffects(world) {
+		t.Fatal("hasDeviceEffects() = false")
+	}
+
+	changes := applyWorldEffects(world, time.Minute)
+	if len(changes) != 1 || changes[0].Device != "heater" || changes[0].RefType != "room" || changes[0].RefId != "r" || changes[0].From != float64(20) {
+		t.Error(changes)
+	}
+	if temp := room.States["temperature"].(float64); math.Abs(temp-20.2) > 0.0000001 {
+		t.Error(temp)
+	}
+	for i := 0; i < 10; i++ {
+		applyWorldEffects(world, time.Minute)
+	}
+	if room.States["temperature"] != float64(21) {
+		t.Error(room.States)
+	}
+}
+
+func TestSimulateWorldEffects(t *testing.T) {
+	repo := &StateRepo{}
+	world := WorldMsg{
+		Id: "w",
+		Rooms: map[string]RoomMsg{"r": {
+			Id:     "r",
+			States: map[string]interface{}{"temperature": float64(20)},
+			Devices: map[string]DeviceMsg{"heater": {
+				Id:      "heater",
+				States:  map[string]interface{}{"on": true},
+				Effects: []DeviceEffect{{Target: "room", TargetState: "temperature", RatePerMinute: 0.6}},
+			}},
+		}},
+	}
+	out := bytes.Buffer{}
+	writer, _ := getSimulationRecordWriter(SimulationFormatJsonLines, &out)
+	err := repo.simulateWorld(context.Background(), world, SimulationRequest{Start: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), Duration: 60, SampleInterval: -1}, writer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	//effects are applied at the start and every 10 seconds
+	if len(lines) != 7 {
+		t.Fatal(len(lines), out.String())
+	}
+	last := SimulationRecord{}
+	err = json.Unmarshal([]byte(lines[6]), &last)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.Type != SimulationRecordEffect || last.RefId != "r" || last.Source != "heater" || math.Abs(last.Value.(float64)-20.7) > 0.0000001 {
+		t.Error(last)
+	}
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+// EntityStates is the persisted state document of a world, room or device
+// states are stored separately from the world document to keep the world document small and unchanged by state updates
+type EntityStates struct {
+	World   string                 `json:"world" bson:"world"`
+	RefType string                 `json:"ref_type" bson:"ref_type"` // "world" || "room" || "device"
+	RefId   string                 `json:"ref_id" bson:"ref_id"`
+	States  map[string]interface{} `json:"states" bson:"states"`
+}
+
+func newEntityStates(world string, refType string, refId string, states map[string]interface{}) EntityStates {
+	return EntityStates{World: world, RefType: refType, RefId: refId, States: CleanStates(states)}
+}
+
+// returns the state documents of the world, its rooms and devices
+func getEntityStates(world World) (result []EntityStates) {
+	result = append(result, newEntityStates(world.Id, "world", world.Id, world.States))
+	for _, room := range world.Rooms {
+		result = append(result, newEntityStates(world.Id, "room", room.Id, room.States))
+		for _, device := range room.Devices {
+			result = append(result, newEntityStates(world.Id, "device", device.Id, device.States))
+		}
+	}
+	return result
+}
+
+// returns a copy of the world without states, to be stored as world document
+// the world itself is not changed
+func getWorldDocument(world World) World {
+	world.States = nil
+	rooms := map[string]*Room{}
+	for roomId, room := range world.Rooms {
+		roomCopy := *room
+		roomCopy.States = nil
+		roomCopy.Devices = map[string]*Device{}
+		for deviceId, device := range room.Devices {
+			deviceCopy := *device
+			deviceCopy.States = nil
+			roomCopy.Devices[deviceId] = &deviceCopy
+		}
+		rooms[roomId] = &roomCopy
+	}
+	world.Rooms = rooms
+	return world
+}
+
+// sets the persisted states of the world, its rooms and devices
+// entities without state document keep the states of the world document (worlds persisted before states were stored separately)
+func applyEntityStates(world *World, states []EntityStates) {
+	for _, element := range states {
+		switch element.RefType {
+		case "world":
+			world.States = element.States
+		case "room":
+			if room, ok := world.Rooms[element.RefId]; ok {
+				room.States = element.States
+			}
+		case "device":
+			for _, room := range world.Rooms {
+				if device, ok := room.Devices[element.RefId]; ok {
+					device.States = element.States
+				}
+			}
+		}
+	}
+}
+
+// returns the ids of all entities of the world with states
+func getEntityStateIds(world World) (result []string) {
+	result = []string{world.Id}
+	for _, room := range world.Rooms {
+		result = append(result, room.Id)
+		for _, device := range room.Devices {
+			result = append(result, device.Id)
+		}
+	}
+	return result
+}
+
+// persists the states of a single entity of the world
+// expects the world to be locked
+func (this *StateRepo) persistStates(world *World, refType string, refId string, states map[string]interface{}) (err error) {
+	return this.Persistence.PersistStates([]EntityStates{newEntityStates(world.Id, refType, refId, states)})
+}
+
+// persists the states of the world, its rooms and devices without rewriting the world document
+// expects the world to be locked
+func (this *StateRepo) persistWorldStates(world *World) (err error) {
+	return this.Persistence.PersistStates(getEntityStates(*world))
+}
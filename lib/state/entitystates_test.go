@@ -0,0 +1,165 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/config"
+)
+
+func getEntityStatesTestWorld() World {
+	return World{Id: "w", Owner: "user", States: map[string]interface{}{"temperature": 20.0}, Rooms: map[string]*Room{
+		"r": {Id: "r", States: map[string]interface{}{"humidity": 50.0}, Devices: map[string]*Device{
+			"d1": {Id: "d1", States: map[string]interface{}{"on": true}},
+			"d2": {Id: "d2", States: map[string]interface{}{"on": false}},
+		}},
+	}}
+}
+
+func TestGetWorldDocument(t *testing.T) {
+	world := getEntityStatesTestWorld()
+	document := getWorldDocument(world)
+	if document.States != nil || document.Rooms["r"].States != nil || document.Rooms["r"].Devices["d1"].States != nil {
+		t.Error(document)
+	}
+	if world.States == nil || world.Rooms["r"].States == nil || world.Rooms["r"].Devices["d1"].States == nil {
+		t.Error("world changed", world)
+	}
+	if len(getEntityStates(world)) != 4 {
+		t.Error(getEntityStates(world))
+	}
+}
+
+func TestBoltPersistenceStates(t *testing.T) {
+	persistence := newTestBoltPersistence(t, filepath.Join(t.TempDir(), "moses.db"))
+	world := getEntityStatesTestWorld()
+	err := persistence.PersistWorld(world)
+	if err != nil {
+		t.Fatal(err)
+	}
+	document := World{}
+	err = persistence.get(persistence.worldBucket, "w", &document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(document.States) != 0 || len(document.Rooms["r"].Devices["d1"].States) != 0 {
+		t.Error("world document contains states", document)
+	}
+
+	err = persistence.PersistStates([]EntityStates{newEntityStates("w", "device", "d1", map[string]interface{}{"on": false})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	worlds, err := persistence.LoadWorlds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := worlds["w"]
+	if loaded.States["temperature"] != 20.0 || loaded.Rooms["r"].States["humidity"] != 50.0 || loaded.Rooms["r"].Devices["d1"].States["on"] != false || loaded.Rooms["r"].Devices["d2"].States["on"] != false {
+		t.Error(loaded.States, loaded.Rooms["r"].States, loaded.Rooms["r"].Devices["d1"].States)
+	}
+
+	delete(world.Rooms["r"].Devices, "d2")
+	err = persistence.PersistWorld(world)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	err = persistence.each(persistence.stateBucket, "w/", func(data []byte) error {
+		count++
+		return nil
+	})
+	if err != nil || count != 3 {
+		t.Error("expected states of removed device to be deleted", count, err)
+	}
+
+	err = persistence.DeleteWorld("w")
+	if err != nil {
+		t.Fatal(err)
+	}
+	count = 0
+	err = persistence.each(persistence.stateBucket, "", func(data []byte) error {
+		count++
+		return nil
+	})
+	if err != nil || count != 0 {
+		t.Error("expected states of deleted world to be deleted", count, err)
+	}
+}
+
+func TestMigrateWorldStates(t *testing.T) {
+	persistence := newTestBoltPersistence(t, filepath.Join(t.TempDir(), "moses.db"))
+	legacy := getEntityStatesTestWorld()
+	err := persistence.put(persistence.worldBucket, legacy.Id, legacy) //world document with states, as persisted by older versions
+	if err != nil {
+		t.Fatal(err)
+	}
+	worlds, err := persistence.LoadWorlds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if worlds["w"].States["temperature"] != 20.0 || worlds["w"].Rooms["r"].Devices["d1"].States["on"] != true {
+		t.Fatal("legacy states not loaded", worlds["w"])
+	}
+
+	err = MigrateWorldStates(persistence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	document := World{}
+	err = persistence.get(persistence.worldBucket, "w", &document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(document.States) != 0 || len(document.Rooms["r"].Devices["d1"].States) != 0 {
+		t.Error("world document contains states after migration", document)
+	}
+	worlds, err = persistence.LoadWorlds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if worlds["w"].States["temperature"] != 20.0 || worlds["w"].Rooms["r"].Devices["d1"].States["on"] != true {
+		t.Error("states lost by migration", worlds["w"])
+	}
+}
+
+func TestJsStateSetPersistsStates(t *testing.T) {
+	persistence := newTestBoltPersistence(t, filepath.Join(t.TempDir(), "moses.db"))
+	world := getEntityStatesTestWorld()
+	world.mux = &sync.Mutex{}
+	err := persistence.PersistWorld(world)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &StateRepo{Config: config.Config{}, Persistence: persistence}
+	device := world.Rooms["r"].Devices["d1"]
+	err = run(`moses.device.state.set("on", false)`, repo.getJsDeviceApi(&world, world.Rooms["r"], device), time.Second, world.mux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worlds, err := persistence.LoadWorlds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if worlds["w"].Rooms["r"].Devices["d1"].States["on"] != false {
+		t.Error(worlds["w"].Rooms["r"].Devices["d1"].States)
+	}
+}
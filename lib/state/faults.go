@@ -0,0 +1,288 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+)
+
+// FaultProfile describes how the values of a simulated device deviate from a healthy device.
+// rates are probabilities between 0 and 1 which are evaluated per message
+type FaultProfile struct {
+	NoiseStdDev    float64       `json:"noise_std_dev" bson:"noise_std_dev"`     //gaussian noise added to every number
+	DriftPerHour   float64       `json:"drift_per_hour" bson:"drift_per_hour"`   //added to every number per hour since Since
+	Since          time.Time     `json:"since" bson:"since"`                     //reference time for drift; set on update if empty
+	StuckAt        *float64      `json:"stuck_at" bson:"stuck_at"`               //if set, every number is replaced by this value
+	DropRate       float64       `json:"drop_rate" bson:"drop_rate"`             //message will not be sent
+	DuplicateRate  float64       `json:"duplicate_rate" bson:"duplicate_rate"`   //message will be sent twice
+	SpikeRate      float64       `json:"spike_rate" bson:"spike_rate"`           //numbers are moved by +/- SpikeMagnitude
+	SpikeMagnitude float64       `json:"spike_magnitude" bson:"spike_magnitude"` //
+	Outages        []FaultOutage `json:"outages" bson:"outages"`                 //device neither sends nor handles commands
+}
+
+// FaultOutage is active between Start and End; if RepeatInterval (in seconds) is > 0 the outage repeats every RepeatInterval seconds after Start
+type FaultOutage struct {
+	Start          time.Time `json:"start" bson:"start"`
+	End            time.Time `json:"end" bson:"end"`
+	RepeatInterval int64     `json:"repeat_interval" bson:"repeat_interval"`
+}
+
+type FaultStatus struct {
+	Sent               int64 `json:"sent"`
+	Dropped            int64 `json:"dropped"`
+	Duplicated         int64 `json:"duplicated"`
+	Spikes             int64 `json:"spikes"`
+	SuppressedByOutage int64 `json:"suppressed_by_outage"`
+	InOutage           bool  `json:"in_outage"`
+}
+
+type DeviceFaultsRequest struct {
+	Device   string                  `json:"device"`
+	Faults   *FaultProfile           `json:"faults"`
+	Services map[string]FaultProfile `json:"services"` //service id -> profile
+}
+
+type faultStatusRegistry struct {
+	mux    sync.Mutex
+	status map[string]FaultStatus
+}
+
+func (this *faultStatusRegistry) update(deviceId string, f func(status *FaultStatus)) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.status == nil {
+		this.status = map[string]FaultStatus{}
+	}
+	status := this.status[deviceId]
+	f(&status)
+	this.status[deviceId] = status
+}
+
+func (this *faultStatusRegistry) get(deviceId string) FaultStatus {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return this.status[deviceId]
+}
+
+func (this FaultOutage) isActive(now time.Time) bool {
+	if now.Before(this.Start) {
+		return false
+	}
+	if this.RepeatInterval <= 0 {
+		return now.Before(this.End)
+	}
+	interval := time.Duration(this.RepeatInterval) * time.Second
+	offset := now.Sub(this.Start) % interval
+	return offset < this.End.Sub(this.Start)
+}
+
+func (this FaultProfile) inOutage(now time.Time) bool {
+	for _, outage := range this.Outages {
+		if outage.isActive(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the values which should be sent instead of value; an empty list means the message is dropped
+func (this FaultProfile) apply(value interface{}, now time.Time, rnd *rand.Rand) (result []interface{}, spike bool) {
+	if this.DropRate > 0 && rnd.Float64() < this.DropRate {
+		return []interface{}{}, false
+	}
+	spike = this.SpikeRate > 0 && rnd.Float64() < this.SpikeRate
+	spikeOffset := this.SpikeMagnitude
+	if rnd.Intn(2) == 0 {
+		spikeOffset = -spikeOffset
+	}
+	drift := 0.0
+	if !this.Since.IsZero() {
+		drift = this.DriftPerHour * now.Sub(this.Since).Hours()
+	}
+	value = mapNumbers(value, func(f float64) float64 {
+		if this.StuckAt != nil {
+			return *this.StuckAt
+		}
+		f = f + drift
+		if this.NoiseStdDev > 0 {
+			f = f + rnd.NormFloat64()*this.NoiseStdDev
+		}
+		if spike {
+			f = f + spikeOffset
+		}
+		return f
+	})
+	result = []interface{}{value}
+	if this.DuplicateRate > 0 && rnd.Float64() < this.DuplicateRate {
+		result = append(result, value)
+	}
+	return result, spike
+}
+
+// applies f to every number in value; maps and lists are copied
+func mapNumbers(value interface{}, f func(float64) float64) interface{} {
+	switch v := value.(type) {
+	case float64:
+		return f(v)
+	case float32:
+		return f(float64(v))
+	case int:
+		return f(float64(v))
+	case int64:
+		return f(float64(v))
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for key, sub := range v {
+			result[key] = mapNumbers(sub, f)
+		}
+		return result
+	case SegmentedValue:
+		result := SegmentedValue{}
+		for key, sub := range v {
+			result[key] = mapNumbers(sub, f)
+		}
+		return result
+	case []interface{}:
+		result := []interface{}{}
+		for _, sub := range v {
+			result = append(result, mapNumbers(sub, f))
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// returns the fault profile for the service; service profiles replace the device profile
+func getFaultProfile(device *Device, serviceId string) (profile FaultProfile, ok bool) {
+	if profile, ok = device.ServiceFaults[serviceId]; ok {
+		return profile, true
+	}
+	if device.Faults != nil {
+		return *device.Faults, true
+	}
+	return profile, false
+}
+
+// returns values which should be sent for the service of the device; an empty list means no message is sent
+func (this *StateRepo) applyFaults(device *Device, serviceId string, value interface{}) []interface{} {
+	profile, ok := getFaultProfile(device, serviceId)
+	if !ok {
+		return []interface{}{value}
+	}
+	now := this.now()
+	if profile.inOutage(now) {
+		this.faultStatus.update(device.Id, func(status *FaultStatus) {
+			status.SuppressedByOutage++
+		})
+		return []interface{}{}
+	}
+	this.faultRandMux.Lock()
+	if this.faultRand == nil {
+		this.faultRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	result, spike := profile.apply(value, now, this.faultRand)
+	this.faultRandMux.Unlock()
+	this.faultStatus.update(device.Id, func(status *FaultStatus) {
+		switch len(result) {
+		case 0:
+			status.Dropped++
+		case 1:
+			status.Sent++
+		default:
+			status.Sent++
+			status.Duplicated++
+		}
+		if spike {
+			status.Spikes++
+		}
+	})
+	return result
+}
+
+// returns true if the service of the device is currently unavailable because of a scheduled outage
+func (this *StateRepo) isInFaultOutage(device *Device, serviceId string) bool {
+	profile, ok := getFaultProfile(device, serviceId)
+	if !ok || !profile.inOutage(this.now()) {
+		return false
+	}
+	this.faultStatus.update(device.Id, func(status *FaultStatus) {
+		status.SuppressedByOutage++
+	})
+	return true
+}
+
+func (this *StateRepo) getFaultyResponder(device *Device, serviceId string, responder func(respMsg interface{})) func(respMsg interface{}) {
+	return func(respMsg interface{}) {
+		for _, value := range this.applyFaults(device, serviceId, respMsg) {
+			responder(value)
+		}
+	}
+}
+
+// expects the world to be locked
+func (this *StateRepo) getDeviceStatus(world *World, device *Device) DeviceStatus {
+	return DeviceStatus{
+		Online:    !device.Offline,
+		Reachable: isDeviceReachable(world, device),
+		Faults:    this.getFaultStatus(device),
+	}
+}
+
+// the device is in outage if the outage of its fault profile or of one of its service fault profiles is active
+func (this *StateRepo) getFaultStatus(device *Device) (status FaultStatus) {
+	status = this.faultStatus.get(device.Id)
+	now := this.now()
+	if device.Faults != nil && device.Faults.inOutage(now) {
+		status.InOutage = true
+	}
+	for _, profile := range device.ServiceFaults {
+		if profile.inOutage(now) {
+			status.InOutage = true
+		}
+	}
+	return status
+}
+
+func (this *StateRepo) UpdateDeviceFaults(jwt jwt.Jwt, msg DeviceFaultsRequest) (device DeviceResponse, access bool, exists bool, err error) {
+	device, access, exists, err = this.ReadDevice(jwt, msg.Device)
+	if err != nil || !access || !exists {
+		return
+	}
+	now := time.Now()
+	if msg.Faults != nil && msg.Faults.Since.IsZero() {
+		msg.Faults.Since = now
+	}
+	for serviceId, profile := range msg.Services {
+		if _, ok := device.Device.Services[serviceId]; !ok {
+			return device, true, true, errors.New("unknown service id: " + serviceId)
+		}
+		if profile.Since.IsZero() {
+			profile.Since = now
+			msg.Services[serviceId] = profile
+		}
+	}
+	device.Device.Faults = msg.Faults
+	device.Device.ServiceFaults = msg.Services
+	err = this.DevUpdateDevice(device.World, device.Room, device.Device)
+	return device, true, true, err
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFaultProfileApply(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	now := time.Now()
+	value := map[string]interface{}{"temperature": float64(20), "unit": "°C", "list": []interface{}{float64(1)}}
+
+	result, _ := FaultProfile{}.apply(value, now, rnd)
+	if !reflect.DeepEqual(result, []interface{}{value}) {
+		t.Error(result)
+	}
+
+	result, _ = FaultProfile{DropRate: 1}.apply(value, now, rnd)
+	if len(result) != 0 {
+		t.Error(result)
+	}
+
+	result, _ = FaultProfile{DuplicateRate: 1}.apply(value, now, rnd)
+	if len(result) != 2 {
+		t.Error(result)
+	}
+
+	stuck := float64(42)
+	result, _ = FaultProfile{StuckAt: &stuck}.apply(value, now, rnd)
+	if !reflect.DeepEqual(result, []interface{}{map[string]interface{}{"temperature": float64(42), "unit": "°C", "list": []interface{}{float64(42)}}}) {
+		t.Error(result)
+	}
+
+	result, _ = FaultProfile{DriftPerHour: 1, Since: now.Add(-2 * time.Hour)}.apply(float64(20), now, rnd)
+	if !reflect.DeepEqual(result, []interface{}{float64(22)}) {
+		t.Error(result)
+	}
+
+	result, spike := FaultProfile{SpikeRate: 1, SpikeMagnitude: 100}.apply(float64(20), now, rnd)
+	if !spike || (result[0] != float64(120) && result[0] != float64(-80)) {
+		t.Error(result, spike)
+	}
+}
+
+func TestFaultOutage(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	outage := FaultOutage{Start: start, End: start.Add(10 * time.Minute)}
+	if outage.isActive(start.Add(-time.Minute)) || !outage.isActive(start.Add(time.Minute)) || outage.isActive(start.Add(11*time.Minute)) {
+		t.Error("unexpected single outage result")
+	}
+	outage.RepeatInterval = 3600
+	if !outage.isActive(start.Add(5*time.Hour+time.Minute)) || outage.isActive(start.Add(5*time.Hour+11*time.Minute)) {
+		t.Error("unexpected repeated outage result")
+	}
+}
+
+func TestFaultStatusServiceOutage(t *testing.T) {
+	now := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	repo := &StateRepo{clock: func() time.Time { return now }}
+	outage := FaultOutage{Start: now.Add(-time.Minute), End: now.Add(time.Minute)}
+	device := &Device{Id: "d", ServiceFaults: map[string]FaultProfile{"s": {Outages: []FaultOutage{outage}}}}
+	if !repo.getFaultStatus(device).InOutage {
+		t.Error("expected outage of service fault profile")
+	}
+	device.ServiceFaults["s"] = FaultProfile{}
+	if repo.getFaultStatus(device).InOutage {
+		t.Error("unexpected outage")
+	}
+}
@@ -0,0 +1,382 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+)
+
+// Hub lets a world or room act as simulated gateway of its devices
+// the hub of a room replaces the hub of its world for the devices of the room
+// devices behind an offline hub neither send sensor data nor handle commands
+type Hub struct {
+	Name        string   `json:"name" bson:"name"`
+	ExternalRef string   `json:"external_ref" bson:"external_ref"` //id of the platform hub
+	Offline     bool     `json:"offline" bson:"offline"`
+	Devices     []string `json:"devices" bson:"devices"` //sorted platform device ids registered at the platform hub
+}
+
+type HubRequest struct {
+	Name string `json:"name"`
+}
+
+type HubConnectionRequest struct {
+	Online bool `json:"online"`
+}
+
+// returns the hub of the device or nil
+// expects the world to be locked
+func getDeviceHub(world *World, device *Device) *Hub {
+	if world == nil {
+		return nil
+	}
+	for _, room := range world.Rooms {
+		if _, ok := room.Devices[device.Id]; ok && room.Hub != nil {
+			return room.Hub
+		}
+	}
+	return world.Hub
+}
+
+// a device is reachable if it is online and its hub (if any) is online
+// expects the world to be locked
+func isDeviceReachable(world *World, device *Device) bool {
+	if device.Offline {
+		return false
+	}
+	hub := getDeviceHub(world, device)
+	return hub == nil || !hub.Offline
+}
+
+// locks the world to check if the device is reachable; used before js runs which lock the world themselves
+func isDeviceReachableLocked(world *World, device *Device) bool {
+	world.mux.Lock()
+	defer world.mux.Unlock()
+	return isDeviceReachable(world, device)
+}
+
+// returns the devices of the world which are not behind a room hub
+func getWorldHubDevices(world WorldMsg) (result []DeviceMsg) {
+	for _, room := range world.Rooms {
+		if room.Hub != nil {
+			continue
+		}
+		for _, device := range room.Devices {
+			result = append(result, device)
+		}
+	}
+	return result
+}
+
+func getRoomHubDevices(room RoomMsg) (result []DeviceMsg) {
+	for _, device := range room.Devices {
+		result = append(result, device)
+	}
+	return result
+}
+
+// creates or updates the platform hub with the platform devices of the given moses devices
+func (this *StateRepo) registerHub(jwt jwt.Jwt, current *Hub, name string, devices []DeviceMsg) (result Hub, err error) {
+	if current != nil {
+		result = *current
+	}
+	result.Name = name
+	platformHub := model.Hub{Id: result.ExternalRef, Name: name, DeviceLocalIds: []string{}, DeviceIds: []string{}}
+	for _, device := range devices {
+		if device.ExternalRef == "" {
+			continue
+		}
+		externalDevice, err := this.Connector.GetDevice(jwt.Impersonate, device.ExternalRef)
+		if err != nil {
+			return result, err
+		}
+		platformHub.DeviceLocalIds = append(platformHub.DeviceLocalIds, externalDevice.LocalId)
+		platformHub.DeviceIds = append(platformHub.DeviceIds, externalDevice.Id)
+	}
+	sort.Strings(platformHub.DeviceLocalIds)
+	sort.Strings(platformHub.DeviceIds)
+	platformHub.Hash = getHubHash(platformHub.DeviceLocalIds)
+	if platformHub.Id == "" {
+		platformHub, err = this.Connector.CreateHub(jwt.Impersonate, platformHub)
+	} else {
+		platformHub, err = this.Connector.UpdateHub(jwt.Impersonate, platformHub)
+	}
+	if err != nil {
+		return result, err
+	}
+	result.ExternalRef = platformHub.Id
+	result.Devices = platformHub.DeviceIds
+	return result, nil
+}
+
+// returns the sorted platform device ids of the given moses devices
+func getHubDeviceRefs(devices []DeviceMsg) (result []string) {
+	result = []string{}
+	for _, device := range devices {
+		if device.ExternalRef != "" {
+			result = append(result, device.ExternalRef)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func isHubRegistered(hub *Hub) bool {
+	return hub != nil && hub.ExternalRef != ""
+}
+
+// updates the device lists of the registered platform hubs of the world after devices or rooms changed
+// failures are only logged; the hub is refreshed again with the next change or a PUT on the hub
+func (this *StateRepo) refreshHubs(jwt jwt.Jwt, worldId string) {
+	world, exists, err := this.DevGetWorld(worldId)
+	if err != nil || !exists {
+		return
+	}
+	if isHubRegistered(world.Hub) {
+		this.refreshHub(jwt, worldId, "", world.Hub, getWorldHubDevices(world))
+	}
+	for _, room := range world.Rooms {
+		if isHubRegistered(room.Hub) {
+			this.refreshHub(jwt, worldId, room.Id, room.Hub, getRoomHubDevices(room))
+		}
+	}
+}
+
+func (this *StateRepo) refreshHub(jwt jwt.Jwt, worldId string, roomId string, hub *Hub, devices []DeviceMsg) {
+	if slices.Equal(getHubDeviceRefs(devices), hub.Devices) {
+		return
+	}
+	updated, err := this.registerHub(jwt, hub, hub.Name, devices)
+	if err != nil {
+		log.Println("WARNING: unable to refresh hub", hub.ExternalRef, err)
+		return
+	}
+	err = this.setHubDevices(worldId, roomId, updated.Devices)
+	if err != nil {
+		log.Println("WARNING: unable to store refreshed hub", hub.ExternalRef, err)
+	}
+}
+
+// stores the registered devices of the world hub (empty roomId) or room hub without restarting change routines
+func (this *StateRepo) setHubDevices(worldId string, roomId string, devices []string) error {
+	this.mux.RLock()
+	defer this.mux.RUnlock()
+	world, exists := this.Worlds[worldId]
+	if !exists {
+		return nil
+	}
+	world.mux.Lock()
+	defer world.mux.Unlock()
+	hub := world.Hub
+	if roomId != "" {
+		room, ok := world.Rooms[roomId]
+		if !ok {
+			return nil
+		}
+		hub = room.Hub
+	}
+	if hub == nil {
+		return nil
+	}
+	hub.Devices = devices
+	return this.persistWorld(*world)
+}
+
+func getHubHash(localIds []string) string {
+	hash := sha256.Sum256([]byte(strings.Join(localIds, ",")))
+	return hex.EncodeToString(hash[:])
+}
+
+func (this *StateRepo) removeHub(jwt jwt.Jwt, hub *Hub) error {
+	if hub == nil || hub.ExternalRef == "" {
+		return nil
+	}
+	return this.Connector.DeleteHub(jwt.Impersonate, hub.ExternalRef)
+}
+
+// removes the platform hubs; used by cascading deletes, failures are only logged
+func (this *StateRepo) removeHubs(jwt jwt.Jwt, hubs ...*Hub) {
+	for _, hub := range hubs {
+		err := this.removeHub(jwt, hub)
+		if err != nil {
+			log.Println("WARNING: unable to remove hub", hub.ExternalRef, err)
+		}
+	}
+}
+
+// registers the world as platform hub of all devices which are not behind a room hub; updates the device list of an existing hub
+func (this *StateRepo) UpdateWorldHub(jwt jwt.Jwt, worldId string, msg HubRequest) (world WorldMsg, access bool, exists bool, err error) {
+	world, access, exists, err = this.ReadWorld(jwt, worldId)
+	if err != nil || !access || !exists {
+		return
+	}
+	created := !isHubRegistered(world.Hub)
+	hub, err := this.registerHub(jwt, world.Hub, msg.Name, getWorldHubDevices(world))
+	if err != nil {
+		return world, true, true, err
+	}
+	world.Hub = &hub
+	err = this.DevUpdateWorld(world)
+	if err != nil && created {
+		this.removeHubs(jwt, &hub)
+	}
+	return world, true, true, err
+}
+
+func (this *StateRepo) DeleteWorldHub(jwt jwt.Jwt, worldId string) (access bool, exists bool, err error) {
+	world, access, exists, err := this.ReadWorld(jwt, worldId)
+	if err != nil || !access || !exists {
+		return access, exists, err
+	}
+	err = this.removeHub(jwt, world.Hub)
+	if err != nil {
+		return true, true, err
+	}
+	world.Hub = nil
+	err = this.DevUpdateWorld(world)
+	return true, true, err
+}
+
+// registers the room as platform hub of its devices; updates the device list of an existing hub
+func (this *StateRepo) UpdateRoomHub(jwt jwt.Jwt, roomId string, msg HubRequest) (room RoomResponse, access bool, exists bool, err error) {
+	room, access, exists, err = this.ReadRoom(jwt, roomId)
+	if err != nil || !access || !exists {
+		return
+	}
+	created := !isHubRegistered(room.Room.Hub)
+	hub, err := this.registerHub(jwt, room.Room.Hub, msg.Name, getRoomHubDevices(room.Room))
+	if err != nil {
+		return room, true, true, err
+	}
+	room.Room.Hub = &hub
+	err = this.DevUpdateRoom(room.World, room.Room)
+	if err != nil {
+		if created {
+			this.removeHubs(jwt, &hub)
+		}
+		return room, true, true, err
+	}
+	//the devices of the room leave the world hub
+	this.refreshHubs(jwt, room.World)
+	return room, true, true, nil
+}
+
+func (this *StateRepo) DeleteRoomHub(jwt jwt.Jwt, roomId string) (access bool, exists bool, err error) {
+	room, access, exists, err := this.ReadRoom(jwt, roomId)
+	if err != nil || !access || !exists {
+		return access, exists, err
+	}
+	err = this.removeHub(jwt, room.Room.Hub)
+	if err != nil {
+		return true, true, err
+	}
+	room.Room.Hub = nil
+	err = this.DevUpdateRoom(room.World, room.Room)
+	if err != nil {
+		return true, true, err
+	}
+	//the devices of the room fall back to the world hub
+	this.refreshHubs(jwt, room.World)
+	return true, true, nil
+}
+
+// sets the hub online or offline without restarting change routines; the connection of the hub and of its devices is logged
+// expects the world to be locked
+func (this *StateRepo) setHubOnline(world *World, hub *Hub, online bool) (err error) {
+	hub.Offline = !online
+	this.logHubConnection(hub)
+	for _, room := range world.Rooms {
+		for _, device := range room.Devices {
+			if getDeviceHub(world, device) == hub {
+				this.logDeviceConnection(world, device)
+			}
+		}
+	}
+	return this.persistWorld(*world)
+}
+
+// exists is false if the world has no hub
+func (this *StateRepo) SetWorldHubOnline(jwt jwt.Jwt, worldId string, msg HubConnectionRequest) (result WorldMsg, access bool, exists bool, err error) {
+	this.mux.RLock()
+	defer this.mux.RUnlock()
+	world, exists := this.Worlds[worldId]
+	if !exists {
+		return result, false, false, nil
+	}
+	world.mux.Lock()
+	defer world.mux.Unlock()
+	if world.Owner != jwt.UserId {
+		return result, false, true, nil
+	}
+	if world.Hub == nil {
+		return result, true, false, nil
+	}
+	err = this.setHubOnline(world, world.Hub, msg.Online)
+	if err != nil {
+		return result, true, true, err
+	}
+	result, err = world.ToMsg()
+	return result, true, true, err
+}
+
+// exists is false if the room has no hub
+func (this *StateRepo) SetRoomHubOnline(jwt jwt.Jwt, roomId string, msg HubConnectionRequest) (result RoomResponse, access bool, exists bool, err error) {
+	this.mux.RLock()
+	defer this.mux.RUnlock()
+	world, exists := this.roomWorldIndex[roomId]
+	if !exists {
+		return result, false, false, nil
+	}
+	world.mux.Lock()
+	defer world.mux.Unlock()
+	if world.Owner != jwt.UserId {
+		return result, false, true, nil
+	}
+	room, ok := world.Rooms[roomId]
+	if !ok || room.Hub == nil {
+		return result, true, false, nil
+	}
+	err = this.setHubOnline(world, room.Hub, msg.Online)
+	if err != nil {
+		return result, true, true, err
+	}
+	result.World = world.Id
+	result.Room, err = room.ToMsg()
+	return result, true, true, err
+}
+
+// expects the world to be locked
+func (this *StateRepo) setJsHubOnline(world *World, hub *Hub, online bool) {
+	if hub == nil {
+		log.Println("WARNING: js-api setHubOnline(), no hub")
+		return
+	}
+	err := this.setHubOnline(world, hub, online)
+	if err != nil {
+		log.Println("ERROR:", err)
+		debug.PrintStack()
+	}
+}
@@ -0,0 +1,357 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+)
+
+type connectionRecorder struct {
+	mux     sync.Mutex
+	entries []string
+}
+
+func (this *connectionRecorder) add(entry string) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.entries = append(this.entries, entry)
+	return nil
+}
+
+func (this *connectionRecorder) get() []string {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return append([]string{}, this.entries...)
+}
+
+func (this *connectionRecorder) LogDeviceDisconnect(id string) error {
+	return this.add("device disconnect " + id)
+}
+
+func (this *connectionRecorder) LogDeviceConnect(id string) error {
+	return this.add("device connect " + id)
+}
+
+func (this *connectionRecorder) LogHubConnect(gateway string) error {
+	return this.add("hub connect " + gateway)
+}
+
+func (this *connectionRecorder) LogHubDisconnect(gateway string) error {
+	return this.add("hub disconnect " + gateway)
+}
+
+func getHubTestWorld() *World {
+	return &World{Id: "w", Owner: "user", mux: &sync.Mutex{}, Hub: &Hub{Name: "world hub", ExternalRef: "world_hub"}, Rooms: map[string]*Room{
+		"r1": {Id: "r1", Devices: map[string]*Device{
+			"d1": {Id: "d1", ExternalRef: "d1_ref", States: map[string]interface{}{}, Services: map[string]Service{
+				"s1": {Id: "s1", ExternalRef: "s1_ref", Code: `moses.service.send({"ok": true});`},
+			}},
+		}},
+		"r2": {Id: "r2", Hub: &Hub{Name: "room hub", ExternalRef: "room_hub"}, Devices: map[string]*Device{
+			"d2": {Id: "d2", ExternalRef: "d2_ref", States: map[string]interface{}{}},
+		}},
+	}}
+}
+
+func TestDeviceHubReachability(t *testing.T) {
+	world := getHubTestWorld()
+	d1 := world.Rooms["r1"].Devices["d1"]
+	d2 := world.Rooms["r2"].Devices["d2"]
+	if getDeviceHub(world, d1) != world.Hub {
+		t.Error("expected world hub for d1")
+	}
+	if getDeviceHub(world, d2) != world.Rooms["r2"].Hub {
+		t.Error("expected room hub for d2")
+	}
+	if getDeviceHub(nil, d1) != nil {
+		t.Error("expected no hub without world")
+	}
+
+	world.Hub.Offline = true
+	if isDeviceReachable(world, d1) {
+		t.Error("d1 should be unreachable behind offline world hub")
+	}
+	if !isDeviceReachable(world, d2) {
+		t.Error("d2 should be reachable behind online room hub")
+	}
+
+	world.Hub.Offline = false
+	world.Rooms["r2"].Hub.Offline = true
+	if !isDeviceReachable(world, d1) || isDeviceReachable(world, d2) {
+		t.Error(isDeviceReachable(world, d1), isDeviceReachable(world, d2))
+	}
+
+	world.Rooms["r2"].Hub.Offline = false
+	d2.Offline = true
+	if isDeviceReachable(world, d2) {
+		t.Error("offline device should be unreachable behind online hub")
+	}
+}
+
+func TestSetHubOnline(t *testing.T) {
+	recorder := &connectionRecorder{}
+	world := getHubTestWorld()
+	repo := &StateRepo{
+		Persistence: nopPersistence{},
+		StateLogger: recorder,
+		Worlds:      map[string]*World{"w": world},
+	}
+	repo.Start()
+	defer repo.Stop()
+
+	expected := []string{"hub connect world_hub", "hub connect room_hub", "device connect d1_ref", "device connect d2_ref"}
+	if !sameEntries(recorder.get(), expected) {
+		t.Fatal(recorder.get())
+	}
+
+	_, _, exists, err := repo.SetWorldHubOnline(jwt.Jwt{UserId: "user"}, "w", HubConnectionRequest{Online: false})
+	if err != nil || !exists {
+		t.Fatal(exists, err)
+	}
+	expected = []string{"hub disconnect world_hub", "device disconnect d1_ref"}
+	if !reflect.DeepEqual(recorder.get()[4:], expected) {
+		t.Fatal(recorder.get())
+	}
+
+	_, access, _, err := repo.SetRoomHubOnline(jwt.Jwt{UserId: "other"}, "r2", HubConnectionRequest{Online: false})
+	if err != nil || access {
+		t.Fatal(access, err)
+	}
+	_, _, exists, err = repo.SetRoomHubOnline(jwt.Jwt{UserId: "user"}, "r1", HubConnectionRequest{Online: false})
+	if err != nil || exists {
+		t.Fatal("room without hub", exists, err)
+	}
+
+	api := repo.getJsRoomApi(world, world.Rooms["r2"])
+	err = run(`moses.room.setHubOnline(false); if(moses.room.isHubOnline()){throw new Error("expected offline hub")}`, api, time.Second, world.mux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = append(expected, "hub disconnect room_hub", "device disconnect d2_ref")
+	if !reflect.DeepEqual(recorder.get()[4:], expected) {
+		t.Fatal(recorder.get())
+	}
+
+	_, err = repo.RunService("s1", nil)
+	if err == nil {
+		t.Error("expected error for device behind offline hub")
+	}
+}
+
+func sameEntries(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := map[string]int{}
+	for _, entry := range a {
+		count[entry]++
+	}
+	for _, entry := range b {
+		count[entry]--
+	}
+	for _, c := range count {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRegisterHub(t *testing.T) {
+	connector, err := NewLocalConnector(config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deviceType, err := connector.SetDeviceType(model.DeviceType{Name: "lamp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lamp1, err := connector.CreateDevice("", model.Device{Name: "lamp 1", LocalId: "lamp_1", DeviceTypeId: deviceType.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lamp2, err := connector.CreateDevice("", model.Device{Name: "lamp 2", LocalId: "lamp_2", DeviceTypeId: deviceType.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &StateRepo{Connector: connector}
+	devices := []DeviceMsg{{Id: "d2", ExternalRef: lamp2.Id}, {Id: "d1", ExternalRef: lamp1.Id}, {Id: "d3"}}
+	hub, err := repo.registerHub(jwt.Jwt{}, nil, "hub", devices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hub.ExternalRef == "" || hub.Name != "hub" {
+		t.Fatal(hub)
+	}
+	hubs := connector.ListHubs()
+	if len(hubs) != 1 || hubs[0].Id != hub.ExternalRef {
+		t.Fatal(hubs)
+	}
+	if !reflect.DeepEqual(hubs[0].DeviceLocalIds, []string{"lamp_1", "lamp_2"}) || hubs[0].Hash != getHubHash([]string{"lamp_1", "lamp_2"}) {
+		t.Error(hubs[0])
+	}
+
+	updated, err := repo.registerHub(jwt.Jwt{}, &hub, "renamed", devices[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	hubs = connector.ListHubs()
+	if updated.ExternalRef != hub.ExternalRef || len(hubs) != 1 || hubs[0].Name != "renamed" || !reflect.DeepEqual(hubs[0].DeviceLocalIds, []string{"lamp_2"}) {
+		t.Error(updated, hubs)
+	}
+
+	err = repo.removeHub(jwt.Jwt{}, &updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(connector.ListHubs()) != 0 {
+		t.Error(connector.ListHubs())
+	}
+}
+
+type failingWorldPersistence struct {
+	nopPersistence
+}
+
+func (this failingWorldPersistence) PersistWorld(world World) error {
+	return errors.New("test error")
+}
+
+func getHubLocalIds(connector *LocalConnector, id string) []string {
+	for _, hub := range connector.ListHubs() {
+		if hub.Id == id {
+			return hub.DeviceLocalIds
+		}
+	}
+	return nil
+}
+
+func TestRefreshHubs(t *testing.T) {
+	connector, err := NewLocalConnector(config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deviceType, err := connector.SetDeviceType(model.DeviceType{Name: "lamp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lamps := []model.Device{}
+	for _, localId := range []string{"lamp_1", "lamp_2", "lamp_3"} {
+		lamp, err := connector.CreateDevice("", model.Device{Name: localId, LocalId: localId, DeviceTypeId: deviceType.Id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		lamps = append(lamps, lamp)
+	}
+	repo := &StateRepo{
+		Connector:   connector,
+		Persistence: nopPersistence{},
+		StateLogger: nopLogger{},
+		Worlds: map[string]*World{"w": {Id: "w", Owner: "user", mux: &sync.Mutex{}, Rooms: map[string]*Room{
+			"r1": {Id: "r1", Devices: map[string]*Device{"d1": {Id: "d1", ExternalRef: lamps[0].Id, States: map[string]interface{}{}}}},
+			"r2": {Id: "r2", Devices: map[string]*Device{"d2": {Id: "d2", ExternalRef: lamps[1].Id, States: map[string]interface{}{}}}},
+		}}},
+	}
+	repo.Start()
+	defer repo.Stop()
+	user := jwt.Jwt{UserId: "user"}
+
+	world, _, _, err := repo.UpdateWorldHub(user, "w", HubRequest{Name: "world hub"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	worldHub := world.Hub.ExternalRef
+	if ids := getHubLocalIds(connector, worldHub); !reflect.DeepEqual(ids, []string{"lamp_1", "lamp_2"}) {
+		t.Fatal(ids)
+	}
+
+	room, _, _, err := repo.UpdateRoomHub(user, "r2", HubRequest{Name: "room hub"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := getHubLocalIds(connector, worldHub); !reflect.DeepEqual(ids, []string{"lamp_1"}) {
+		t.Error("device should leave the world hub", ids)
+	}
+	if ids := getHubLocalIds(connector, room.Room.Hub.ExternalRef); !reflect.DeepEqual(ids, []string{"lamp_2"}) {
+		t.Error(ids)
+	}
+
+	_, _, _, err = repo.CreateDevice(user, CreateDeviceRequest{Name: "d3", Room: "r1", ExternalRef: lamps[2].Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := getHubLocalIds(connector, worldHub); !reflect.DeepEqual(ids, []string{"lamp_1", "lamp_3"}) {
+		t.Error("new device should join the world hub", ids)
+	}
+
+	_, _, err = repo.DeleteRoomHub(user, "r2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := getHubLocalIds(connector, worldHub); !reflect.DeepEqual(ids, []string{"lamp_1", "lamp_2", "lamp_3"}) {
+		t.Error("device should fall back to the world hub", ids)
+	}
+
+	_, _, _, err = repo.DeleteDevice(user, "d1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := getHubLocalIds(connector, worldHub); !reflect.DeepEqual(ids, []string{"lamp_2", "lamp_3"}) {
+		t.Error("deleted device should leave the world hub", ids)
+	}
+	if len(connector.ListHubs()) != 1 {
+		t.Error(connector.ListHubs())
+	}
+}
+
+func TestRemoveCreatedHubOnFailedUpdate(t *testing.T) {
+	connector, err := NewLocalConnector(config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &StateRepo{
+		Connector:   connector,
+		Persistence: failingWorldPersistence{},
+		StateLogger: nopLogger{},
+		Worlds: map[string]*World{"w": {Id: "w", Owner: "user", mux: &sync.Mutex{}, Rooms: map[string]*Room{
+			"r1": {Id: "r1", Devices: map[string]*Device{}},
+		}}},
+	}
+	repo.Start()
+	defer repo.Stop()
+	user := jwt.Jwt{UserId: "user"}
+
+	_, _, _, err = repo.UpdateWorldHub(user, "w", HubRequest{Name: "world hub"})
+	if err == nil {
+		t.Fatal("expected persistence error")
+	}
+	_, _, _, err = repo.UpdateRoomHub(user, "r1", HubRequest{Name: "room hub"})
+	if err == nil {
+		t.Fatal("expected persistence error")
+	}
+	if len(connector.ListHubs()) != 0 {
+		t.Error("created hubs should be removed", connector.ListHubs())
+	}
+}
@@ -17,39 +17,68 @@
 package state
 
 import (
+	"errors"
 	deviceRepo "github.com/SENERGY-Platform/device-repository/lib/client"
 	"github.com/SENERGY-Platform/models/go/models"
+	"github.com/SENERGY-Platform/moses/lib/config"
 	"github.com/SENERGY-Platform/moses/lib/jwt"
 	permClient "github.com/SENERGY-Platform/permissions-v2/pkg/client"
+	platform_connector_lib "github.com/SENERGY-Platform/platform-connector-lib"
 	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"github.com/SENERGY-Platform/platform-connector-lib/security"
 	"github.com/google/uuid"
 	"log"
+	"net/http"
 	"net/url"
 	"runtime/debug"
+	"time"
 )
 
-func (this *StateRepo) GetIotDeviceType(jwt jwt.Jwt, id string) (dt model.DeviceType, err error) {
-	err = jwt.Impersonate.GetJSON(this.Config.DeviceManagerUrl+"/device-types/"+url.PathEscape(id), &dt)
+// SenergyConnector connects moses to the SENERGY platform: kafka, keycloak, device manager, device repository and permissions
+type SenergyConnector struct {
+	connector *platform_connector_lib.Connector
+	config    config.Config
+}
+
+func NewSenergyConnector(connector *platform_connector_lib.Connector, config config.Config) *SenergyConnector {
+	return &SenergyConnector{connector: connector, config: config}
+}
+
+func (this *SenergyConnector) Access() (token jwt.JwtImpersonate, err error) {
+	temp, err := this.connector.Security().Access()
+	return jwt.JwtImpersonate(temp), err
+}
+
+func (this *SenergyConnector) GetDeviceType(token jwt.JwtImpersonate, id string) (dt model.DeviceType, err error) {
+	err = token.GetJSON(this.config.DeviceManagerUrl+"/device-types/"+url.PathEscape(id), &dt)
 	if err != nil {
 		log.Println("ERROR: unable to get device type", err)
 	}
 	return
 }
 
-func (this *StateRepo) GetIotDeviceTypes(jwt jwt.Jwt) (result []model.DeviceType, err error) {
-	err = jwt.Impersonate.GetJSON(this.Config.DeviceManagerUrl+"/device-types", &result)
+func (this *SenergyConnector) GetCharacteristic(token jwt.JwtImpersonate, id string) (characteristic model.Characteristic, err error) {
+	err = token.GetJSON(this.config.DeviceRepoUrl+"/characteristics/"+url.PathEscape(id), &characteristic)
+	if err != nil {
+		log.Println("WARNING: unable to get characteristic", id, err)
+	}
+	return
+}
+
+func (this *SenergyConnector) ListDeviceTypes(token jwt.JwtImpersonate) (result []model.DeviceType, err error) {
+	err = token.GetJSON(this.config.DeviceManagerUrl+"/device-types", &result)
 	if err != nil {
 		log.Println("ERROR: unable to query service", err)
 	}
 	return
 }
 
-func (this *StateRepo) GetIotDeviceTypesIds(jwt jwt.Jwt) (result []string, err error) {
+func (this *SenergyConnector) ListDeviceTypeIds() (result []string, err error) {
 	steps := 1000
 	limit := 0
 	offset := 0
 	temp := []string{}
-	c := permClient.New(this.Config.PermissionsV2Url)
+	c := permClient.New(this.config.PermissionsV2Url)
 	for len(temp) == limit {
 		limit = steps
 		temp, err, _ = c.AdminListResourceIds(permClient.InternalAdminToken, "device-types", permClient.ListOptions{
@@ -65,18 +94,18 @@ func (this *StateRepo) GetIotDeviceTypesIds(jwt jwt.Jwt) (result []string, err e
 	return
 }
 
-func (this *StateRepo) GetMosesDeviceTypesIds(jwt jwt.Jwt) (result []string, err error) {
+func (this *SenergyConnector) ListProtocolDeviceTypeIds(protocolId string) (result []string, err error) {
 	steps := 1000
 	limit := 0
 	offset := 0
 	temp := []models.DeviceType{}
-	c := deviceRepo.NewClient(this.Config.DeviceRepoUrl, nil)
+	c := deviceRepo.NewClient(this.config.DeviceRepoUrl, nil)
 	for len(temp) == limit {
 		limit = steps
 		temp, _, err, _ = c.ListDeviceTypesV3(permClient.InternalAdminToken, deviceRepo.DeviceTypeListOptions{
 			Limit:       int64(limit),
 			Offset:      int64(offset),
-			ProtocolIds: []string{this.MosesProtocolId},
+			ProtocolIds: []string{protocolId},
 			SortBy:      "name.asc",
 		})
 		if err != nil {
@@ -90,59 +119,179 @@ func (this *StateRepo) GetMosesDeviceTypesIds(jwt jwt.Jwt) (result []string, err
 	return
 }
 
-func (this *StateRepo) GenerateExternalDevice(jwt jwt.Jwt, request CreateDeviceByTypeRequest) (device model.Device, err error) {
-	deviceInp := model.Device{Name: request.Name, DeviceTypeId: request.DeviceTypeId, LocalId: uuid.NewString()}
-	err = jwt.Impersonate.PostJSON(this.Config.DeviceManagerUrl+"/devices", deviceInp, &device)
+func (this *SenergyConnector) CreateDevice(token jwt.JwtImpersonate, device model.Device) (result model.Device, err error) {
+	if device.LocalId == "" {
+		device.LocalId = uuid.NewString()
+	}
+	err = token.PostJSON(this.config.DeviceManagerUrl+"/devices", device, &result)
 	if err != nil {
-		log.Println("ERROR: unable to create device in iot repository: ", err, device)
+		log.Println("ERROR: unable to create device in iot repository: ", err, result)
 	}
 	return
 }
 
-func (this *StateRepo) DeleteExternalDevice(jwt jwt.Jwt, id string) (err error) {
-	if id != "" {
-		_, err = jwt.Impersonate.Delete(this.Config.DeviceManagerUrl + "/devices/" + url.PathEscape(id))
+func (this *SenergyConnector) GetDevice(token jwt.JwtImpersonate, id string) (device model.Device, err error) {
+	err = token.GetJSON(this.config.DeviceManagerUrl+"/devices/"+url.PathEscape(id), &device)
+	if err != nil {
+		log.Println("ERROR: unable to get device", err)
 	}
 	return
 }
 
-func (this *StateRepo) GetProtocolList(handler string) (result []models.Protocol, err error) {
-	token, err := this.Connector.Security().Access()
+func (this *SenergyConnector) CheckDevicePermission(token jwt.JwtImpersonate, id string) (access bool, exists bool, err error) {
+	access, err, code := permClient.New(this.config.PermissionsV2Url).CheckPermission(string(token), "devices", id, permClient.Read, permClient.Execute)
+	if code == http.StatusNotFound {
+		return false, false, nil
+	}
+	if code == http.StatusUnauthorized || code == http.StatusForbidden {
+		return false, true, nil
+	}
+	if err != nil {
+		log.Println("ERROR: unable to check device permissions", err)
+		return false, false, err
+	}
+	return access, true, nil
+}
+
+// a device which does not exist (anymore) counts as deleted
+func (this *SenergyConnector) DeleteDevice(token jwt.JwtImpersonate, id string) (err error) {
+	resp, err := token.Delete(this.config.DeviceManagerUrl + "/devices/" + url.PathEscape(id))
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+func (this *SenergyConnector) CreateHub(token jwt.JwtImpersonate, hub model.Hub) (result model.Hub, err error) {
+	err = token.PostJSON(this.config.DeviceManagerUrl+"/hubs", hub, &result)
+	if err != nil {
+		log.Println("ERROR: unable to create hub: ", err)
+	}
+	return
+}
+
+func (this *SenergyConnector) UpdateHub(token jwt.JwtImpersonate, hub model.Hub) (result model.Hub, err error) {
+	err = token.PutJSON(this.config.DeviceManagerUrl+"/hubs/"+url.PathEscape(hub.Id), hub, &result)
+	if err != nil {
+		log.Println("ERROR: unable to update hub: ", err)
+	}
+	return
+}
+
+func (this *SenergyConnector) DeleteHub(token jwt.JwtImpersonate, id string) (err error) {
+	_, err = token.Delete(this.config.DeviceManagerUrl + "/hubs/" + url.PathEscape(id))
+	return
+}
+
+func (this *SenergyConnector) LookupDevice(token jwt.JwtImpersonate, id string) (device model.Device, exists bool, err error) {
+	device, err = this.connector.Iot().GetDevice(id, security.JwtToken(token))
+	if errors.Is(err, security.ErrorNotFound) {
+		return device, false, nil
+	}
+	return device, err == nil, err
+}
+
+func (this *SenergyConnector) LookupDeviceType(token jwt.JwtImpersonate, id string) (deviceType model.DeviceType, exists bool, err error) {
+	deviceType, err = this.connector.Iot().GetDeviceType(id, security.JwtToken(token))
+	if errors.Is(err, security.ErrorNotFound) {
+		return deviceType, false, nil
+	}
+	return deviceType, err == nil, err
+}
+
+func (this *SenergyConnector) SendEvent(deviceRef string, serviceRef string, value interface{}) (err error) {
+	token, err := this.connector.Security().Access()
+	if err != nil {
+		log.Println("ERROR: ", err)
+		debug.PrintStack()
+		return err
+	}
+
+	msg, err := encodeSegments(value, this.config.ProtocolSegmentName)
+	if err != nil {
+		log.Println("ERROR: ", err)
+		debug.PrintStack()
+		return err
+	}
+	err = this.connector.HandleDeviceEventWithAuthToken(token, deviceRef, serviceRef, msg, platform_connector_lib.Sync)
+	if err != nil {
+		log.Println("ERROR: while sending sensor data", value, deviceRef, serviceRef, err)
+	}
+	return err
+}
+
+func (this *SenergyConnector) SetCommandHandler(handler func(deviceRef string, serviceRef string, cmdMsg interface{}, responder func(respMsg interface{}))) {
+	this.connector.SetAsyncCommandHandler(func(commandRequest model.ProtocolMsg, requestMsg platform_connector_lib.CommandRequestMsg, t time.Time) (err error) {
+		handler(commandRequest.Metadata.Device.Id, commandRequest.Metadata.Service.Id, decodeSegments(requestMsg, this.config), func(respMsg interface{}) {
+			msg, err := encodeSegments(respMsg, this.config.ProtocolSegmentName)
+			if err != nil {
+				log.Println("ERROR: ", err)
+				debug.PrintStack()
+				return
+			}
+			err = this.connector.HandleCommandResponse(commandRequest, msg, platform_connector_lib.Sync)
+			if err != nil {
+				log.Println("ERROR: ", err)
+				debug.PrintStack()
+				return
+			}
+		})
+		return nil
+	})
+}
+
+func (this *SenergyConnector) getProtocolList(handler string) (result []models.Protocol, err error) {
+	token, err := this.connector.Security().Access()
 	if err != nil {
 		debug.PrintStack()
 		return result, err
 	}
-	result, err, _ = deviceRepo.NewClient(this.Config.DeviceRepoUrl, nil).ListProtocols(string(token), 1000, 0, "name.asc")
+	protocols, err, _ := deviceRepo.NewClient(this.config.DeviceRepoUrl, nil).ListProtocols(string(token), 1000, 0, "name.asc")
+	for _, protocol := range protocols {
+		if protocol.Handler == handler {
+			result = append(result, protocol)
+		}
+	}
 	return result, err
 }
 
-func (this *StateRepo) EnsureProtocol(handler string, segments []model.ProtocolSegment) (protocolId string, err error) {
-	protocols, err := this.GetProtocolList(handler)
+// logs segments which are missing in the existing protocol; the platform drops values of unknown segments
+func logMissingProtocolSegments(protocol models.Protocol, segments []model.ProtocolSegment) {
+	known := map[string]bool{}
+	for _, segment := range protocol.ProtocolSegments {
+		known[segment.Name] = true
+	}
+	for _, segment := range segments {
+		if !known[segment.Name] {
+			log.Println("WARNING: protocol", protocol.Id, "has no segment", segment.Name)
+		}
+	}
+}
+
+func (this *SenergyConnector) EnsureProtocol(handler string, segments []model.ProtocolSegment) (protocol model.Protocol, err error) {
+	protocols, err := this.getProtocolList(handler)
 	if err != nil {
 		debug.PrintStack()
-		return protocolId, err
+		return protocol, err
 	}
 	if len(protocols) == 1 {
-		return protocols[0].Id, err
+		logMissingProtocolSegments(protocols[0], segments)
+		return protocols[0], err
 	}
 	if len(protocols) > 1 {
 		log.Println("WARNING: found multiple existing moses protocols")
-		return protocols[0].Id, err
-	}
-	protocol, err := this.CreateProtocol(handler, segments)
-	if err != nil {
-		return protocolId, err
+		logMissingProtocolSegments(protocols[0], segments)
+		return protocols[0], err
 	}
-	protocolId = protocol.Id
-	return protocolId, err
+	return this.createProtocol(handler, segments)
 }
 
-func (this *StateRepo) CreateProtocol(handler string, segments []model.ProtocolSegment) (protocol model.Protocol, err error) {
-	token, err := this.Connector.Security().Access()
+func (this *SenergyConnector) createProtocol(handler string, segments []model.ProtocolSegment) (protocol model.Protocol, err error) {
+	token, err := this.connector.Security().Access()
 	if err != nil {
 		return protocol, err
 	}
-	err = token.PostJSON(this.Config.DeviceManagerUrl+"/protocols", model.Protocol{
+	err = token.PostJSON(this.config.DeviceManagerUrl+"/protocols", model.Protocol{
 		Name:             handler,
 		Handler:          handler,
 		ProtocolSegments: segments,
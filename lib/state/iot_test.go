@@ -0,0 +1,65 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+)
+
+func TestGetExternalDevice(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch {
+		case request.URL.Path == "/check/devices/unknown":
+			http.Error(writer, "not found", http.StatusNotFound)
+		case request.URL.Path == "/check/devices/foreign":
+			json.NewEncoder(writer).Encode(false)
+		case strings.HasPrefix(request.URL.Path, "/check/devices/"):
+			if request.URL.Query().Get("permissions") != "rx" {
+				t.Error(request.URL.Query())
+			}
+			json.NewEncoder(writer).Encode(true)
+		case request.URL.Path == "/devices/own":
+			json.NewEncoder(writer).Encode(model.Device{Id: "own", Name: "lamp", DeviceTypeId: "dt"})
+		default:
+			http.Error(writer, "unexpected request", http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	repo := &StateRepo{Connector: NewSenergyConnector(nil, config.Config{PermissionsV2Url: server.URL, DeviceManagerUrl: server.URL})}
+	token := jwt.Jwt{Impersonate: "Bearer test"}
+
+	device, access, exists, err := repo.GetExternalDevice(token, "own")
+	if err != nil || !access || !exists || device.Name != "lamp" || device.DeviceTypeId != "dt" {
+		t.Error(device, access, exists, err)
+	}
+	_, access, exists, err = repo.GetExternalDevice(token, "foreign")
+	if err != nil || access || !exists {
+		t.Error(access, exists, err)
+	}
+	_, access, exists, err = repo.GetExternalDevice(token, "unknown")
+	if err != nil || access || exists {
+		t.Error(access, exists, err)
+	}
+}
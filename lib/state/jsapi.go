@@ -19,6 +19,7 @@ package state
 import (
 	"log"
 	"runtime/debug"
+	"time"
 )
 
 func (this *StateRepo) getJsWorldApi(world *World) map[string]interface{} {
@@ -35,8 +36,9 @@ func (this *StateRepo) getJsWorldSubApi(world *World) map[string]interface{} {
 					world.States = map[string]interface{}{}
 				}
 				world.States[field] = value
+				this.notifyStateChange(world, "world", world.Id, field, value)
 				if world != nil {
-					err := this.persistWorld(*world)
+					err := this.persistStates(world, "world", world.Id, world.States)
 					if err != nil {
 						log.Println("ERROR:", err)
 						debug.PrintStack()
@@ -63,6 +65,18 @@ func (this *StateRepo) getJsWorldSubApi(world *World) map[string]interface{} {
 			}
 			return this.getJsRoomSubApi(world, room)
 		},
+		"setHubOnline": func(online bool) {
+			this.setJsHubOnline(world, world.Hub, online)
+		},
+		"isHubOnline": func() bool {
+			return world.Hub != nil && !world.Hub.Offline
+		},
+		"power": func() float64 {
+			return getWorldPower(world)
+		},
+		"energy": func() float64 {
+			return getWorldEnergy(world)
+		},
 	}
 }
 
@@ -81,8 +95,9 @@ func (this *StateRepo) getJsRoomSubApi(world *World, room *Room) map[string]inte
 					room.States = map[string]interface{}{}
 				}
 				room.States[field] = value
+				this.notifyStateChange(world, "room", room.Id, field, value)
 				if world != nil {
-					err := this.persistWorld(*world)
+					err := this.persistStates(world, "room", room.Id, room.States)
 					if err != nil {
 						log.Println("ERROR:", err)
 						debug.PrintStack()
@@ -109,6 +124,18 @@ func (this *StateRepo) getJsRoomSubApi(world *World, room *Room) map[string]inte
 			}
 			return this.getJsDeviceSubApi(world, device)
 		},
+		"setHubOnline": func(online bool) {
+			this.setJsHubOnline(world, room.Hub, online)
+		},
+		"isHubOnline": func() bool {
+			return room.Hub != nil && !room.Hub.Offline
+		},
+		"power": func() float64 {
+			return getRoomPower(room)
+		},
+		"energy": func() float64 {
+			return getRoomEnergy(room)
+		},
 	}
 }
 
@@ -128,8 +155,9 @@ func (this *StateRepo) getJsDeviceSubApi(world *World, device *Device) map[strin
 					device.States = map[string]interface{}{}
 				}
 				device.States[field] = value
+				this.notifyStateChange(world, "device", device.Id, field, value)
 				if world != nil {
-					err := this.persistWorld(*world)
+					err := this.persistStates(world, "device", device.Id, device.States)
 					if err != nil {
 						log.Println("ERROR:", err)
 						debug.PrintStack()
@@ -148,6 +176,25 @@ func (this *StateRepo) getJsDeviceSubApi(world *World, device *Device) map[strin
 				return val
 			},
 		},
+		"setOnline": func(online bool) {
+			err := this.setDeviceOnline(world, device, online)
+			if err != nil {
+				log.Println("ERROR:", err)
+				debug.PrintStack()
+			}
+		},
+		"isOnline": func() bool {
+			return !device.Offline
+		},
+		"power": func() float64 {
+			return getDevicePower(device)
+		},
+		"energy": func() float64 {
+			return getDeviceEnergy(device)
+		},
+		"respond": func(id string, value interface{}) bool {
+			return this.respondDeferred(device.Id, id, value)
+		},
 	}
 }
 
@@ -156,31 +203,60 @@ func (this *StateRepo) getJsSensorApi(world *World, room *Room, device *Device,
 		"world":   this.getJsWorldSubApi(world),
 		"room":    this.getJsRoomSubApi(world, room),
 		"device":  this.getJsDeviceSubApi(world, device),
-		"service": this.getJsSensorSubApi(device, service),
+		"service": this.getJsSensorSubApi(world, device, service),
 	}
 }
 
-func (this *StateRepo) getJsSensorSubApi(device *Device, service Service) map[string]interface{} {
+func (this *StateRepo) getJsSensorSubApi(world *World, device *Device, service Service) map[string]interface{} {
 	return map[string]interface{}{
 		"send": func(value interface{}) {
-			this.sendSensorData(device, service, value)
+			this.sendSensorData(world, device, service, value)
+		},
+		"sendSegments": func(value map[string]interface{}) {
+			this.sendSensorData(world, device, service, this.toSegmentedValue(value))
 		},
 		"input": nil,
 	}
 }
 
-func (this *StateRepo) getJsCommandApi(world *World, room *Room, device *Device, cmdMsg interface{}, responder func(respMsg interface{})) map[string]interface{} {
+func (this *StateRepo) getJsCommandApi(world *World, room *Room, device *Device, service Service, cmdMsg interface{}, responder func(respMsg interface{})) map[string]interface{} {
 	return map[string]interface{}{
 		"world":   this.getJsWorldSubApi(world),
 		"room":    this.getJsRoomSubApi(world, room),
 		"device":  this.getJsDeviceSubApi(world, device),
-		"service": this.getJsCommandSubApi(cmdMsg, responder),
+		"service": this.getJsCommandSubApi(device, service, cmdMsg, responder),
 	}
 }
 
-func (this *StateRepo) getJsCommandSubApi(cmdMsg interface{}, responder func(respMsg interface{})) interface{} {
+// faults of the device are applied to all responses; responses sent later use the device version at the time they are sent
+func (this *StateRepo) getJsCommandSubApi(device *Device, service Service, cmdMsg interface{}, responder func(respMsg interface{})) interface{} {
+	input, segments := splitSegments(cmdMsg, this.Config.ProtocolSegmentName)
+	send := this.getFaultyResponder(device, service.Id, responder)
 	return map[string]interface{}{
-		"input": cmdMsg,
-		"send":  responder,
+		"input":    input,
+		"segments": segments,
+		"send":     send,
+		"sendSegments": func(value map[string]interface{}) {
+			send(this.toSegmentedValue(value))
+		},
+		"sendLater": func(value interface{}, delayMs int64) string {
+			return this.sendLater(device, service.Id, responder, value, time.Duration(delayMs)*time.Millisecond)
+		},
+		"defer": func(timeoutMs int64) map[string]interface{} {
+			id := this.deferResponse(device, service.Id, responder, time.Duration(timeoutMs)*time.Millisecond)
+			return map[string]interface{}{
+				"id": id,
+				"send": func(value interface{}) bool {
+					return this.respondDeferred(device.Id, id, value)
+				},
+				"sendSegments": func(value map[string]interface{}) bool {
+					return this.respondDeferred(device.Id, id, this.toSegmentedValue(value))
+				},
+				"cancel": func() bool {
+					_, ok := this.pendingResponses.take(id, device.Id)
+					return ok
+				},
+			}
+		},
 	}
 }
@@ -77,12 +77,12 @@ func run(code string, moses interface{}, timeout time.Duration, mux sync.Locker)
 	vm := otto.New()
 	vm.Interrupt = make(chan func(), 1) // The buffer prevents blocking
 
-	go func() {
-		time.Sleep(timeout) // Stop after two seconds
+	timer := time.AfterFunc(timeout, func() {
 		vm.Interrupt <- func() {
 			panic(halt)
 		}
-	}()
+	})
+	defer timer.Stop()
 	err = vm.Set("moses", moses)
 	if err != nil {
 		return
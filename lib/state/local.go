@@ -0,0 +1,411 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"github.com/google/uuid"
+)
+
+const defaultLocalCommandTimeout = 10 * time.Second
+
+var ErrLocalNotFound = errors.New("not found in local registry")
+
+// LocalRegistry is the content of config.LocalRegistryFile
+type LocalRegistry struct {
+	DeviceTypes     []model.DeviceType     `json:"device_types"`
+	Characteristics []model.Characteristic `json:"characteristics"`
+	Devices         []model.Device         `json:"devices"`
+	Hubs            []model.Hub            `json:"hubs"`
+}
+
+// LocalEvent is written to config.LocalEventFile for every sensor event
+type LocalEvent struct {
+	Time    time.Time   `json:"time"`
+	Device  string      `json:"device"`
+	Service string      `json:"service"`
+	Value   interface{} `json:"value"`
+}
+
+// LocalConnector replaces the platform in standalone mode
+// devices, device types and characteristics are kept in an in-process registry which is stored in config.LocalRegistryFile if set
+// sensor events are appended as json lines to config.LocalEventFile or logged if no file is set
+// commands are sent with SendCommand(); there are no permissions, every user may access every device
+type LocalConnector struct {
+	config          config.Config
+	mux             sync.Mutex
+	deviceTypes     map[string]model.DeviceType
+	characteristics map[string]model.Characteristic
+	devices         map[string]model.Device
+	hubs            map[string]model.Hub
+	commandHandler  func(deviceRef string, serviceRef string, cmdMsg interface{}, responder func(respMsg interface{}))
+	eventMux        sync.Mutex
+}
+
+func NewLocalConnector(config config.Config) (result *LocalConnector, err error) {
+	result = &LocalConnector{
+		config:          config,
+		deviceTypes:     map[string]model.DeviceType{},
+		characteristics: map[string]model.Characteristic{},
+		devices:         map[string]model.Device{},
+		hubs:            map[string]model.Hub{},
+	}
+	if config.LocalRegistryFile == "" {
+		return result, nil
+	}
+	file, err := os.Open(config.LocalRegistryFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	defer file.Close()
+	registry := LocalRegistry{}
+	err = json.NewDecoder(file).Decode(&registry)
+	if err != nil {
+		return result, err
+	}
+	for _, deviceType := range registry.DeviceTypes {
+		result.deviceTypes[deviceType.Id] = deviceType
+	}
+	for _, characteristic := range registry.Characteristics {
+		result.characteristics[characteristic.Id] = characteristic
+	}
+	for _, device := range registry.Devices {
+		result.devices[device.Id] = device
+	}
+	for _, hub := range registry.Hubs {
+		result.hubs[hub.Id] = hub
+	}
+	return result, nil
+}
+
+// expects a locked connector
+func (this *LocalConnector) persist() error {
+	if this.config.LocalRegistryFile == "" {
+		return nil
+	}
+	registry := LocalRegistry{DeviceTypes: []model.DeviceType{}, Characteristics: []model.Characteristic{}, Devices: []model.Device{}, Hubs: []model.Hub{}}
+	for _, deviceType := range this.deviceTypes {
+		registry.DeviceTypes = append(registry.DeviceTypes, deviceType)
+	}
+	for _, characteristic := range this.characteristics {
+		registry.Characteristics = append(registry.Characteristics, characteristic)
+	}
+	for _, device := range this.devices {
+		registry.Devices = append(registry.Devices, device)
+	}
+	for _, hub := range this.hubs {
+		registry.Hubs = append(registry.Hubs, hub)
+	}
+	sort.Slice(registry.DeviceTypes, func(i, j int) bool { return registry.DeviceTypes[i].Id < registry.DeviceTypes[j].Id })
+	sort.Slice(registry.Characteristics, func(i, j int) bool { return registry.Characteristics[i].Id < registry.Characteristics[j].Id })
+	sort.Slice(registry.Devices, func(i, j int) bool { return registry.Devices[i].Id < registry.Devices[j].Id })
+	sort.Slice(registry.Hubs, func(i, j int) bool { return registry.Hubs[i].Id < registry.Hubs[j].Id })
+	b, err := json.MarshalIndent(registry, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(this.config.LocalRegistryFile, b, 0644)
+}
+
+func (this *LocalConnector) Access() (token jwt.JwtImpersonate, err error) {
+	return "", nil
+}
+
+// the protocol id equals the handler; segment ids equal the segment names
+func (this *LocalConnector) EnsureProtocol(handler string, segments []model.ProtocolSegment) (protocol model.Protocol, err error) {
+	protocol = model.Protocol{Id: handler, Name: handler, Handler: handler}
+	for _, segment := range segments {
+		protocol.ProtocolSegments = append(protocol.ProtocolSegments, model.ProtocolSegment{Id: segment.Name, Name: segment.Name})
+	}
+	return protocol, nil
+}
+
+func (this *LocalConnector) GetDeviceType(token jwt.JwtImpersonate, id string) (deviceType model.DeviceType, err error) {
+	deviceType, exists, err := this.LookupDeviceType(token, id)
+	if err == nil && !exists {
+		err = ErrLocalNotFound
+	}
+	return deviceType, err
+}
+
+func (this *LocalConnector) ListDeviceTypes(token jwt.JwtImpersonate) (result []model.DeviceType, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	result = []model.DeviceType{}
+	for _, deviceType := range this.deviceTypes {
+		result = append(result, deviceType)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+func (this *LocalConnector) ListDeviceTypeIds() (result []string, err error) {
+	deviceTypes, err := this.ListDeviceTypes("")
+	for _, deviceType := range deviceTypes {
+		result = append(result, deviceType.Id)
+	}
+	return result, err
+}
+
+// services without protocol id match every protocol
+func (this *LocalConnector) ListProtocolDeviceTypeIds(protocolId string) (result []string, err error) {
+	deviceTypes, err := this.ListDeviceTypes("")
+	for _, deviceType := range deviceTypes {
+		for _, service := range deviceType.Services {
+			if service.ProtocolId == "" || service.ProtocolId == protocolId {
+				result = append(result, deviceType.Id)
+				break
+			}
+		}
+	}
+	return result, err
+}
+
+func (this *LocalConnector) GetCharacteristic(token jwt.JwtImpersonate, id string) (characteristic model.Characteristic, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	characteristic, ok := this.characteristics[id]
+	if !ok {
+		return characteristic, ErrLocalNotFound
+	}
+	return characteristic, nil
+}
+
+// creates or replaces the device type; a new id is generated if empty
+func (this *LocalConnector) SetDeviceType(deviceType model.DeviceType) (result model.DeviceType, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if deviceType.Id == "" {
+		deviceType.Id = uuid.NewString()
+	}
+	for i, service := range deviceType.Services {
+		if service.Id == "" {
+			deviceType.Services[i].Id = uuid.NewString()
+		}
+	}
+	this.deviceTypes[deviceType.Id] = deviceType
+	return deviceType, this.persist()
+}
+
+func (this *LocalConnector) ListDevices() (result []model.Device) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	result = []model.Device{}
+	for _, device := range this.devices {
+		result = append(result, device)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id < result[j].Id
+	})
+	return result
+}
+
+func (this *LocalConnector) CreateDevice(token jwt.JwtImpersonate, device model.Device) (result model.Device, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if _, ok := this.deviceTypes[device.DeviceTypeId]; !ok {
+		return result, errors.New("unknown device type: " + device.DeviceTypeId)
+	}
+	device.Id = uuid.NewString()
+	if device.LocalId == "" {
+		device.LocalId = device.Id
+	}
+	this.devices[device.Id] = device
+	return device, this.persist()
+}
+
+func (this *LocalConnector) GetDevice(token jwt.JwtImpersonate, id string) (device model.Device, err error) {
+	device, exists, err := this.LookupDevice(token, id)
+	if err == nil && !exists {
+		err = ErrLocalNotFound
+	}
+	return device, err
+}
+
+func (this *LocalConnector) CheckDevicePermission(token jwt.JwtImpersonate, id string) (access bool, exists bool, err error) {
+	_, exists, err = this.LookupDevice(token, id)
+	return exists, exists, err
+}
+
+func (this *LocalConnector) DeleteDevice(token jwt.JwtImpersonate, id string) (err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if _, ok := this.devices[id]; !ok {
+		return nil
+	}
+	delete(this.devices, id)
+	return this.persist()
+}
+
+func (this *LocalConnector) CreateHub(token jwt.JwtImpersonate, hub model.Hub) (result model.Hub, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	hub.Id = uuid.NewString()
+	this.hubs[hub.Id] = hub
+	return hub, this.persist()
+}
+
+func (this *LocalConnector) UpdateHub(token jwt.JwtImpersonate, hub model.Hub) (result model.Hub, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if _, ok := this.hubs[hub.Id]; !ok {
+		return result, ErrLocalNotFound
+	}
+	this.hubs[hub.Id] = hub
+	return hub, this.persist()
+}
+
+func (this *LocalConnector) DeleteHub(token jwt.JwtImpersonate, id string) (err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if _, ok := this.hubs[id]; !ok {
+		return nil
+	}
+	delete(this.hubs, id)
+	return this.persist()
+}
+
+func (this *LocalConnector) ListHubs() (result []model.Hub) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	result = []model.Hub{}
+	for _, hub := range this.hubs {
+		result = append(result, hub)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id < result[j].Id
+	})
+	return result
+}
+
+func (this *LocalConnector) LookupDevice(token jwt.JwtImpersonate, id string) (device model.Device, exists bool, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	device, exists = this.devices[id]
+	return device, exists, nil
+}
+
+func (this *LocalConnector) LookupDeviceType(token jwt.JwtImpersonate, id string) (deviceType model.DeviceType, exists bool, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	deviceType, exists = this.deviceTypes[id]
+	return deviceType, exists, nil
+}
+
+func (this *LocalConnector) SendEvent(deviceRef string, serviceRef string, value interface{}) (err error) {
+	b, err := json.Marshal(LocalEvent{Time: time.Now(), Device: deviceRef, Service: serviceRef, Value: value})
+	if err != nil {
+		return err
+	}
+	if this.config.LocalEventFile == "" {
+		log.Println("EVENT:", string(b))
+		return nil
+	}
+	this.eventMux.Lock()
+	defer this.eventMux.Unlock()
+	file, err := os.OpenFile(this.config.LocalEventFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(b, '\n'))
+	return err
+}
+
+func (this *LocalConnector) SetCommandHandler(handler func(deviceRef string, serviceRef string, cmdMsg interface{}, responder func(respMsg interface{}))) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.commandHandler = handler
+}
+
+// sends the command to the device service and returns the first response
+func (this *LocalConnector) SendCommand(deviceRef string, serviceRef string, cmdMsg interface{}, timeout time.Duration) (resp interface{}, exists bool, err error) {
+	this.mux.Lock()
+	handler := this.commandHandler
+	_, exists = this.devices[deviceRef]
+	this.mux.Unlock()
+	if !exists {
+		return resp, false, nil
+	}
+	if handler == nil {
+		return resp, true, errors.New("no command handler")
+	}
+	if timeout <= 0 {
+		timeout = defaultLocalCommandTimeout
+	}
+	responses := make(chan interface{}, 1)
+	go handler(deviceRef, serviceRef, cmdMsg, func(respMsg interface{}) {
+		select {
+		case responses <- respMsg:
+		default:
+		}
+	})
+	select {
+	case resp = <-responses:
+		return resp, true, nil
+	case <-time.After(timeout):
+		return resp, true, errors.New("missing command response")
+	}
+}
+
+// connection log of standalone mode
+type LocalConnectionLogger struct {
+	Debug bool
+}
+
+func (this LocalConnectionLogger) LogDeviceDisconnect(id string) error {
+	if this.Debug {
+		log.Println("DEBUG: device disconnected", id)
+	}
+	return nil
+}
+
+func (this LocalConnectionLogger) LogDeviceConnect(id string) error {
+	if this.Debug {
+		log.Println("DEBUG: device connected", id)
+	}
+	return nil
+}
+
+func (this LocalConnectionLogger) LogHubConnect(gateway string) error {
+	if this.Debug {
+		log.Println("DEBUG: hub connected", gateway)
+	}
+	return nil
+}
+
+func (this LocalConnectionLogger) LogHubDisconnect(gateway string) error {
+	if this.Debug {
+		log.Println("DEBUG: hub disconnected", gateway)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+)
+
+func TestLocalConnectorRegistry(t *testing.T) {
+	registryFile := filepath.Join(t.TempDir(), "registry.json")
+	connector, err := NewLocalConnector(config.Config{LocalRegistryFile: registryFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deviceType, err := connector.SetDeviceType(model.DeviceType{Name: "lamp", Services: []model.Service{{Name: "on"}, {Name: "off", ProtocolId: "other"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deviceType.Id == "" || deviceType.Services[0].Id == "" || deviceType.Services[1].Id == "" {
+		t.Fatal(deviceType)
+	}
+	_, err = connector.CreateDevice("", model.Device{Name: "unknown", DeviceTypeId: "unknown"})
+	if err == nil {
+		t.Error("expected error for unknown device type")
+	}
+	device, err := connector.CreateDevice("", model.Device{Name: "lamp 1", DeviceTypeId: deviceType.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewLocalConnector(config.Config{LocalRegistryFile: registryFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists, _ := reloaded.LookupDeviceType("", deviceType.Id); !exists {
+		t.Error("missing device type after reload")
+	}
+	access, exists, err := reloaded.CheckDevicePermission("", device.Id)
+	if err != nil || !access || !exists {
+		t.Error(access, exists, err)
+	}
+	ids, err := reloaded.ListProtocolDeviceTypeIds("moses")
+	if err != nil || len(ids) != 1 || ids[0] != deviceType.Id {
+		t.Error(ids, err)
+	}
+
+	err = reloaded.DeleteDevice("", device.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = reloaded.DeleteDevice("", device.Id)
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = reloaded.GetDevice("", device.Id)
+	if err != ErrLocalNotFound {
+		t.Error(err)
+	}
+}
+
+func TestLocalConnectorCommandsAndEvents(t *testing.T) {
+	eventFile := filepath.Join(t.TempDir(), "events.jsonl")
+	connector, err := NewLocalConnector(config.Config{LocalEventFile: eventFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deviceType, err := connector.SetDeviceType(model.DeviceType{Name: "lamp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	externalDevice, err := connector.CreateDevice("", model.Device{Name: "lamp", DeviceTypeId: deviceType.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &StateRepo{
+		Config:      config.Config{JsTimeout: 2 * time.Second},
+		Persistence: nopPersistence{},
+		StateLogger: LocalConnectionLogger{},
+		Connector:   connector,
+		Worlds: map[string]*World{"w": {Id: "w", mux: &sync.Mutex{}, Rooms: map[string]*Room{"r": {Id: "r", Devices: map[string]*Device{
+			"d": {Id: "d", ExternalRef: externalDevice.Id, States: map[string]interface{}{"on": false}, Services: map[string]Service{
+				"sensor":   {Id: "sensor", ExternalRef: "sensor_ref", Code: `moses.service.send({"on": moses.device.state.get("on")});`},
+				"actuator": {Id: "actuator", ExternalRef: "actuator_ref", Code: `moses.device.state.set("on", moses.service.input.on); moses.service.send({"ok": true});`},
+			}},
+		}}}}},
+	}
+	repo.Start()
+	defer repo.Stop()
+
+	resp, exists, err := connector.SendCommand(externalDevice.Id, "actuator_ref", map[string]interface{}{"on": true}, time.Second)
+	if err != nil || !exists {
+		t.Fatal(exists, err)
+	}
+	if resp.(map[string]interface{})["ok"] != true {
+		t.Error(resp)
+	}
+	_, exists, err = connector.SendCommand("unknown", "actuator_ref", nil, time.Second)
+	if err != nil || exists {
+		t.Error(exists, err)
+	}
+
+	world := repo.Worlds["w"]
+	device := world.Rooms["r"].Devices["d"]
+	err = run(device.Services["sensor"].Code, repo.getJsSensorApi(world, world.Rooms["r"], device, device.Services["sensor"]), repo.Config.JsTimeout, world.mux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(eventFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 1 {
+		t.Fatal(lines)
+	}
+	event := LocalEvent{}
+	err = json.Unmarshal([]byte(lines[0]), &event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Device != externalDevice.Id || event.Service != "sensor_ref" || event.Value.(map[string]interface{})["on"] != true {
+		t.Error(event)
+	}
+}
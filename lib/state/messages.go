@@ -54,9 +54,16 @@ type CreateRoomRequest struct {
 }
 
 type DeviceResponse struct {
-	World  string    `json:"world"`
-	Room   string    `json:"room"`
-	Device DeviceMsg `json:"device"`
+	World  string       `json:"world"`
+	Room   string       `json:"room"`
+	Device DeviceMsg    `json:"device"`
+	Status DeviceStatus `json:"status"`
+}
+
+type DeviceStatus struct {
+	Online    bool        `json:"online"`
+	Reachable bool        `json:"reachable"` //online and not behind an offline hub
+	Faults    FaultStatus `json:"faults"`
 }
 
 type UpdateDeviceRequest struct {
@@ -81,6 +88,12 @@ type CreateDeviceByTypeRequest struct {
 	Name         string `json:"name"`
 }
 
+type AdoptDeviceRequest struct {
+	ExternalId string `json:"external_id"` //id of the existing platform device
+	Room       string `json:"room"`
+	Name       string `json:"name"` //defaults to the name of the platform device
+}
+
 type UpdateServiceRequest struct {
 	Id             string `json:"id"`
 	Name           string `json:"name"`
@@ -110,12 +123,15 @@ type CreateChangeRoutineRequest struct {
 	RefId    string `json:"ref_id"`
 	Interval int64  `json:"interval"`
 	Code     string `json:"code"`
+	Template string `json:"template,omitempty"`
 }
 
 type UpdateChangeRoutineRequest struct {
 	Id       string `json:"id"`
 	Interval int64  `json:"interval"`
 	Code     string `json:"code"`
+	Disabled bool   `json:"disabled"`
+	Template string `json:"template,omitempty"`
 }
 
 type ChangeRoutineResponse struct {
@@ -124,6 +140,8 @@ type ChangeRoutineResponse struct {
 	RefId    string `json:"ref_id"`
 	Interval int64  `json:"interval"`
 	Code     string `json:"code"`
+	Disabled bool   `json:"disabled"`
+	Template string `json:"template,omitempty"`
 }
 
 type CreateTemplateRequest struct {
@@ -164,6 +182,9 @@ type WorldMsg struct {
 	States         map[string]interface{}   `json:"states"`
 	Rooms          map[string]RoomMsg       `json:"rooms"`
 	ChangeRoutines map[string]ChangeRoutine `json:"change_routines"`
+	Adapter        string                   `json:"adapter,omitempty"`
+	Webhooks       map[string]Webhook       `json:"webhooks,omitempty"`
+	Hub            *Hub                     `json:"hub,omitempty"`
 }
 
 type RoomMsg struct {
@@ -172,16 +193,26 @@ type RoomMsg struct {
 	States         map[string]interface{}   `json:"states"`
 	Devices        map[string]DeviceMsg     `json:"devices"`
 	ChangeRoutines map[string]ChangeRoutine `json:"change_routines"`
+	Hub            *Hub                     `json:"hub,omitempty"`
 }
 
 type DeviceMsg struct {
-	Id             string                   `json:"id"`
-	Name           string                   `json:"name"`
-	ExternalTypeId string                   `json:"external_type_id"`
-	ExternalRef    string                   `json:"external_ref"` //platform intern device id; 1:1
-	States         map[string]interface{}   `json:"states"`
-	ChangeRoutines map[string]ChangeRoutine `json:"change_routines"`
-	Services       map[string]Service       `json:"services"`
+	Id                 string                   `json:"id"`
+	Name               string                   `json:"name"`
+	ExternalTypeId     string                   `json:"external_type_id"`
+	ExternalRef        string                   `json:"external_ref"` //platform intern device id; 1:1
+	States             map[string]interface{}   `json:"states"`
+	ChangeRoutines     map[string]ChangeRoutine `json:"change_routines"`
+	Services           map[string]Service       `json:"services"`
+	Faults             *FaultProfile            `json:"faults,omitempty"`
+	ServiceFaults      map[string]FaultProfile  `json:"service_faults,omitempty"`
+	Offline            bool                     `json:"offline"`
+	Blueprint          string                   `json:"blueprint,omitempty"`
+	BlueprintParameter map[string]string        `json:"blueprint_parameter,omitempty"`
+	BlueprintRoutines  []string                 `json:"blueprint_routines,omitempty"`
+	Power              *PowerModel              `json:"power,omitempty"`
+	Effects            []DeviceEffect           `json:"effects,omitempty"`
+	Adapter            string                   `json:"adapter,omitempty"`
 }
 
 func jsonCopy(from interface{}, to interface{}) (err error) {
@@ -0,0 +1,282 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"errors"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+)
+
+// state keys written by the metering of devices with a PowerModel and of their rooms and worlds
+// the keys are namespaced to not overwrite states of the user
+const (
+	MeteringPowerState  = "metering_power"  //current power draw in watts
+	MeteringEnergyState = "metering_energy" //cumulative energy in kWh
+)
+
+const defaultMeteringInterval = 10 * time.Second
+
+// PowerModel describes the power draw of a device depending on its states
+type PowerModel struct {
+	OnState      string  `json:"on_state" bson:"on_state"`           //bool or number state; the device is on if the value is true or > 0; the device is always on if empty
+	StandbyWatts float64 `json:"standby_watts" bson:"standby_watts"` //power draw while off
+	OnWatts      float64 `json:"on_watts" bson:"on_watts"`           //power draw while on (at full level)
+	LevelState   string  `json:"level_state" bson:"level_state"`     //optional number state; while on, the power draw is scaled between StandbyWatts and OnWatts
+	LevelMax     float64 `json:"level_max" bson:"level_max"`         //value of LevelState for full power draw; defaults to 100
+}
+
+type DevicePowerRequest struct {
+	Device string      `json:"device"`
+	Power  *PowerModel `json:"power"` //nil removes the power model
+}
+
+// last metering time per device, room and world id
+type meteringRegistry struct {
+	mux  sync.Mutex
+	last map[string]time.Time
+}
+
+// returns the duration since the last call for the entity
+func (this *meteringRegistry) since(id string, now time.Time) (duration time.Duration, ok bool) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.last == nil {
+		this.last = map[string]time.Time{}
+	}
+	last, ok := this.last[id]
+	this.last[id] = now
+	if !ok || now.Before(last) {
+		return 0, false
+	}
+	return now.Sub(last), true
+}
+
+// the metering overwrites the reserved states; they can not be used as input of the power model
+func ValidatePowerModel(power *PowerModel) error {
+	if power == nil {
+		return nil
+	}
+	for _, key := range []string{power.OnState, power.LevelState} {
+		if key == MeteringPowerState || key == MeteringEnergyState {
+			return errors.New("power model state is reserved for metering: " + key)
+		}
+	}
+	return nil
+}
+
+func toFloat(value interface{}) (result float64, ok bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// returns the current power draw in watts
+func (this PowerModel) Watts(states map[string]interface{}) float64 {
+	if this.OnState != "" {
+		on, _ := toFloat(states[this.OnState])
+		if on <= 0 {
+			return this.StandbyWatts
+		}
+	}
+	if this.LevelState == "" {
+		return this.OnWatts
+	}
+	level, ok := toFloat(states[this.LevelState])
+	if !ok {
+		return this.OnWatts
+	}
+	levelMax := this.LevelMax
+	if levelMax <= 0 {
+		levelMax = 100
+	}
+	factor := level / levelMax
+	if factor < 0 {
+		factor = 0
+	}
+	if factor > 1 {
+		factor = 1
+	}
+	return this.StandbyWatts + (this.OnWatts-this.StandbyWatts)*factor
+}
+
+func getDevicePower(device *Device) float64 {
+	if device.Power == nil || device.Offline {
+		return 0
+	}
+	return device.Power.Watts(device.States)
+}
+
+func getDeviceEnergy(device *Device) float64 {
+	energy, _ := toFloat(device.States[MeteringEnergyState])
+	return energy
+}
+
+func getRoomPower(room *Room) (result float64) {
+	for _, device := range room.Devices {
+		result = result + getDevicePower(device)
+	}
+	return result
+}
+
+// the energy of the room is metered separately and keeps the energy of removed devices
+func getRoomEnergy(room *Room) float64 {
+	energy, _ := toFloat(room.States[MeteringEnergyState])
+	return energy
+}
+
+func getWorldPower(world *World) (result float64) {
+	for _, room := range world.Rooms {
+		result = result + getRoomPower(room)
+	}
+	return result
+}
+
+// the energy of the world is metered separately and keeps the energy of removed rooms and devices
+func getWorldEnergy(world *World) float64 {
+	energy, _ := toFloat(world.States[MeteringEnergyState])
+	return energy
+}
+
+func hasPowerModels(world *World) bool {
+	for _, room := range world.Rooms {
+		for _, device := range room.Devices {
+			if device.Power != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (this *StateRepo) getMeteringInterval() time.Duration {
+	if this.Config.MeteringInterval > 0 {
+		return time.Duration(this.Config.MeteringInterval) * time.Second
+	}
+	return defaultMeteringInterval
+}
+
+// integrates the power draw of all devices with power model, their rooms and their world since the last metering
+// and updates the power and energy states of devices, rooms and the world
+// expects the world to be locked
+func (this *StateRepo) updateWorldMetering(world *World, now time.Time) {
+	worldMetered := false
+	for _, room := range world.Rooms {
+		roomMetered := false
+		for _, device := range room.Devices {
+			if device.Power == nil {
+				continue
+			}
+			roomMetered = true
+			if device.States == nil {
+				device.States = map[string]interface{}{}
+			}
+			this.meter(device.Id, device.States, getDevicePower(device), now)
+		}
+		if roomMetered {
+			worldMetered = true
+			if room.States == nil {
+				room.States = map[string]interface{}{}
+			}
+			this.meter(room.Id, room.States, getRoomPower(room), now)
+		}
+	}
+	if worldMetered {
+		if world.States == nil {
+			world.States = map[string]interface{}{}
+		}
+		this.meter(world.Id, world.States, getWorldPower(world), now)
+	}
+}
+
+// adds the energy of the power draw of the last metering to the energy state and sets the power state to the current power draw
+// the power draw of a metering is assumed until the next metering
+func (this *StateRepo) meter(id string, states map[string]interface{}, power float64, now time.Time) {
+	if duration, ok := this.metering.since(id, now); ok {
+		last, known := toFloat(states[MeteringPowerState])
+		if !known {
+			last = power
+		}
+		energy, _ := toFloat(states[MeteringEnergyState])
+		states[MeteringEnergyState] = energy + last*duration.Hours()/1000
+	} else if _, ok := states[MeteringEnergyState]; !ok {
+		states[MeteringEnergyState] = float64(0)
+	}
+	states[MeteringPowerState] = power
+}
+
+func (this *StateRepo) startMetering(world *World) (ticker *time.Ticker, stop chan bool) {
+	return this.startWorldUpdates(world, this.getMeteringInterval(), "startMetering()", func(now time.Time) {
+		this.updateWorldMetering(world, now)
+	})
+}
+
+// calls update every interval with the locked world and persists the states of the world afterwards
+func (this *StateRepo) startWorldUpdates(world *World, interval time.Duration, locationInfoForErrorLogging string, update func(now time.Time)) (ticker *time.Ticker, stop chan bool) {
+	ticker = time.NewTicker(interval)
+	stop = make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				world.mux.Lock()
+				update(this.now())
+				err := this.persistWorldStates(world)
+				world.mux.Unlock()
+				if err != nil {
+					log.Println("ERROR:", locationInfoForErrorLogging, err)
+					debug.PrintStack()
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return
+}
+
+func (this *StateRepo) UpdateDevicePower(jwt jwt.Jwt, msg DevicePowerRequest) (device DeviceResponse, access bool, exists bool, err error) {
+	device, access, exists, err = this.ReadDevice(jwt, msg.Device)
+	if err != nil || !access || !exists {
+		return
+	}
+	err = ValidatePowerModel(msg.Power)
+	if err != nil {
+		return device, true, true, err
+	}
+	device.Device.Power = msg.Power
+	err = this.DevUpdateDevice(device.World, device.Room, device.Device)
+	return device, true, true, err
+}
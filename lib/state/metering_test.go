@@ -0,0 +1,108 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPowerModelWatts(t *testing.T) {
+	model := PowerModel{OnState: "on", StandbyWatts: 1, OnWatts: 101, LevelState: "level"}
+	if watts := model.Watts(map[string]interface{}{"on": false, "level": float64(50)}); watts != 1 {
+		t.Error(watts)
+	}
+	if watts := model.Watts(map[string]interface{}{"on": true, "level": float64(50)}); watts != 51 {
+		t.Error(watts)
+	}
+	if watts := model.Watts(map[string]interface{}{"on": true}); watts != 101 {
+		t.Error(watts)
+	}
+	if watts := (PowerModel{OnWatts: 5}).Watts(nil); watts != 5 {
+		t.Error(watts)
+	}
+}
+
+func TestValidatePowerModel(t *testing.T) {
+	if err := ValidatePowerModel(&PowerModel{OnState: "on", LevelState: "level"}); err != nil {
+		t.Error(err)
+	}
+	if err := ValidatePowerModel(nil); err != nil {
+		t.Error(err)
+	}
+	if err := ValidatePowerModel(&PowerModel{OnState: MeteringPowerState}); err == nil {
+		t.Error("expected error for reserved on_state")
+	}
+	if err := ValidatePowerModel(&PowerModel{OnState: "on", LevelState: MeteringEnergyState}); err == nil {
+		t.Error("expected error for reserved level_state")
+	}
+}
+
+func TestUpdateWorldMetering(t *testing.T) {
+	repo := &StateRepo{}
+	heater := &Device{Id: "heater", States: map[string]interface{}{"on": true}, Power: &PowerModel{OnState: "on", OnWatts: 2000}}
+	lamp := &Device{Id: "lamp", States: map[string]interface{}{"on": false}, Power: &PowerModel{OnState: "on", OnWatts: 60, StandbyWatts: 0.5}}
+	world := &World{
+		Id: "w",
+		Rooms: map[string]*Room{
+			"r1": {Id: "r1", Devices: map[string]*Device{"heater": heater}},
+			"r2": {Id: "r2", Devices: map[string]*Device{"lamp": lamp, "other": {Id: "other"}}},
+		},
+	}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo.updateWorldMetering(world, start)
+	if heater.States[MeteringPowerState] != float64(2000) || heater.States[MeteringEnergyState] != float64(0) {
+		t.Error(heater.States)
+	}
+
+	//the power draw of a metering is assumed until the next metering
+	heater.States["on"] = false
+	repo.updateWorldMetering(world, start.Add(30*time.Minute))
+	repo.updateWorldMetering(world, start.Add(time.Hour))
+
+	if energy := heater.States[MeteringEnergyState].(float64); math.Abs(energy-1) > 0.0000001 {
+		t.Error(energy)
+	}
+	if energy := lamp.States[MeteringEnergyState].(float64); math.Abs(energy-0.0005) > 0.0000001 {
+		t.Error(energy)
+	}
+	if world.States[MeteringPowerState] != float64(0.5) {
+		t.Error(world.States)
+	}
+	if energy := world.States[MeteringEnergyState].(float64); math.Abs(energy-1.0005) > 0.0000001 {
+		t.Error(energy)
+	}
+	if _, ok := world.Rooms["r2"].Devices["other"].States[MeteringPowerState]; ok {
+		t.Error("unmetered device has power state")
+	}
+
+	//energy of rooms and worlds is cumulative; states of the user are kept
+	world.States["energy"] = "user value"
+	delete(world.Rooms["r1"].Devices, "heater")
+	world.Rooms["r1"].Devices["lamp2"] = &Device{Id: "lamp2", States: map[string]interface{}{"on": true}, Power: &PowerModel{OnState: "on", OnWatts: 100}}
+	repo.updateWorldMetering(world, start.Add(2*time.Hour))
+	if energy := world.Rooms["r1"].States[MeteringEnergyState].(float64); math.Abs(energy-1) > 0.0000001 {
+		t.Error("room energy should keep the energy of removed devices", energy)
+	}
+	if energy := world.States[MeteringEnergyState].(float64); math.Abs(energy-1.001) > 0.0000001 {
+		t.Error(energy)
+	}
+	if world.States[MeteringPowerState] != float64(100.5) || world.States["energy"] != "user value" {
+		t.Error(world.States)
+	}
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"errors"
+	"log"
+	"math"
+)
+
+// implemented by persistence backends which can be the source of a migration
+type exportablePersistence interface {
+	PersistenceInterface
+	exportScenarios() ([]Scenario, error)
+	exportSnapshots() ([]WorldSnapshot, error)
+	exportBlueprints() ([]DeviceBlueprint, error)
+	exportCommandRecords() ([]CommandRecord, error)
+}
+
+// copies all worlds, graphs, templates, scenarios, snapshots, blueprints and command records from one persistence to another
+// documents of the target with the same id are replaced; other documents of the target are kept
+func MigratePersistence(from PersistenceInterface, to PersistenceInterface) (err error) {
+	source, ok := from.(exportablePersistence)
+	if !ok {
+		return errors.New("persistence is not exportable")
+	}
+	worlds, err := source.LoadWorlds()
+	if err != nil {
+		return err
+	}
+	for _, world := range worlds {
+		err = to.PersistWorld(*world)
+		if err != nil {
+			return err
+		}
+	}
+	log.Println("migrated worlds:", len(worlds))
+
+	graphs, err := source.LoadGraphs()
+	if err != nil {
+		return err
+	}
+	for _, graph := range graphs {
+		err = to.PersistGraph(*graph)
+		if err != nil {
+			return err
+		}
+	}
+	log.Println("migrated graphs:", len(graphs))
+
+	templates, err := source.GetTemplates()
+	if err != nil {
+		return err
+	}
+	for _, templ := range templates {
+		err = to.PersistTemplate(templ)
+		if err != nil {
+			return err
+		}
+	}
+	log.Println("migrated templates:", len(templates))
+
+	scenarios, err := source.exportScenarios()
+	if err != nil {
+		return err
+	}
+	for _, scenario := range scenarios {
+		err = to.PersistScenario(scenario)
+		if err != nil {
+			return err
+		}
+	}
+	log.Println("migrated scenarios:", len(scenarios))
+
+	snapshots, err := source.exportSnapshots()
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range snapshots {
+		err = to.PersistSnapshot(snapshot)
+		if err != nil {
+			return err
+		}
+	}
+	log.Println("migrated snapshots:", len(snapshots))
+
+	blueprints, err := source.exportBlueprints()
+	if err != nil {
+		return err
+	}
+	for _, blueprint := range blueprints {
+		err = to.PersistBlueprint(blueprint)
+		if err != nil {
+			return err
+		}
+	}
+	log.Println("migrated blueprints:", len(blueprints))
+
+	records, err := source.exportCommandRecords()
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		err = to.PersistCommandRecord(record, math.MaxInt32) //the source is already limited to the command history size
+		if err != nil {
+			return err
+		}
+	}
+	log.Println("migrated command records:", len(records))
+	return nil
+}
+
+// rewrites all worlds to store their states separately from the world documents
+// worlds persisted before states were stored separately are loaded with the states of their world document
+func MigrateWorldStates(persistence PersistenceInterface) (err error) {
+	worlds, err := persistence.LoadWorlds()
+	if err != nil {
+		return err
+	}
+	for _, world := range worlds {
+		err = persistence.PersistWorld(*world)
+		if err != nil {
+			return err
+		}
+	}
+	log.Println("migrated world states:", len(worlds))
+	return nil
+}
@@ -42,6 +42,8 @@ type ChangeRoutine struct {
 	Id       string `json:"id" bson:"id"`
 	Interval int64  `json:"interval" bson:"interval"`
 	Code     string `json:"code" bson:"code"`
+	Disabled bool   `json:"disabled" bson:"disabled"`
+	Template string `json:"template,omitempty" bson:"template,omitempty"` //id of the RoutineTemplate the code was rendered from
 }
 
 type RoutineTemplate struct {
@@ -59,6 +61,9 @@ type World struct {
 	States         map[string]interface{}   `json:"states" bson:"states"`
 	Rooms          map[string]*Room         `json:"rooms" bson:"rooms"`
 	ChangeRoutines map[string]ChangeRoutine `json:"change_routines" bson:"change_routines"`
+	Adapter        string                   `json:"adapter,omitempty" bson:"adapter,omitempty"` //output adapter of the devices; defaults to "platform"
+	Webhooks       map[string]Webhook       `json:"webhooks,omitempty" bson:"webhooks,omitempty"`
+	Hub            *Hub                     `json:"hub,omitempty" bson:"hub,omitempty"`
 	mux            *sync.Mutex              `json:"-" bson:"-"`
 }
 
@@ -76,6 +81,7 @@ type Room struct {
 	States         map[string]interface{}   `json:"states" bson:"states"`
 	Devices        map[string]*Device       `json:"devices" bson:"devices"`
 	ChangeRoutines map[string]ChangeRoutine `json:"change_routines" bson:"change_routines"`
+	Hub            *Hub                     `json:"hub,omitempty" bson:"hub,omitempty"`
 }
 
 func (this *Room) CleanStates() {
@@ -87,14 +93,23 @@ func (this *Room) CleanStates() {
 }
 
 type Device struct {
-	Id             string                   `json:"id" bson:"id"`
-	Name           string                   `json:"name" bson:"name"`
-	ImageUrl       string                   `json:"image_url" bson:"image_url"`
-	ExternalTypeId string                   `json:"external_type_id" bson:"external_type_id"`
-	ExternalRef    string                   `json:"external_ref" bson:"external_ref"` //platform intern device id; 1:1
-	States         map[string]interface{}   `json:"states" bson:"states"`
-	ChangeRoutines map[string]ChangeRoutine `json:"change_routines" bson:"change_routines"`
-	Services       map[string]Service       `json:"services" bson:"services"`
+	Id                 string                   `json:"id" bson:"id"`
+	Name               string                   `json:"name" bson:"name"`
+	ImageUrl           string                   `json:"image_url" bson:"image_url"`
+	ExternalTypeId     string                   `json:"external_type_id" bson:"external_type_id"`
+	ExternalRef        string                   `json:"external_ref" bson:"external_ref"` //platform intern device id; 1:1
+	States             map[string]interface{}   `json:"states" bson:"states"`
+	ChangeRoutines     map[string]ChangeRoutine `json:"change_routines" bson:"change_routines"`
+	Services           map[string]Service       `json:"services" bson:"services"`
+	Faults             *FaultProfile            `json:"faults,omitempty" bson:"faults,omitempty"`
+	ServiceFaults      map[string]FaultProfile  `json:"service_faults,omitempty" bson:"service_faults,omitempty"`           //service id -> fault profile; replaces Faults for this service
+	Offline            bool                     `json:"offline" bson:"offline"`                                             //offline devices neither send sensor data nor handle commands
+	Blueprint          string                   `json:"blueprint,omitempty" bson:"blueprint,omitempty"`                     //id of the DeviceBlueprint the device was created from
+	BlueprintParameter map[string]string        `json:"blueprint_parameter,omitempty" bson:"blueprint_parameter,omitempty"` //parameter used to render the blueprint
+	BlueprintRoutines  []string                 `json:"blueprint_routines,omitempty" bson:"blueprint_routines,omitempty"`   //ids of the change routines created from the blueprint, by index in DeviceBlueprint.ChangeRoutines
+	Power              *PowerModel              `json:"power,omitempty" bson:"power,omitempty"`                             //enables metering of the device
+	Effects            []DeviceEffect           `json:"effects,omitempty" bson:"effects,omitempty"`                         //declarative changes of device, room or world states
+	Adapter            string                   `json:"adapter,omitempty" bson:"adapter,omitempty"`                         //output adapter of the device; overwrites the adapter of the world
 }
 
 func (this *Device) CleanStates() {
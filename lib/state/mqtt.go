@@ -0,0 +1,247 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/config"
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// topic templates with the parameters world, device, device_ref, device_name, service, service_ref and service_name
+const (
+	defaultMqttSensorTopic   = "moses/{{device}}/{{service}}/event"
+	defaultMqttCommandTopic  = "moses/{{device}}/{{service}}/cmd"
+	defaultMqttResponseTopic = "moses/{{device}}/{{service}}/resp"
+)
+
+const mqttTimeout = 10 * time.Second
+
+type mqttClient interface {
+	Publish(topic string, qos byte, payload []byte) error
+	Subscribe(topic string, qos byte, handler func(topic string, payload []byte)) error
+	Unsubscribe(topics ...string) error
+}
+
+// MqttAdapter publishes sensor data as json to the sensor topic of the service
+// commands are received on the command topic of the service; responses are published to its response topic
+type MqttAdapter struct {
+	client         mqttClient
+	qos            byte
+	sensorTopic    string
+	commandTopic   string
+	responseTopic  string
+	mux            sync.Mutex
+	subscribeMux   sync.Mutex               //serializes changes of the subscriptions; not used by handleCommand
+	commandTargets map[string]AdapterTarget //command topic -> target
+	handler        AdapterCommandHandler
+}
+
+func NewMqttAdapter(config config.Config) (result *MqttAdapter, err error) {
+	result = newMqttAdapter(nil, config)
+	options := paho.NewClientOptions().
+		AddBroker(config.MqttBrokerUrl).
+		SetClientID(config.MqttClientId).
+		SetUsername(config.MqttUser).
+		SetPassword(config.MqttPw).
+		SetAutoReconnect(true).
+		SetCleanSession(true).
+		SetOnConnectHandler(func(paho.Client) {
+			result.resubscribe()
+		})
+	client := paho.NewClient(options)
+	result.client = pahoMqttClient{client: client}
+	token := client.Connect()
+	if !token.WaitTimeout(mqttTimeout) {
+		return result, errors.New("mqtt connection timeout")
+	}
+	return result, token.Error()
+}
+
+func newMqttAdapter(client mqttClient, config config.Config) *MqttAdapter {
+	result := &MqttAdapter{
+		client:         client,
+		qos:            byte(config.MqttQos),
+		sensorTopic:    config.MqttSensorTopic,
+		commandTopic:   config.MqttCommandTopic,
+		responseTopic:  config.MqttResponseTopic,
+		commandTargets: map[string]AdapterTarget{},
+	}
+	if result.sensorTopic == "" {
+		result.sensorTopic = defaultMqttSensorTopic
+	}
+	if result.commandTopic == "" {
+		result.commandTopic = defaultMqttCommandTopic
+	}
+	if result.responseTopic == "" {
+		result.responseTopic = defaultMqttResponseTopic
+	}
+	return result
+}
+
+func (this *MqttAdapter) SendSensorData(target AdapterTarget, value interface{}) error {
+	topic, err := RenderTempl(this.sensorTopic, target.templateParameter())
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return this.client.Publish(topic, this.qos, payload)
+}
+
+// the subscriptions are changed without holding the lock, because handleCommand is called by the message router of the client
+// which also has to process the acknowledgements of the subscriptions
+func (this *MqttAdapter) SetCommandTargets(targets []AdapterTarget, handler AdapterCommandHandler) error {
+	commandTargets := map[string]AdapterTarget{}
+	for _, target := range targets {
+		topic, err := RenderTempl(this.commandTopic, target.templateParameter())
+		if err != nil {
+			return err
+		}
+		commandTargets[topic] = target
+	}
+	this.subscribeMux.Lock()
+	defer this.subscribeMux.Unlock()
+	this.mux.Lock()
+	this.handler = handler
+	outdated := []string{}
+	added := []string{}
+	for topic := range this.commandTargets {
+		if _, ok := commandTargets[topic]; !ok {
+			outdated = append(outdated, topic)
+			delete(this.commandTargets, topic)
+		}
+	}
+	for topic, target := range commandTargets {
+		if _, ok := this.commandTargets[topic]; !ok {
+			added = append(added, topic)
+		}
+		this.commandTargets[topic] = target
+	}
+	this.mux.Unlock()
+
+	var result error
+	if len(outdated) > 0 {
+		err := this.client.Unsubscribe(outdated...)
+		if err != nil {
+			result = err
+		}
+	}
+	for _, topic := range added {
+		err := this.client.Subscribe(topic, this.qos, this.handleCommand)
+		if err != nil {
+			//the topic is subscribed again by the next call
+			log.Println("ERROR: unable to subscribe mqtt command topic", topic, err)
+			this.mux.Lock()
+			delete(this.commandTargets, topic)
+			this.mux.Unlock()
+			result = err
+		}
+	}
+	return result
+}
+
+// subscribes all command topics; used after a reconnect to the broker
+func (this *MqttAdapter) resubscribe() {
+	this.subscribeMux.Lock()
+	defer this.subscribeMux.Unlock()
+	this.mux.Lock()
+	topics := []string{}
+	for topic := range this.commandTargets {
+		topics = append(topics, topic)
+	}
+	this.mux.Unlock()
+	for _, topic := range topics {
+		err := this.client.Subscribe(topic, this.qos, this.handleCommand)
+		if err != nil {
+			log.Println("ERROR: unable to subscribe mqtt command topic", topic, err)
+		}
+	}
+}
+
+// payloads which are no valid json are passed as string
+func (this *MqttAdapter) handleCommand(topic string, payload []byte) {
+	this.mux.Lock()
+	target, ok := this.commandTargets[topic]
+	handler := this.handler
+	this.mux.Unlock()
+	if !ok || handler == nil {
+		log.Println("WARNING: ignore mqtt command on unknown topic", topic)
+		return
+	}
+	var cmdMsg interface{}
+	err := json.Unmarshal(payload, &cmdMsg)
+	if err != nil {
+		cmdMsg = string(payload)
+	}
+	handler(target, cmdMsg, func(respMsg interface{}) {
+		err := this.respond(target, respMsg)
+		if err != nil {
+			log.Println("ERROR: unable to send mqtt command response", target.Device, target.Service, err)
+		}
+	})
+}
+
+func (this *MqttAdapter) respond(target AdapterTarget, respMsg interface{}) error {
+	topic, err := RenderTempl(this.responseTopic, target.templateParameter())
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(respMsg)
+	if err != nil {
+		return err
+	}
+	return this.client.Publish(topic, this.qos, payload)
+}
+
+func (this *MqttAdapter) Close() {
+	if client, ok := this.client.(pahoMqttClient); ok {
+		client.client.Disconnect(250)
+	}
+}
+
+type pahoMqttClient struct {
+	client paho.Client
+}
+
+func (this pahoMqttClient) Publish(topic string, qos byte, payload []byte) error {
+	return this.wait(this.client.Publish(topic, qos, false, payload))
+}
+
+func (this pahoMqttClient) Subscribe(topic string, qos byte, handler func(topic string, payload []byte)) error {
+	return this.wait(this.client.Subscribe(topic, qos, func(client paho.Client, message paho.Message) {
+		handler(message.Topic(), message.Payload())
+	}))
+}
+
+func (this pahoMqttClient) Unsubscribe(topics ...string) error {
+	return this.wait(this.client.Unsubscribe(topics...))
+}
+
+func (this pahoMqttClient) wait(token paho.Token) error {
+	if !token.WaitTimeout(mqttTimeout) {
+		return errors.New("mqtt timeout")
+	}
+	return token.Error()
+}
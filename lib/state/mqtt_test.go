@@ -0,0 +1,234 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/config"
+)
+
+// in memory broker without wildcard support
+type testMqttBroker struct {
+	mux           sync.Mutex
+	subscriptions map[string]func(topic string, payload []byte)
+	published     chan testMqttMessage
+}
+
+type testMqttMessage struct {
+	topic   string
+	payload string
+}
+
+func newTestMqttBroker() *testMqttBroker {
+	return &testMqttBroker{subscriptions: map[string]func(topic string, payload []byte){}, published: make(chan testMqttMessage, 100)}
+}
+
+func (this *testMqttBroker) Publish(topic string, qos byte, payload []byte) error {
+	this.mux.Lock()
+	handler, ok := this.subscriptions[topic]
+	this.mux.Unlock()
+	if ok {
+		go handler(topic, payload)
+	}
+	this.published <- testMqttMessage{topic: topic, payload: string(payload)}
+	return nil
+}
+
+func (this *testMqttBroker) Subscribe(topic string, qos byte, handler func(topic string, payload []byte)) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.subscriptions[topic] = handler
+	return nil
+}
+
+func (this *testMqttBroker) Unsubscribe(topics ...string) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for _, topic := range topics {
+		delete(this.subscriptions, topic)
+	}
+	return nil
+}
+
+func (this *testMqttBroker) next(t *testing.T) testMqttMessage {
+	select {
+	case msg := <-this.published:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("missing mqtt message")
+		return testMqttMessage{}
+	}
+}
+
+func TestMqttAdapter(t *testing.T) {
+	broker := newTestMqttBroker()
+	repo := &StateRepo{
+		Config:      config.Config{JsTimeout: 2 * time.Second},
+		Persistence: nopPersistence{},
+		StateLogger: nopLogger{},
+		Adapters:    map[string]OutputAdapter{AdapterMqtt: newMqttAdapter(broker, config.Config{})},
+		Worlds: map[string]*World{"w": {Id: "w", Adapter: AdapterMqtt, mux: &sync.Mutex{}, Rooms: map[string]*Room{"r": {Id: "r", Devices: map[string]*Device{
+			"d": {Id: "d", ExternalRef: "dref", States: map[string]interface{}{"on": false}, Services: map[string]Service{
+				"sensor":   {Id: "sensor", Code: `moses.service.send({"on": moses.device.state.get("on")});`},
+				"actuator": {Id: "actuator", ExternalRef: "aref", Code: `moses.device.state.set("on", moses.service.input.on); moses.service.send({"ok": true});`},
+			}},
+		}}}}},
+	}
+	repo.Start()
+	defer repo.Stop()
+	if len(broker.subscriptions) != 2 || broker.subscriptions["moses/d/actuator/cmd"] == nil {
+		t.Fatal(broker.subscriptions)
+	}
+
+	repo.HandleCommand("dref", "aref", map[string]interface{}{"on": true}, func(respMsg interface{}) {
+		t.Error("unexpected platform command response", respMsg)
+	})
+	if repo.Worlds["w"].Rooms["r"].Devices["d"].States["on"] != false {
+		t.Error("platform command should be ignored for mqtt devices")
+	}
+
+	err := broker.Publish("moses/d/actuator/cmd", 0, []byte(`{"on": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker.next(t)
+	msg := broker.next(t)
+	if msg.topic != "moses/d/actuator/resp" || msg.payload != `{"ok":true}` {
+		t.Error(msg)
+	}
+	if len(repo.sensorEvents.list("sensor")) != 0 {
+		t.Error(repo.sensorEvents.list("sensor"))
+	}
+
+	world := repo.Worlds["w"]
+	device := world.Rooms["r"].Devices["d"]
+	err = run(device.Services["sensor"].Code, repo.getJsSensorApi(world, world.Rooms["r"], device, device.Services["sensor"]), repo.Config.JsTimeout, world.mux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg = broker.next(t)
+	value := map[string]interface{}{}
+	err = json.Unmarshal([]byte(msg.payload), &value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.topic != "moses/d/sensor/event" || value["on"] != true {
+		t.Error(msg)
+	}
+	events := repo.sensorEvents.list("sensor")
+	if len(events) != 1 || events[0].Error != "" {
+		t.Error(events)
+	}
+}
+
+func TestMqttAdapterTargets(t *testing.T) {
+	broker := newTestMqttBroker()
+	adapter := newMqttAdapter(broker, config.Config{MqttCommandTopic: "{{device_ref}}/{{service_ref}}/cmd"})
+	handler := func(target AdapterTarget, cmdMsg interface{}, responder func(respMsg interface{})) {}
+	err := adapter.SetCommandTargets([]AdapterTarget{{DeviceRef: "a", ServiceRef: "1"}, {DeviceRef: "b", ServiceRef: "1"}}, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = adapter.SetCommandTargets([]AdapterTarget{{DeviceRef: "b", ServiceRef: "1"}, {DeviceRef: "c", ServiceRef: "1"}}, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(broker.subscriptions) != 2 || broker.subscriptions["b/1/cmd"] == nil || broker.subscriptions["c/1/cmd"] == nil {
+		t.Error(broker.subscriptions)
+	}
+}
+
+func TestDeviceAdapterName(t *testing.T) {
+	if name := getDeviceAdapterName(&World{}, &Device{}); name != AdapterPlatform {
+		t.Error(name)
+	}
+	if name := getDeviceAdapterName(&World{Adapter: AdapterMqtt}, &Device{}); name != AdapterMqtt {
+		t.Error(name)
+	}
+	if name := getDeviceAdapterName(&World{Adapter: AdapterMqtt}, &Device{Adapter: AdapterPlatform}); name != AdapterPlatform {
+		t.Error(name)
+	}
+	repo := &StateRepo{}
+	if repo.ValidateAdapter(AdapterMqtt) == nil || repo.ValidateAdapter("") != nil {
+		t.Error("unexpected adapter validation")
+	}
+}
+
+// delivers a command to all subscribed topics while subscribing like the message router of a client, which also processes the acknowledgements
+type routerTestMqttClient struct {
+	*testMqttBroker
+	fail map[string]bool
+}
+
+func (this *routerTestMqttClient) Subscribe(topic string, qos byte, handler func(topic string, payload []byte)) error {
+	if this.fail[topic] {
+		return errors.New("test error")
+	}
+	this.mux.Lock()
+	subscriptions := map[string]func(topic string, payload []byte){}
+	for key, value := range this.subscriptions {
+		subscriptions[key] = value
+	}
+	this.mux.Unlock()
+	done := make(chan bool)
+	go func() {
+		for key, subscriptionHandler := range subscriptions {
+			subscriptionHandler(key, []byte(`{}`))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		return errors.New("message router is blocked")
+	}
+	return this.testMqttBroker.Subscribe(topic, qos, handler)
+}
+
+func TestMqttAdapterSubscribe(t *testing.T) {
+	client := &routerTestMqttClient{testMqttBroker: newTestMqttBroker(), fail: map[string]bool{"b/1/cmd": true}}
+	adapter := newMqttAdapter(client, config.Config{MqttCommandTopic: "{{device_ref}}/{{service_ref}}/cmd"})
+	commands := make(chan string, 10)
+	handler := func(target AdapterTarget, cmdMsg interface{}, responder func(respMsg interface{})) {
+		commands <- target.DeviceRef
+	}
+	targets := []AdapterTarget{{DeviceRef: "a", ServiceRef: "1"}, {DeviceRef: "b", ServiceRef: "1"}, {DeviceRef: "c", ServiceRef: "1"}}
+	err := adapter.SetCommandTargets(targets, handler)
+	if err == nil {
+		t.Error("expected subscription error")
+	}
+	if len(client.subscriptions) != 2 || client.subscriptions["b/1/cmd"] != nil {
+		t.Error(client.subscriptions)
+	}
+	if len(commands) == 0 {
+		t.Error("commands should be handled while subscribing")
+	}
+
+	delete(client.fail, "b/1/cmd")
+	err = adapter.SetCommandTargets(targets, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(client.subscriptions) != 3 || client.subscriptions["b/1/cmd"] == nil {
+		t.Error("failed subscriptions should be retried", client.subscriptions)
+	}
+}
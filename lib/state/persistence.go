@@ -17,6 +17,7 @@
 package state
 
 import (
+	"errors"
 	"github.com/SENERGY-Platform/moses/lib/config"
 	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
@@ -25,6 +26,7 @@ import (
 
 type PersistenceInterface interface {
 	PersistWorld(world World) (err error)
+	PersistStates(states []EntityStates) (err error) //updates the states of single entities without rewriting the world document
 	PersistGraph(graph Graph) (err error)
 	PersistTemplate(templ RoutineTemplate) error
 	LoadWorlds() (map[string]*World, error)
@@ -34,28 +36,89 @@ type PersistenceInterface interface {
 	DeleteWorld(id string) error
 	DeleteGraph(id string) error
 	DeleteTemplate(id string) error
+	PersistScenario(scenario Scenario) error
+	GetScenario(id string) (scenario Scenario, err error)
+	GetScenarios(owner string) (scenarios []Scenario, err error)
+	DeleteScenario(id string) error
+	PersistSnapshot(snapshot WorldSnapshot) error
+	GetSnapshot(id string) (snapshot WorldSnapshot, err error)
+	GetSnapshots(worldId string) (snapshots []WorldSnapshot, err error) //without data
+	DeleteSnapshot(id string) error
+	DeleteWorldSnapshots(worldId string) error
+	PersistBlueprint(blueprint DeviceBlueprint) error
+	GetBlueprint(id string) (blueprint DeviceBlueprint, err error)
+	GetBlueprints(owner string) (blueprints []DeviceBlueprint, err error)
+	DeleteBlueprint(id string) error
+	PersistCommandRecord(record CommandRecord, historySize int) error                 //keeps the newest historySize records of the device
+	GetCommandRecords(query CommandHistoryQuery) (records []CommandRecord, err error) //newest first
+}
+
+const (
+	PersistenceMongo = "mongodb"
+	PersistenceBolt  = "bolt"
+)
+
+type ClosablePersistence interface {
+	PersistenceInterface
+	Close()
+}
+
+// creates the persistence of the given backend; "" -> "mongodb"
+func NewPersistence(config config.Config, backend string) (result ClosablePersistence, err error) {
+	switch backend {
+	case PersistenceMongo, "":
+		mongo, err := NewMongoPersistence(config)
+		return &mongo, err
+	case PersistenceBolt:
+		bolt, err := NewBoltPersistence(config)
+		return &bolt, err
+	default:
+		return nil, errors.New("unknown persistence backend: " + backend)
+	}
 }
 
 type MongoPersistence struct {
-	session                *mgo.Session
-	worldCollectionName    string
-	graphCollectionName    string
-	templateCollectionName string
-	tableName              string
+	session                 *mgo.Session
+	worldCollectionName     string
+	graphCollectionName     string
+	templateCollectionName  string
+	scenarioCollectionName  string
+	snapshotCollectionName  string
+	blueprintCollectionName string
+	commandCollectionName   string
+	stateCollectionName     string
+	tableName               string
 }
 
 func NewMongoPersistence(config config.Config) (result MongoPersistence, err error) {
 	result.worldCollectionName = config.WorldCollectionName
 	result.graphCollectionName = config.GraphCollectionName
 	result.templateCollectionName = config.TemplateCollectionName
+	result.scenarioCollectionName = config.ScenarioCollectionName
+	result.snapshotCollectionName = config.SnapshotCollectionName
+	result.blueprintCollectionName = config.BlueprintCollectionName
+	result.commandCollectionName = config.CommandCollectionName
+	result.stateCollectionName = config.StateCollectionName
 	result.tableName = config.MongoTable
 	result.session, err = mgo.Dial(config.MongoUrl)
-	if err == nil {
-		result.session.SetMode(mgo.Monotonic, true)
+	if err != nil {
+		return result, err
 	}
+	result.session.SetMode(mgo.Monotonic, true)
+	err = result.ensureStateIndex()
 	return
 }
 
+// states are upserted by world and ref_id; the unique index keeps the upserts fast and prevents duplicates of concurrent upserts
+func (this MongoPersistence) ensureStateIndex() error {
+	session, collection := this.getStateCollection()
+	defer session.Close()
+	return collection.EnsureIndex(mgo.Index{
+		Key:    []string{"world", "ref_id"},
+		Unique: true,
+	})
+}
+
 func (this *MongoPersistence) Close() {
 	this.session.Close()
 }
@@ -78,11 +141,73 @@ func (this MongoPersistence) getTemplateCollection() (session *mgo.Session, coll
 	return
 }
 
+func (this MongoPersistence) getScenarioCollection() (session *mgo.Session, collection *mgo.Collection) {
+	session = this.session.Copy()
+	collection = session.DB(this.tableName).C(this.scenarioCollectionName)
+	return
+}
+
+func (this MongoPersistence) getSnapshotCollection() (session *mgo.Session, collection *mgo.Collection) {
+	session = this.session.Copy()
+	collection = session.DB(this.tableName).C(this.snapshotCollectionName)
+	return
+}
+
+func (this MongoPersistence) getBlueprintCollection() (session *mgo.Session, collection *mgo.Collection) {
+	session = this.session.Copy()
+	collection = session.DB(this.tableName).C(this.blueprintCollectionName)
+	return
+}
+
+func (this MongoPersistence) getCommandCollection() (session *mgo.Session, collection *mgo.Collection) {
+	session = this.session.Copy()
+	collection = session.DB(this.tableName).C(this.commandCollectionName)
+	return
+}
+
+func (this MongoPersistence) getStateCollection() (session *mgo.Session, collection *mgo.Collection) {
+	session = this.session.Copy()
+	collection = session.DB(this.tableName).C(this.stateCollectionName)
+	return
+}
+
+// the states of the world are stored in the state collection; the world document is stored without states
 func (this MongoPersistence) PersistWorld(world World) (err error) {
-	session, collection := this.getWorldCollection()
 	world.CleanStates()
+	err = this.PersistStates(getEntityStates(world))
+	if err != nil {
+		return err
+	}
+	err = this.removeOutdatedStates(world)
+	if err != nil {
+		return err
+	}
+	session, collection := this.getWorldCollection()
+	defer session.Close()
+	_, err = collection.Upsert(bson.M{"id": world.Id}, getWorldDocument(world))
+	return
+}
+
+func (this MongoPersistence) PersistStates(states []EntityStates) (err error) {
+	if len(states) == 0 {
+		return nil
+	}
+	session, collection := this.getStateCollection()
+	defer session.Close()
+	bulk := collection.Bulk()
+	bulk.Unordered()
+	for _, element := range states {
+		bulk.Upsert(bson.M{"world": element.World, "ref_id": element.RefId}, bson.M{"$set": element})
+	}
+	_, err = bulk.Run()
+	return
+}
+
+// removes the states of deleted rooms and devices
+func (this MongoPersistence) removeOutdatedStates(world World) (err error) {
+	session, collection := this.getStateCollection()
 	defer session.Close()
-	_, err = collection.Upsert(bson.M{"id": world.Id}, world)
+	_, err = collection.RemoveAll(bson.M{"world": world.Id, "ref_id": bson.M{"$nin": getEntityStateIds(world)}})
 	return
 }
 
@@ -123,10 +248,22 @@ func (this MongoPersistence) LoadWorlds() (result map[string]*World, err error)
 	if err != nil {
 		return result, err
 	}
+	stateSession, stateCollection := this.getStateCollection()
+	defer stateSession.Close()
+	states := []EntityStates{}
+	err = stateCollection.Find(nil).All(&states)
+	if err != nil {
+		return result, err
+	}
+	worldStates := map[string][]EntityStates{}
+	for _, element := range states {
+		worldStates[element.World] = append(worldStates[element.World], element)
+	}
 	for _, world := range worlds {
 		var tempWorld World
 		tempWorld = world
 		tempWorld.mux = &sync.Mutex{}
+		applyEntityStates(&tempWorld, worldStates[world.Id])
 		tempWorld.CleanStates()
 		result[tempWorld.Id] = &tempWorld
 	}
@@ -154,6 +291,12 @@ func (this MongoPersistence) DeleteWorld(id string) (err error) {
 	session, collection := this.getWorldCollection()
 	defer session.Close()
 	_, err = collection.RemoveAll(bson.M{"id": id})
+	if err != nil {
+		return err
+	}
+	stateSession, stateCollection := this.getStateCollection()
+	defer stateSession.Close()
+	_, err = stateCollection.RemoveAll(bson.M{"world": id})
 	return
 }
 
@@ -170,3 +313,163 @@ func (this MongoPersistence) DeleteTemplate(id string) (err error) {
 	_, err = collection.RemoveAll(bson.M{"id": id})
 	return
 }
+
+func (this MongoPersistence) PersistScenario(scenario Scenario) (err error) {
+	session, collection := this.getScenarioCollection()
+	defer session.Close()
+	_, err = collection.Upsert(bson.M{"id": scenario.Id}, scenario)
+	return
+}
+
+func (this MongoPersistence) GetScenario(id string) (scenario Scenario, err error) {
+	session, collection := this.getScenarioCollection()
+	defer session.Close()
+	err = collection.Find(bson.M{"id": id}).One(&scenario)
+	return
+}
+
+func (this MongoPersistence) GetScenarios(owner string) (scenarios []Scenario, err error) {
+	session, collection := this.getScenarioCollection()
+	defer session.Close()
+	err = collection.Find(bson.M{"owner": owner}).All(&scenarios)
+	return
+}
+
+func (this MongoPersistence) DeleteScenario(id string) (err error) {
+	session, collection := this.getScenarioCollection()
+	defer session.Close()
+	_, err = collection.RemoveAll(bson.M{"id": id})
+	return
+}
+
+func (this MongoPersistence) PersistSnapshot(snapshot WorldSnapshot) (err error) {
+	session, collection := this.getSnapshotCollection()
+	defer session.Close()
+	_, err = collection.Upsert(bson.M{"id": snapshot.Id}, snapshot)
+	return
+}
+
+func (this MongoPersistence) GetSnapshot(id string) (snapshot WorldSnapshot, err error) {
+	session, collection := this.getSnapshotCollection()
+	defer session.Close()
+	err = collection.Find(bson.M{"id": id}).One(&snapshot)
+	return
+}
+
+func (this MongoPersistence) GetSnapshots(worldId string) (snapshots []WorldSnapshot, err error) {
+	session, collection := this.getSnapshotCollection()
+	defer session.Close()
+	err = collection.Find(bson.M{"world": worldId}).Select(bson.M{"data": 0}).Sort("created").All(&snapshots)
+	return
+}
+
+func (this MongoPersistence) DeleteSnapshot(id string) (err error) {
+	session, collection := this.getSnapshotCollection()
+	defer session.Close()
+	_, err = collection.RemoveAll(bson.M{"id": id})
+	return
+}
+
+func (this MongoPersistence) DeleteWorldSnapshots(worldId string) (err error) {
+	session, collection := this.getSnapshotCollection()
+	defer session.Close()
+	_, err = collection.RemoveAll(bson.M{"world": worldId})
+	return
+}
+
+func (this MongoPersistence) PersistBlueprint(blueprint DeviceBlueprint) (err error) {
+	session, collection := this.getBlueprintCollection()
+	defer session.Close()
+	_, err = collection.Upsert(bson.M{"id": blueprint.Id}, blueprint)
+	return
+}
+
+func (this MongoPersistence) GetBlueprint(id string) (blueprint DeviceBlueprint, err error) {
+	session, collection := this.getBlueprintCollection()
+	defer session.Close()
+	err = collection.Find(bson.M{"id": id}).One(&blueprint)
+	return
+}
+
+func (this MongoPersistence) GetBlueprints(owner string) (blueprints []DeviceBlueprint, err error) {
+	session, collection := this.getBlueprintCollection()
+	defer session.Close()
+	err = collection.Find(bson.M{"owner": owner}).All(&blueprints)
+	return
+}
+
+func (this MongoPersistence) DeleteBlueprint(id string) (err error) {
+	session, collection := this.getBlueprintCollection()
+	defer session.Close()
+	_, err = collection.RemoveAll(bson.M{"id": id})
+	return
+}
+
+func (this MongoPersistence) PersistCommandRecord(record CommandRecord, historySize int) (err error) {
+	session, collection := this.getCommandCollection()
+	defer session.Close()
+	_, err = collection.Upsert(bson.M{"id": record.Id}, record)
+	if err != nil {
+		return err
+	}
+	outdated := []CommandRecord{}
+	err = collection.Find(bson.M{"device": record.Device}).Sort("-time").Skip(historySize).Select(bson.M{"id": 1}).All(&outdated)
+	if err != nil || len(outdated) == 0 {
+		return err
+	}
+	ids := []string{}
+	for _, element := range outdated {
+		ids = append(ids, element.Id)
+	}
+	_, err = collection.RemoveAll(bson.M{"id": bson.M{"$in": ids}})
+	return
+}
+
+func (this MongoPersistence) GetCommandRecords(query CommandHistoryQuery) (records []CommandRecord, err error) {
+	session, collection := this.getCommandCollection()
+	defer session.Close()
+	selection := bson.M{"device": query.Device}
+	if query.Service != "" {
+		selection["service"] = query.Service
+	}
+	timeRange := bson.M{}
+	if !query.From.IsZero() {
+		timeRange["$gte"] = query.From
+	}
+	if !query.To.IsZero() {
+		timeRange["$lte"] = query.To
+	}
+	if len(timeRange) > 0 {
+		selection["time"] = timeRange
+	}
+	err = collection.Find(selection).Sort("-time").Limit(query.Limit).All(&records)
+	return
+}
+
+func (this MongoPersistence) exportScenarios() (scenarios []Scenario, err error) {
+	session, collection := this.getScenarioCollection()
+	defer session.Close()
+	err = collection.Find(nil).All(&scenarios)
+	return
+}
+
+func (this MongoPersistence) exportSnapshots() (snapshots []WorldSnapshot, err error) {
+	session, collection := this.getSnapshotCollection()
+	defer session.Close()
+	err = collection.Find(nil).All(&snapshots)
+	return
+}
+
+func (this MongoPersistence) exportBlueprints() (blueprints []DeviceBlueprint, err error) {
+	session, collection := this.getBlueprintCollection()
+	defer session.Close()
+	err = collection.Find(nil).All(&blueprints)
+	return
+}
+
+func (this MongoPersistence) exportCommandRecords() (records []CommandRecord, err error) {
+	session, collection := this.getCommandCollection()
+	defer session.Close()
+	err = collection.Find(nil).All(&records)
+	return
+}
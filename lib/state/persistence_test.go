@@ -0,0 +1,147 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"github.com/globalsign/mgo"
+)
+
+// persistence of tests which do not need stored documents; all changes are discarded and nothing is found
+type nopPersistence struct{}
+
+func (this nopPersistence) PersistWorld(world World) (err error) {
+	return nil
+}
+
+func (this nopPersistence) PersistStates(states []EntityStates) (err error) {
+	return nil
+}
+
+func (this nopPersistence) PersistGraph(graph Graph) (err error) {
+	return nil
+}
+
+func (this nopPersistence) PersistTemplate(templ RoutineTemplate) error {
+	return nil
+}
+
+func (this nopPersistence) LoadWorlds() (map[string]*World, error) {
+	return map[string]*World{}, nil
+}
+
+func (this nopPersistence) LoadGraphs() (map[string]*Graph, error) {
+	return map[string]*Graph{}, nil
+}
+
+func (this nopPersistence) GetTemplate(id string) (templ RoutineTemplate, err error) {
+	return templ, mgo.ErrNotFound
+}
+
+func (this nopPersistence) GetTemplates() (templ []RoutineTemplate, err error) {
+	return []RoutineTemplate{}, nil
+}
+
+func (this nopPersistence) DeleteWorld(id string) error {
+	return nil
+}
+
+func (this nopPersistence) DeleteGraph(id string) error {
+	return nil
+}
+
+func (this nopPersistence) DeleteTemplate(id string) error {
+	return nil
+}
+
+func (this nopPersistence) PersistScenario(scenario Scenario) error {
+	return nil
+}
+
+func (this nopPersistence) GetScenario(id string) (scenario Scenario, err error) {
+	return scenario, mgo.ErrNotFound
+}
+
+func (this nopPersistence) GetScenarios(owner string) (scenarios []Scenario, err error) {
+	return []Scenario{}, nil
+}
+
+func (this nopPersistence) DeleteScenario(id string) error {
+	return nil
+}
+
+func (this nopPersistence) PersistSnapshot(snapshot WorldSnapshot) error {
+	return nil
+}
+
+func (this nopPersistence) GetSnapshot(id string) (snapshot WorldSnapshot, err error) {
+	return snapshot, mgo.ErrNotFound
+}
+
+func (this nopPersistence) GetSnapshots(worldId string) (snapshots []WorldSnapshot, err error) {
+	return []WorldSnapshot{}, nil
+}
+
+func (this nopPersistence) DeleteSnapshot(id string) error {
+	return nil
+}
+
+func (this nopPersistence) DeleteWorldSnapshots(worldId string) error {
+	return nil
+}
+
+func (this nopPersistence) PersistBlueprint(blueprint DeviceBlueprint) error {
+	return nil
+}
+
+func (this nopPersistence) GetBlueprint(id string) (blueprint DeviceBlueprint, err error) {
+	return blueprint, mgo.ErrNotFound
+}
+
+func (this nopPersistence) GetBlueprints(owner string) (blueprints []DeviceBlueprint, err error) {
+	return []DeviceBlueprint{}, nil
+}
+
+func (this nopPersistence) DeleteBlueprint(id string) error {
+	return nil
+}
+
+func (this nopPersistence) PersistCommandRecord(record CommandRecord, historySize int) (err error) {
+	return nil
+}
+
+func (this nopPersistence) GetCommandRecords(query CommandHistoryQuery) (records []CommandRecord, err error) {
+	return []CommandRecord{}, nil
+}
+
+// connection log of tests; all connection changes are discarded
+type nopLogger struct{}
+
+func (this nopLogger) LogDeviceDisconnect(id string) error {
+	return nil
+}
+
+func (this nopLogger) LogDeviceConnect(id string) error {
+	return nil
+}
+
+func (this nopLogger) LogHubConnect(gateway string) error {
+	return nil
+}
+
+func (this nopLogger) LogHubDisconnect(gateway string) error {
+	return nil
+}
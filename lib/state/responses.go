@@ -0,0 +1,217 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/google/uuid"
+)
+
+// PendingResponse is a command response which is sent after the js run of the actuator service
+type PendingResponse struct {
+	Id       string     `json:"id"`
+	Device   string     `json:"device"`
+	Service  string     `json:"service"`
+	Created  time.Time  `json:"created"`
+	Due      *time.Time `json:"due,omitempty"` //time of a delayed response or of the timeout of a deferred response
+	Deferred bool       `json:"deferred"`      //deferred responses are sent by moses.device.respond(id, value)
+	timer    *time.Timer
+	device   *Device //current version of the device; its fault profile is applied when the response is sent
+	respond  func(value interface{})
+}
+
+type pendingResponseRegistry struct {
+	mux       sync.Mutex
+	responses map[string]*PendingResponse
+}
+
+// adds the response; timeout is called after delay if delay > 0
+func (this *pendingResponseRegistry) add(response *PendingResponse, delay time.Duration, timeout func()) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.responses == nil {
+		this.responses = map[string]*PendingResponse{}
+	}
+	if delay > 0 {
+		response.timer = time.AfterFunc(delay, timeout)
+	}
+	this.responses[response.Id] = response
+}
+
+// removes and returns the pending response; deviceId is ignored if empty
+func (this *pendingResponseRegistry) take(id string, deviceId string) (response *PendingResponse, ok bool) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	response, ok = this.responses[id]
+	if !ok || (deviceId != "" && response.Device != deviceId) {
+		return nil, false
+	}
+	delete(this.responses, id)
+	if response.timer != nil {
+		response.timer.Stop()
+	}
+	return response, true
+}
+
+// replaces the device of each pending response by the result of current; responses without current device are cancelled
+func (this *pendingResponseRegistry) refresh(current func(response *PendingResponse) (device *Device, ok bool)) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for id, response := range this.responses {
+		device, ok := current(response)
+		if ok {
+			response.device = device
+			continue
+		}
+		if response.timer != nil {
+			response.timer.Stop()
+		}
+		delete(this.responses, id)
+	}
+}
+
+// returns the latest due time of the responses of the service created since the given time
+// unbounded is true if one of these responses has no due time
+func (this *pendingResponseRegistry) latestDue(deviceId string, serviceId string, since time.Time) (due time.Time, pending bool, unbounded bool) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for _, response := range this.responses {
+		if response.Device != deviceId || response.Service != serviceId || response.Created.Before(since) {
+			continue
+		}
+		pending = true
+		if response.Due == nil {
+			unbounded = true
+		} else if response.Due.After(due) {
+			due = *response.Due
+		}
+	}
+	return due, pending, unbounded
+}
+
+func (this *pendingResponseRegistry) cancelAll() {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for _, response := range this.responses {
+		if response.timer != nil {
+			response.timer.Stop()
+		}
+	}
+	this.responses = nil
+}
+
+func (this *pendingResponseRegistry) list(deviceId string) (result []PendingResponse) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	result = []PendingResponse{}
+	for _, response := range this.responses {
+		if response.Device == deviceId {
+			result = append(result, *response)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Created.Before(result[j].Created)
+	})
+	return result
+}
+
+// cancels the pending responses of devices and services which no longer exist
+// and lets the remaining responses use the current version of their device
+// expects the indexes of the state repo to be rebuilt
+func (this *StateRepo) refreshPendingResponses() {
+	this.pendingResponses.refresh(func(response *PendingResponse) (*Device, bool) {
+		device, ok := this.serviceDeviceIndex[response.Service]
+		return device, ok && device.Id == response.Device
+	})
+}
+
+// sends the value with the responder of the pending response after applying the fault profile of its device
+func (this *StateRepo) sendPendingResponse(response *PendingResponse, value interface{}) {
+	for _, result := range this.applyFaults(response.device, response.Service, value) {
+		response.respond(result)
+	}
+}
+
+// sends value with the responder after delay; returns the id of the pending response
+// the responder is expected to not apply faults; they are applied when the response is sent
+func (this *StateRepo) sendLater(device *Device, serviceId string, responder func(respMsg interface{}), value interface{}, delay time.Duration) string {
+	now := time.Now()
+	due := now.Add(delay)
+	response := &PendingResponse{Id: uuid.NewString(), Device: device.Id, Service: serviceId, Created: now, Due: &due, device: device, respond: responder}
+	send := func() {
+		if pending, ok := this.pendingResponses.take(response.Id, ""); ok {
+			this.sendPendingResponse(pending, value)
+		}
+	}
+	if delay <= 0 {
+		this.pendingResponses.add(response, 0, nil)
+		go send()
+		return response.Id
+	}
+	this.pendingResponses.add(response, delay, send)
+	return response.Id
+}
+
+// registers a response which is sent by respondDeferred(); the response is dropped after timeout if timeout > 0
+// the responder is expected to not apply faults; they are applied when the response is sent
+func (this *StateRepo) deferResponse(device *Device, serviceId string, responder func(respMsg interface{}), timeout time.Duration) string {
+	now := time.Now()
+	response := &PendingResponse{Id: uuid.NewString(), Device: device.Id, Service: serviceId, Created: now, Deferred: true, device: device, respond: responder}
+	if timeout > 0 {
+		due := now.Add(timeout)
+		response.Due = &due
+	}
+	this.pendingResponses.add(response, timeout, func() {
+		if _, ok := this.pendingResponses.take(response.Id, ""); ok {
+			log.Println("WARNING: deferred response timed out", response.Device, response.Service, response.Id)
+		}
+	})
+	return response.Id
+}
+
+// sends the deferred response of the device; returns false if no such response is pending
+func (this *StateRepo) respondDeferred(deviceId string, id string, value interface{}) bool {
+	response, ok := this.pendingResponses.take(id, deviceId)
+	if !ok {
+		return false
+	}
+	this.sendPendingResponse(response, value)
+	return true
+}
+
+func (this *StateRepo) ReadPendingResponses(jwt jwt.Jwt, deviceId string) (result []PendingResponse, access bool, exists bool, err error) {
+	_, access, exists, err = this.ReadDevice(jwt, deviceId)
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	return this.pendingResponses.list(deviceId), true, true, nil
+}
+
+// cancels the pending response without sending it
+func (this *StateRepo) CancelPendingResponse(jwt jwt.Jwt, deviceId string, id string) (access bool, exists bool, err error) {
+	_, access, exists, err = this.ReadDevice(jwt, deviceId)
+	if err != nil || !access || !exists {
+		return access, exists, err
+	}
+	_, exists = this.pendingResponses.take(id, deviceId)
+	return true, exists, nil
+}
@@ -0,0 +1,222 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/config"
+)
+
+func TestSendLater(t *testing.T) {
+	repo := &StateRepo{Persistence: nopPersistence{}}
+	device := &Device{Id: "d", States: map[string]interface{}{}}
+	world := &World{Id: "w", mux: &sync.Mutex{}, Rooms: map[string]*Room{"r": {Id: "r", Devices: map[string]*Device{"d": device}}}}
+	responses := make(chan interface{}, 10)
+	api := repo.getJsCommandApi(world, world.Rooms["r"], device, Service{Id: "s"}, map[string]interface{}{}, func(respMsg interface{}) {
+		responses <- respMsg
+	})
+	err := run(`var id = moses.service.sendLater({"ok": true}, 50); moses.device.state.set("response", id);`, api, 2*time.Second, world.mux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending := repo.pendingResponses.list("d")
+	if len(pending) != 1 || pending[0].Id != device.States["response"] || pending[0].Service != "s" || pending[0].Due == nil || pending[0].Deferred {
+		t.Fatal(pending)
+	}
+	select {
+	case resp := <-responses:
+		if resp.(map[string]interface{})["ok"] != true {
+			t.Error(resp)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("missing delayed response")
+	}
+	if len(repo.pendingResponses.list("d")) != 0 {
+		t.Error(repo.pendingResponses.list("d"))
+	}
+}
+
+func TestDeferredResponse(t *testing.T) {
+	repo := &StateRepo{Persistence: nopPersistence{}}
+	device := &Device{Id: "d", States: map[string]interface{}{}}
+	world := &World{Id: "w", mux: &sync.Mutex{}, Rooms: map[string]*Room{"r": {Id: "r", Devices: map[string]*Device{"d": device}}}}
+	responses := make(chan interface{}, 10)
+	api := repo.getJsCommandApi(world, world.Rooms["r"], device, Service{Id: "s"}, map[string]interface{}{}, func(respMsg interface{}) {
+		responses <- respMsg
+	})
+	err := run(`var response = moses.service.defer(0); moses.device.state.set("response", response.id);`, api, 2*time.Second, world.mux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.pendingResponses.list("d")) != 1 || len(responses) != 0 {
+		t.Fatal(repo.pendingResponses.list("d"))
+	}
+	err = run(`moses.device.state.set("sent", moses.device.respond(moses.device.state.get("response"), 42));`, repo.getJsDeviceApi(world, world.Rooms["r"], device), 2*time.Second, world.mux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.States["sent"] != true || len(responses) != 1 {
+		t.Fatal(device.States, len(responses))
+	}
+	if len(repo.pendingResponses.list("d")) != 0 {
+		t.Error(repo.pendingResponses.list("d"))
+	}
+	if repo.respondDeferred("d", device.States["response"].(string), 42) {
+		t.Error("response sent twice")
+	}
+}
+
+func TestPendingResponsesCancelAll(t *testing.T) {
+	repo := &StateRepo{}
+	sent := make(chan bool, 10)
+	repo.sendLater(&Device{Id: "d"}, "s", func(respMsg interface{}) { sent <- true }, 1, 50*time.Millisecond)
+	repo.deferResponse(&Device{Id: "d"}, "s", func(respMsg interface{}) { sent <- true }, time.Second)
+	if len(repo.pendingResponses.list("d")) != 2 {
+		t.Fatal(repo.pendingResponses.list("d"))
+	}
+	repo.pendingResponses.cancelAll()
+	if len(repo.pendingResponses.list("d")) != 0 {
+		t.Error(repo.pendingResponses.list("d"))
+	}
+	time.Sleep(100 * time.Millisecond)
+	if len(sent) != 0 {
+		t.Error("canceled response has been sent")
+	}
+}
+
+func TestDeferredResponseTimeout(t *testing.T) {
+	repo := &StateRepo{}
+	sent := make(chan bool, 10)
+	id := repo.deferResponse(&Device{Id: "d"}, "s", func(respMsg interface{}) { sent <- true }, 20*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	if len(repo.pendingResponses.list("d")) != 0 || repo.respondDeferred("d", id, 1) || len(sent) != 0 {
+		t.Error("timed out response is still pending")
+	}
+}
+
+func getPendingResponseTestRepo() *StateRepo {
+	return &StateRepo{
+		Config:      config.Config{JsTimeout: 2 * time.Second},
+		Persistence: nopPersistence{},
+		StateLogger: nopLogger{},
+		Worlds: map[string]*World{
+			"w1": {Id: "w1", mux: &sync.Mutex{}, Rooms: map[string]*Room{"r1": {Id: "r1", Devices: map[string]*Device{
+				"d1": {Id: "d1", States: map[string]interface{}{}, Services: map[string]Service{
+					"s1": {Id: "s1", Code: `moses.service.sendLater({"ok": true}, 200);`},
+				}},
+			}}}},
+			"w2": {Id: "w2", mux: &sync.Mutex{}, Rooms: map[string]*Room{}},
+		},
+	}
+}
+
+func TestPendingResponseSurvivesUnrelatedEdit(t *testing.T) {
+	repo := getPendingResponseTestRepo()
+	repo.Start()
+	defer repo.Shutdown()
+
+	result := make(chan interface{}, 1)
+	go func() {
+		resp, err := repo.RunService("s1", nil)
+		if err != nil {
+			t.Error(err)
+		}
+		result <- resp
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if len(repo.pendingResponses.list("d1")) != 1 {
+		t.Fatal(repo.pendingResponses.list("d1"))
+	}
+	err := repo.DevUpdateWorld(WorldMsg{Id: "w2", Name: "changed", Rooms: map[string]RoomMsg{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.pendingResponses.list("d1")) != 1 {
+		t.Fatal("pending response canceled by unrelated edit")
+	}
+	select {
+	case resp := <-result:
+		if resp == nil || resp.(map[string]interface{})["ok"] != true {
+			t.Error(resp)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("missing delayed response")
+	}
+}
+
+func TestPendingResponseOfRemovedDeviceIsCanceled(t *testing.T) {
+	repo := getPendingResponseTestRepo()
+	repo.Start()
+	defer repo.Shutdown()
+
+	world := repo.Worlds["w1"]
+	device := world.Rooms["r1"].Devices["d1"]
+	responses := make(chan interface{}, 1)
+	err := run(device.Services["s1"].Code, repo.getJsCommandApi(world, world.Rooms["r1"], device, device.Services["s1"], nil, func(respMsg interface{}) {
+		responses <- respMsg
+	}), time.Second, world.mux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.DevUpdateWorld(WorldMsg{Id: "w1", Rooms: map[string]RoomMsg{"r1": {Id: "r1", Devices: map[string]DeviceMsg{}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.pendingResponses.list("d1")) != 0 {
+		t.Fatal(repo.pendingResponses.list("d1"))
+	}
+	select {
+	case resp := <-responses:
+		t.Error("unexpected response of removed device", resp)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestPendingResponseUsesCurrentDeviceFaults(t *testing.T) {
+	repo := getPendingResponseTestRepo()
+	repo.Worlds["w1"].Rooms["r1"].Devices["d1"].Services["s1"] = Service{Id: "s1", Code: `moses.service.sendLater(42, 200);`}
+	repo.Start()
+	defer repo.Shutdown()
+
+	result := make(chan interface{}, 1)
+	go func() {
+		resp, err := repo.RunService("s1", nil)
+		if err != nil {
+			t.Error(err)
+		}
+		result <- resp
+	}()
+	time.Sleep(50 * time.Millisecond)
+	stuck := 7.0
+	err := repo.DevUpdateWorld(WorldMsg{Id: "w1", Rooms: map[string]RoomMsg{"r1": {Id: "r1", Devices: map[string]DeviceMsg{
+		"d1": {Id: "d1", States: map[string]interface{}{}, Services: repo.Worlds["w1"].Rooms["r1"].Devices["d1"].Services, Faults: &FaultProfile{StuckAt: &stuck}},
+	}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case resp := <-result:
+		if resp != stuck {
+			t.Error(resp)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("missing delayed response")
+	}
+}
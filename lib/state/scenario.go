@@ -0,0 +1,411 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/globalsign/mgo"
+	"github.com/google/uuid"
+)
+
+const (
+	ScenarioActionSetState       = "set_state"
+	ScenarioActionEnableRoutine  = "enable_routine"
+	ScenarioActionDisableRoutine = "disable_routine"
+	ScenarioActionRunService     = "run_service"
+	ScenarioActionSetOnline      = "set_online"
+	ScenarioActionSetOffline     = "set_offline"
+)
+
+// Scenario is a list of timed changes applied to a world
+type Scenario struct {
+	Id    string         `json:"id" bson:"id"`
+	Owner string         `json:"-" bson:"owner"`
+	Name  string         `json:"name" bson:"name"`
+	World string         `json:"world" bson:"world"`
+	Steps []ScenarioStep `json:"steps" bson:"steps"`
+}
+
+// {offset: 300, action: "set_state", ref_type: "room", ref_id: "", key: "window", value: "open"}
+type ScenarioStep struct {
+	Offset  int64       `json:"offset" bson:"offset"`     //seconds after scenario start
+	Action  string      `json:"action" bson:"action"`     // "set_state" || "enable_routine" || "disable_routine" || "run_service" || "set_online" || "set_offline"
+	RefType string      `json:"ref_type" bson:"ref_type"` // "world" || "room" || "device"; only used by set_state
+	RefId   string      `json:"ref_id" bson:"ref_id"`     //id of the world, room, device, routine or service
+	Key     string      `json:"key" bson:"key"`           //state key; only used by set_state
+	Value   interface{} `json:"value" bson:"value"`       //state value for set_state; input for run_service
+}
+
+type CreateScenarioRequest struct {
+	Name  string         `json:"name"`
+	World string         `json:"world"`
+	Steps []ScenarioStep `json:"steps"`
+}
+
+type UpdateScenarioRequest struct {
+	Id    string         `json:"id"`
+	Name  string         `json:"name"`
+	World string         `json:"world"`
+	Steps []ScenarioStep `json:"steps"`
+}
+
+type ScenarioStatus struct {
+	Id      string            `json:"id"`
+	Running bool              `json:"running"`
+	Started time.Time         `json:"started"`
+	Stopped time.Time         `json:"stopped"`
+	Log     []ScenarioStepLog `json:"log"`
+}
+
+type ScenarioStepLog struct {
+	Step   int       `json:"step"` //index in the offset sorted step list
+	Offset int64     `json:"offset"`
+	Action string    `json:"action"`
+	RefId  string    `json:"ref_id"`
+	Time   time.Time `json:"time"`
+	Error  string    `json:"error,omitempty"`
+}
+
+type scenarioRun struct {
+	mux    sync.Mutex
+	status ScenarioStatus
+	stop   chan bool
+}
+
+func (this *scenarioRun) getStatus() (result ScenarioStatus) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	result = this.status
+	result.Log = append([]ScenarioStepLog{}, this.status.Log...)
+	return result
+}
+
+func (this *scenarioRun) log(entry ScenarioStepLog) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.status.Log = append(this.status.Log, entry)
+}
+
+// marks the run as finished; only the first call sets the stop time
+func (this *scenarioRun) finish(now time.Time) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if !this.status.Running {
+		return
+	}
+	this.status.Running = false
+	this.status.Stopped = now
+}
+
+func (this *scenarioRun) isRunning() bool {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return this.status.Running
+}
+
+func sortScenarioSteps(steps []ScenarioStep) []ScenarioStep {
+	result := append([]ScenarioStep{}, steps...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Offset < result[j].Offset
+	})
+	return result
+}
+
+func validateScenarioSteps(steps []ScenarioStep) error {
+	for _, step := range steps {
+		if step.Offset < 0 {
+			return errors.New("negative scenario step offset")
+		}
+		switch step.Action {
+		case ScenarioActionSetState:
+			if step.Key == "" {
+				return errors.New("missing state key in scenario step")
+			}
+			if step.RefType != "world" && step.RefType != "room" && step.RefType != "device" {
+				return errors.New("unknown ref type in scenario step: " + step.RefType)
+			}
+		case ScenarioActionEnableRoutine, ScenarioActionDisableRoutine, ScenarioActionRunService, ScenarioActionSetOnline, ScenarioActionSetOffline:
+			if step.RefId == "" {
+				return errors.New("missing ref id in scenario step")
+			}
+		default:
+			return errors.New("unknown scenario action: " + step.Action)
+		}
+	}
+	return nil
+}
+
+func (this *StateRepo) CreateScenario(jwt jwt.Jwt, msg CreateScenarioRequest) (result Scenario, access bool, worldExists bool, err error) {
+	_, access, worldExists, err = this.ReadWorld(jwt, msg.World)
+	if err != nil || !access || !worldExists {
+		return result, access, worldExists, err
+	}
+	err = validateScenarioSteps(msg.Steps)
+	if err != nil {
+		return result, true, true, err
+	}
+	result = Scenario{Id: uuid.NewString(), Owner: jwt.UserId, Name: msg.Name, World: msg.World, Steps: sortScenarioSteps(msg.Steps)}
+	err = this.Persistence.PersistScenario(result)
+	return result, true, true, err
+}
+
+func (this *StateRepo) ReadScenario(jwt jwt.Jwt, id string) (result Scenario, access bool, exists bool, err error) {
+	result, err = this.Persistence.GetScenario(id)
+	if err == mgo.ErrNotFound {
+		return result, false, false, nil
+	}
+	if err != nil {
+		return result, false, false, err
+	}
+	if result.Owner != jwt.UserId {
+		return Scenario{}, false, true, nil
+	}
+	return result, true, true, nil
+}
+
+func (this *StateRepo) ReadScenarios(jwt jwt.Jwt) (result []Scenario, err error) {
+	return this.Persistence.GetScenarios(jwt.UserId)
+}
+
+func (this *StateRepo) UpdateScenario(jwt jwt.Jwt, msg UpdateScenarioRequest) (result Scenario, access bool, exists bool, err error) {
+	result, access, exists, err = this.ReadScenario(jwt, msg.Id)
+	if err != nil || !access || !exists {
+		return
+	}
+	_, access, exists, err = this.ReadWorld(jwt, msg.World)
+	if err != nil || !access || !exists {
+		return
+	}
+	err = validateScenarioSteps(msg.Steps)
+	if err != nil {
+		return result, true, true, err
+	}
+	result.Name = msg.Name
+	result.World = msg.World
+	result.Steps = sortScenarioSteps(msg.Steps)
+	err = this.Persistence.PersistScenario(result)
+	return result, true, true, err
+}
+
+func (this *StateRepo) DeleteScenario(jwt jwt.Jwt, id string) (access bool, exists bool, err error) {
+	_, access, exists, err = this.ReadScenario(jwt, id)
+	if err != nil || !access || !exists {
+		return
+	}
+	this.stopScenario(id)
+	err = this.Persistence.DeleteScenario(id)
+	return true, true, err
+}
+
+func (this *StateRepo) StartScenario(jwt jwt.Jwt, id string) (status ScenarioStatus, access bool, exists bool, err error) {
+	scenario, access, exists, err := this.ReadScenario(jwt, id)
+	if err != nil || !access || !exists {
+		return
+	}
+	_, access, exists, err = this.ReadWorld(jwt, scenario.World)
+	if err != nil {
+		return status, access, exists, err
+	}
+	if !access || !exists {
+		return status, true, true, errors.New("unknown world of scenario: " + scenario.World)
+	}
+	this.scenarioMux.Lock()
+	defer this.scenarioMux.Unlock()
+	if this.scenarioRuns == nil {
+		this.scenarioRuns = map[string]*scenarioRun{}
+	}
+	if run, ok := this.scenarioRuns[id]; ok && run.isRunning() {
+		return run.getStatus(), true, true, errors.New("scenario is already running")
+	}
+	run := &scenarioRun{
+		status: ScenarioStatus{Id: id, Running: true, Started: this.now(), Log: []ScenarioStepLog{}},
+		stop:   make(chan bool, 1),
+	}
+	this.scenarioRuns[id] = run
+	go this.runScenario(scenario, run)
+	return run.getStatus(), true, true, nil
+}
+
+func (this *StateRepo) StopScenario(jwt jwt.Jwt, id string) (status ScenarioStatus, access bool, exists bool, err error) {
+	_, access, exists, err = this.ReadScenario(jwt, id)
+	if err != nil || !access || !exists {
+		return
+	}
+	return this.stopScenario(id), true, true, nil
+}
+
+func (this *StateRepo) ReadScenarioStatus(jwt jwt.Jwt, id string) (status ScenarioStatus, access bool, exists bool, err error) {
+	_, access, exists, err = this.ReadScenario(jwt, id)
+	if err != nil || !access || !exists {
+		return
+	}
+	this.scenarioMux.Lock()
+	defer this.scenarioMux.Unlock()
+	run, ok := this.scenarioRuns[id]
+	if !ok {
+		return ScenarioStatus{Id: id, Log: []ScenarioStepLog{}}, true, true, nil
+	}
+	return run.getStatus(), true, true, nil
+}
+
+func (this *StateRepo) stopScenario(id string) (status ScenarioStatus) {
+	this.scenarioMux.Lock()
+	defer this.scenarioMux.Unlock()
+	run, ok := this.scenarioRuns[id]
+	if !ok {
+		return ScenarioStatus{Id: id, Log: []ScenarioStepLog{}}
+	}
+	run.finish(this.now())
+	select {
+	case run.stop <- true:
+	default:
+	}
+	return run.getStatus()
+}
+
+func (this *StateRepo) stopAllScenarios() {
+	this.scenarioMux.Lock()
+	ids := []string{}
+	for id := range this.scenarioRuns {
+		ids = append(ids, id)
+	}
+	this.scenarioMux.Unlock()
+	for _, id := range ids {
+		this.stopScenario(id)
+	}
+}
+
+func (this *StateRepo) runScenario(scenario Scenario, run *scenarioRun) {
+	defer func() {
+		run.finish(this.now())
+	}()
+	token := jwt.Jwt{UserId: scenario.Owner}
+	start := run.getStatus().Started
+	for index, step := range scenario.Steps {
+		timer := time.NewTimer(start.Add(time.Duration(step.Offset) * time.Second).Sub(this.now()))
+		select {
+		case <-run.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if !run.isRunning() {
+			return
+		}
+		entry := ScenarioStepLog{Step: index, Offset: step.Offset, Action: step.Action, RefId: step.RefId, Time: this.now()}
+		err := this.executeScenarioStep(token, scenario.World, step)
+		if err != nil {
+			log.Println("WARNING: unable to execute scenario step", scenario.Id, index, err)
+			entry.Error = err.Error()
+		}
+		run.log(entry)
+	}
+}
+
+func (this *StateRepo) executeScenarioStep(token jwt.Jwt, worldId string, step ScenarioStep) (err error) {
+	switch step.Action {
+	case ScenarioActionSetState:
+		return this.setEntityState(token, worldId, step.RefType, step.RefId, step.Key, step.Value)
+	case ScenarioActionEnableRoutine, ScenarioActionDisableRoutine:
+		routine, access, exists, err := this.ReadChangeRoutine(token, step.RefId)
+		if err != nil {
+			return err
+		}
+		if !access || !exists {
+			return errors.New("unknown routine id")
+		}
+		_, _, _, err = this.UpdateChangeRoutine(token, UpdateChangeRoutineRequest{
+			Id:       routine.Id,
+			Interval: routine.Interval,
+			Code:     routine.Code,
+			Template: routine.Template,
+			Disabled: step.Action == ScenarioActionDisableRoutine,
+		})
+		return err
+	case ScenarioActionRunService:
+		service, access, exists, err := this.ReadService(token, step.RefId)
+		if err != nil {
+			return err
+		}
+		if !access || !exists || service.World != worldId {
+			return errors.New("unknown service id")
+		}
+		_, err = this.RunService(step.RefId, step.Value)
+		return err
+	case ScenarioActionSetOnline, ScenarioActionSetOffline:
+		device, access, exists, err := this.ReadDevice(token, step.RefId)
+		if err != nil {
+			return err
+		}
+		if !access || !exists || device.World != worldId {
+			return errors.New("unknown device id")
+		}
+		_, _, _, err = this.SetDeviceOnline(token, DeviceConnectionRequest{Device: step.RefId, Online: step.Action == ScenarioActionSetOnline})
+		return err
+	default:
+		return errors.New("unknown scenario action: " + step.Action)
+	}
+}
+
+// sets a single state of a world, room or device without restarting change routines
+func (this *StateRepo) setEntityState(token jwt.Jwt, worldId string, refType string, refId string, key string, value interface{}) error {
+	this.mux.RLock()
+	defer this.mux.RUnlock()
+	world, ok := this.Worlds[worldId]
+	if !ok {
+		return errors.New("unknown world id")
+	}
+	world.mux.Lock()
+	defer world.mux.Unlock()
+	if world.Owner != token.UserId {
+		return errors.New("access denied")
+	}
+	var states *map[string]interface{}
+	switch refType {
+	case "world":
+		if refId != "" && refId != world.Id {
+			return errors.New("state ref does not belong to world")
+		}
+		refId = world.Id
+		states = &world.States
+	case "room":
+		room, ok := world.Rooms[refId]
+		if !ok {
+			return errors.New("unknown room id")
+		}
+		states = &room.States
+	case "device":
+		room, ok := this.deviceRoomIndex[refId]
+		if !ok || this.deviceWorldIndex[refId] != world {
+			return errors.New("unknown device id")
+		}
+		states = &room.Devices[refId].States
+	default:
+		return errors.New("unknown ref type")
+	}
+	if *states == nil {
+		*states = map[string]interface{}{}
+	}
+	(*states)[key] = value
+	return this.persistStates(world, refType, refId, *states)
+}
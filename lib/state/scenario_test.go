@@ -0,0 +1,220 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/globalsign/mgo"
+)
+
+type scenarioTestPersistence struct {
+	nopPersistence
+	mux       sync.Mutex
+	scenarios map[string]Scenario
+}
+
+func (this *scenarioTestPersistence) PersistScenario(scenario Scenario) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.scenarios[scenario.Id] = scenario
+	return nil
+}
+
+func (this *scenarioTestPersistence) GetScenario(id string) (Scenario, error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	scenario, ok := this.scenarios[id]
+	if !ok {
+		return scenario, mgo.ErrNotFound
+	}
+	return scenario, nil
+}
+
+func (this *scenarioTestPersistence) GetTemplate(id string) (RoutineTemplate, error) {
+	if id != "tmpl" {
+		return RoutineTemplate{}, mgo.ErrNotFound
+	}
+	return RoutineTemplate{Id: "tmpl", Template: `moses.world.state.set("{{key}}", 1);`}, nil
+}
+
+func getScenarioTestRepo() *StateRepo {
+	return &StateRepo{
+		Persistence: &scenarioTestPersistence{scenarios: map[string]Scenario{}},
+		StateLogger: nopLogger{},
+		Worlds: map[string]*World{"w": {Id: "w", Owner: "user", mux: &sync.Mutex{}, States: map[string]interface{}{},
+			ChangeRoutines: map[string]ChangeRoutine{
+				"cr": {Id: "cr", Interval: 3600, Code: `moses.world.state.set("key", 1);`, Template: "tmpl"},
+			},
+			Rooms: map[string]*Room{},
+		}},
+	}
+}
+
+func waitForScenario(t *testing.T, repo *StateRepo, id string) ScenarioStatus {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		status, _, _, err := repo.ReadScenarioStatus(jwt.Jwt{UserId: "user"}, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !status.Running {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("scenario did not finish")
+	return ScenarioStatus{}
+}
+
+func TestScenarioStepOrder(t *testing.T) {
+	repo := getScenarioTestRepo()
+	//every read of the clock advances an hour, so all steps are due immediately
+	ticks := atomic.Int64{}
+	start := time.Now()
+	repo.clock = func() time.Time {
+		return start.Add(time.Duration(ticks.Add(1)) * time.Hour)
+	}
+	repo.Start()
+	defer repo.Stop()
+	user := jwt.Jwt{UserId: "user"}
+
+	scenario, _, _, err := repo.CreateScenario(user, CreateScenarioRequest{Name: "order", World: "w", Steps: []ScenarioStep{
+		{Offset: 20, Action: ScenarioActionSetState, RefType: "world", Key: "x", Value: "second"},
+		{Offset: 10, Action: ScenarioActionSetState, RefType: "world", Key: "x", Value: "first"},
+		{Offset: 20, Action: ScenarioActionSetState, RefType: "world", Key: "y", Value: "third"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scenario.Steps[0].Value != "first" || scenario.Steps[1].Value != "second" || scenario.Steps[2].Value != "third" {
+		t.Fatal(scenario.Steps)
+	}
+	_, _, _, err = repo.StartScenario(user, scenario.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := waitForScenario(t, repo, scenario.Id)
+	if len(status.Log) != 3 {
+		t.Fatal(status.Log)
+	}
+	for i, entry := range status.Log {
+		if entry.Step != i || entry.Error != "" {
+			t.Error(entry)
+		}
+		if !entry.Time.After(status.Started) {
+			t.Error("expected step time of the repo clock", entry.Time, status.Started)
+		}
+	}
+	world, _, _, err := repo.ReadWorld(user, "w")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if world.States["x"] != "second" || world.States["y"] != "third" {
+		t.Error(world.States)
+	}
+}
+
+func TestStopScenario(t *testing.T) {
+	repo := getScenarioTestRepo()
+	repo.Start()
+	defer repo.Stop()
+	user := jwt.Jwt{UserId: "user"}
+
+	scenario, _, _, err := repo.CreateScenario(user, CreateScenarioRequest{Name: "stop", World: "w", Steps: []ScenarioStep{
+		{Offset: 3600, Action: ScenarioActionSetState, RefType: "world", Key: "x", Value: 1},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, _, err = repo.StartScenario(user, scenario.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, _, err = repo.StartScenario(user, scenario.Id)
+	if err == nil {
+		t.Error("expected error for running scenario")
+	}
+	status, _, _, err := repo.StopScenario(user, scenario.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Running || status.Stopped.IsZero() || len(status.Log) != 0 {
+		t.Fatal(status)
+	}
+	time.Sleep(50 * time.Millisecond)
+	after, _, _, err := repo.ReadScenarioStatus(user, scenario.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !after.Stopped.Equal(status.Stopped) {
+		t.Error("stop time should be set once", after.Stopped, status.Stopped)
+	}
+	_, _, _, err = repo.StartScenario(user, scenario.Id)
+	if err != nil {
+		t.Error("stopped scenario should be restartable", err)
+	}
+	repo.stopAllScenarios()
+}
+
+func TestScenarioRoutineSteps(t *testing.T) {
+	repo := getScenarioTestRepo()
+	repo.Start()
+	defer repo.Stop()
+	user := jwt.Jwt{UserId: "user"}
+
+	err := repo.executeScenarioStep(user, "w", ScenarioStep{Action: ScenarioActionDisableRoutine, RefId: "cr"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	routine, _, _, err := repo.ReadChangeRoutine(user, "cr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !routine.Disabled || routine.Template != "tmpl" || routine.Interval != 3600 {
+		t.Fatal("disabled routine should keep its template", routine)
+	}
+
+	routine, _, _, err = repo.UpdateChangeRoutineByTemplate(user, UpdateChangeRoutineByTemplateRequest{RoutineId: "cr", TemplId: "tmpl", Interval: 60, Parameter: map[string]string{"key": "other"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !routine.Disabled || routine.Interval != 60 || routine.Code != `moses.world.state.set("other", 1);` {
+		t.Fatal("template update should keep the routine disabled", routine)
+	}
+
+	err = repo.executeScenarioStep(user, "w", ScenarioStep{Action: ScenarioActionEnableRoutine, RefId: "cr"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	routine, _, _, err = repo.ReadChangeRoutine(user, "cr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if routine.Disabled || routine.Template != "tmpl" || routine.Interval != 60 {
+		t.Error(routine)
+	}
+
+	err = repo.executeScenarioStep(user, "w", ScenarioStep{Action: ScenarioActionEnableRoutine, RefId: "unknown"})
+	if err == nil {
+		t.Error("expected error for unknown routine")
+	}
+}
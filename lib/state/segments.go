@@ -0,0 +1,137 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/SENERGY-Platform/moses/lib/config"
+	platform_connector_lib "github.com/SENERGY-Platform/platform-connector-lib"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+)
+
+// SegmentedValue is a message with one value per protocol segment
+// other values are sent in the segment config.ProtocolSegmentName
+type SegmentedValue map[string]interface{}
+
+// returns config.ProtocolSegments; config.ProtocolSegmentName is added as first segment if missing
+func GetProtocolSegmentNames(config config.Config) (result []string) {
+	result = []string{}
+	found := false
+	for _, name := range config.ProtocolSegments {
+		if name == config.ProtocolSegmentName {
+			found = true
+		}
+		if name != "" {
+			result = append(result, name)
+		}
+	}
+	if !found {
+		result = append([]string{config.ProtocolSegmentName}, result...)
+	}
+	return result
+}
+
+func getProtocolSegments(config config.Config) (result []model.ProtocolSegment) {
+	for _, name := range GetProtocolSegmentNames(config) {
+		result = append(result, model.ProtocolSegment{Name: name})
+	}
+	return result
+}
+
+// returns the segment name of a protocol segment id
+type segmentLookup func(segmentId string) string
+
+// returns the segment names used by generated service code; nil if a single segment is configured
+// unknown segment ids are mapped to config.ProtocolSegmentName
+func (this *StateRepo) getSegmentLookup() segmentLookup {
+	if len(GetProtocolSegmentNames(this.Config)) < 2 {
+		return nil
+	}
+	return func(segmentId string) string {
+		if name, ok := this.mosesProtocolSegments[segmentId]; ok {
+			return name
+		}
+		return this.Config.ProtocolSegmentName
+	}
+}
+
+// returns the message of a js sendSegments call; values of unknown segments are dropped because the platform ignores them
+// if a single segment is configured, the value of config.ProtocolSegmentName is returned
+func (this *StateRepo) toSegmentedValue(value map[string]interface{}) interface{} {
+	segments := GetProtocolSegmentNames(this.Config)
+	if len(segments) < 2 {
+		return value[this.Config.ProtocolSegmentName]
+	}
+	known := map[string]bool{}
+	for _, name := range segments {
+		known[name] = true
+	}
+	result := SegmentedValue{}
+	for key, segmentValue := range value {
+		if !known[key] {
+			log.Println("WARNING: ignore value of unknown protocol segment", key)
+			continue
+		}
+		result[key] = segmentValue
+	}
+	return result
+}
+
+// returns the value of the default segment and the values of all segments
+func splitSegments(value interface{}, defaultSegment string) (input interface{}, segments map[string]interface{}) {
+	if segmented, ok := value.(SegmentedValue); ok {
+		return segmented[defaultSegment], segmented
+	}
+	return value, map[string]interface{}{defaultSegment: value}
+}
+
+// encodes every segment value as json
+func encodeSegments(value interface{}, defaultSegment string) (msg map[string]string, err error) {
+	msg = map[string]string{}
+	segments, ok := value.(SegmentedValue)
+	if !ok {
+		segments = SegmentedValue{defaultSegment: value}
+	}
+	for name, segmentValue := range segments {
+		b, err := json.Marshal(segmentValue)
+		if err != nil {
+			return msg, err
+		}
+		msg[name] = string(b)
+	}
+	return msg, nil
+}
+
+// decodes the json segments of the request; segments which are no valid json are kept as string
+// returns a SegmentedValue if more than one segment is configured, else the value of the default segment
+func decodeSegments(requestMsg platform_connector_lib.CommandRequestMsg, config config.Config) interface{} {
+	msg := SegmentedValue{}
+	for key, value := range requestMsg {
+		var msgPart interface{}
+		err := json.Unmarshal([]byte(value), &msgPart)
+		if err != nil {
+			msgPart = value
+		}
+		msg[key] = msgPart
+	}
+	if len(GetProtocolSegmentNames(config)) < 2 {
+		return msg[config.ProtocolSegmentName]
+	}
+	return msg
+}
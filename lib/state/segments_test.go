@@ -0,0 +1,114 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/config"
+	platform_connector_lib "github.com/SENERGY-Platform/platform-connector-lib"
+)
+
+func TestGetProtocolSegmentNames(t *testing.T) {
+	names := GetProtocolSegmentNames(config.Config{ProtocolSegmentName: "payload"})
+	if !reflect.DeepEqual(names, []string{"payload"}) {
+		t.Error(names)
+	}
+	names = GetProtocolSegmentNames(config.Config{ProtocolSegmentName: "payload", ProtocolSegments: []string{"metadata"}})
+	if !reflect.DeepEqual(names, []string{"payload", "metadata"}) {
+		t.Error(names)
+	}
+	names = GetProtocolSegmentNames(config.Config{ProtocolSegmentName: "payload", ProtocolSegments: []string{"metadata", "payload"}})
+	if !reflect.DeepEqual(names, []string{"metadata", "payload"}) {
+		t.Error(names)
+	}
+}
+
+func TestSegmentEncoding(t *testing.T) {
+	conf := config.Config{ProtocolSegmentName: "payload", ProtocolSegments: []string{"payload", "metadata"}}
+	msg, err := encodeSegments(SegmentedValue{"payload": 1, "metadata": map[string]interface{}{"unit": "°C"}}, conf.ProtocolSegmentName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(msg, map[string]string{"payload": "1", "metadata": `{"unit":"°C"}`}) {
+		t.Error(msg)
+	}
+	msg, err = encodeSegments(map[string]interface{}{"value": 1}, conf.ProtocolSegmentName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(msg, map[string]string{"payload": `{"value":1}`}) {
+		t.Error(msg)
+	}
+
+	request := platform_connector_lib.CommandRequestMsg{"payload": `{"on":true}`, "metadata": "raw"}
+	decoded := decodeSegments(request, conf)
+	if !reflect.DeepEqual(decoded, SegmentedValue{"payload": map[string]interface{}{"on": true}, "metadata": "raw"}) {
+		t.Error(decoded)
+	}
+	decoded = decodeSegments(request, config.Config{ProtocolSegmentName: "payload"})
+	if !reflect.DeepEqual(decoded, map[string]interface{}{"on": true}) {
+		t.Error(decoded)
+	}
+}
+
+func TestToSegmentedValue(t *testing.T) {
+	repo := &StateRepo{Config: config.Config{ProtocolSegmentName: "payload"}}
+	if value := repo.toSegmentedValue(map[string]interface{}{"payload": 1, "metadata": 2}); value != 1 {
+		t.Error("single segment protocols should send the value of the default segment", value)
+	}
+	repo.Config.ProtocolSegments = []string{"metadata"}
+	value := repo.toSegmentedValue(map[string]interface{}{"payload": 1, "metadata": 2, "value": 3})
+	if !reflect.DeepEqual(value, SegmentedValue{"payload": 1, "metadata": 2}) {
+		t.Error("unknown segments should be dropped", value)
+	}
+}
+
+func TestJsCommandSegments(t *testing.T) {
+	repo := &StateRepo{Persistence: nopPersistence{}, Config: config.Config{ProtocolSegmentName: "payload", ProtocolSegments: []string{"payload", "metadata"}}}
+	device := &Device{Id: "d", States: map[string]interface{}{}}
+	world := &World{Id: "w", mux: &sync.Mutex{}, Rooms: map[string]*Room{"r": {Id: "r", Devices: map[string]*Device{"d": device}}}}
+	responses := make(chan interface{}, 10)
+	cmdMsg := SegmentedValue{"payload": map[string]interface{}{"on": true}, "metadata": map[string]interface{}{"source": "test"}}
+	api := repo.getJsCommandApi(world, world.Rooms["r"], device, Service{Id: "s"}, cmdMsg, func(respMsg interface{}) {
+		responses <- respMsg
+	})
+	err := run(`
+		moses.device.state.set("on", moses.service.input.on);
+		moses.device.state.set("source", moses.service.segments.metadata.source);
+		moses.service.send({"payload": {"ok": true}, "metadata": {"source": "moses"}});
+		moses.service.sendSegments({"payload": {"ok": true}, "metadata": {"source": "moses"}});
+	`, api, 2*time.Second, world.mux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.States["on"] != true || device.States["source"] != "test" {
+		t.Error(device.States)
+	}
+	resp := <-responses
+	if _, ok := resp.(SegmentedValue); ok {
+		t.Error("send should use the default segment", resp)
+	}
+	resp = <-responses
+	segmented, ok := resp.(SegmentedValue)
+	if !ok || segmented["metadata"].(map[string]interface{})["source"] != "moses" || segmented["payload"].(map[string]interface{})["ok"] != true {
+		t.Error(resp)
+	}
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"sync"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+)
+
+const defaultSensorEventHistorySize = 100
+const sensorEventSubscriptionBuffer = 100
+
+// SensorEvent describes sensor data sent to the platform connector
+type SensorEvent struct {
+	Time    time.Time   `json:"time"`
+	Device  string      `json:"device"`
+	Service string      `json:"service"`
+	Value   interface{} `json:"value"`
+	Error   string      `json:"error,omitempty"` //error of the connector; the event has not been sent if set
+}
+
+// bounded in memory history of sensor events per service id
+type sensorEventRegistry struct {
+	mux             sync.Mutex
+	events          map[string][]SensorEvent
+	subscriptions   map[string]map[int]chan SensorEvent
+	subscriptionIds int
+}
+
+func (this *StateRepo) getSensorEventHistorySize() int {
+	if this.Config.SensorEventHistorySize > 0 {
+		return int(this.Config.SensorEventHistorySize)
+	}
+	return defaultSensorEventHistorySize
+}
+
+// adds the event and passes it to all subscriptions of the service; events are dropped for subscriptions which are not read fast enough
+func (this *sensorEventRegistry) add(event SensorEvent, historySize int) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.events == nil {
+		this.events = map[string][]SensorEvent{}
+	}
+	events := append(this.events[event.Service], event)
+	if len(events) > historySize {
+		events = append([]SensorEvent{}, events[len(events)-historySize:]...)
+	}
+	this.events[event.Service] = events
+	for _, subscription := range this.subscriptions[event.Service] {
+		select {
+		case subscription <- event:
+		default:
+		}
+	}
+}
+
+// newest first
+func (this *sensorEventRegistry) list(serviceId string) (result []SensorEvent) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	events := this.events[serviceId]
+	result = make([]SensorEvent, 0, len(events))
+	for i := len(events) - 1; i >= 0; i-- {
+		result = append(result, events[i])
+	}
+	return result
+}
+
+func (this *sensorEventRegistry) subscribe(serviceId string) (events <-chan SensorEvent, unsubscribe func()) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.subscriptions == nil {
+		this.subscriptions = map[string]map[int]chan SensorEvent{}
+	}
+	if this.subscriptions[serviceId] == nil {
+		this.subscriptions[serviceId] = map[int]chan SensorEvent{}
+	}
+	this.subscriptionIds++
+	id := this.subscriptionIds
+	subscription := make(chan SensorEvent, sensorEventSubscriptionBuffer)
+	this.subscriptions[serviceId][id] = subscription
+	return subscription, func() {
+		this.mux.Lock()
+		defer this.mux.Unlock()
+		delete(this.subscriptions[serviceId], id)
+		if len(this.subscriptions[serviceId]) == 0 {
+			delete(this.subscriptions, serviceId)
+		}
+	}
+}
+
+func (this *StateRepo) recordSensorEvent(device *Device, service Service, value interface{}, err error) {
+	event := SensorEvent{Time: this.now(), Device: device.Id, Service: service.Id, Value: value}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	this.sensorEvents.add(event, this.getSensorEventHistorySize())
+}
+
+func (this *StateRepo) ReadSensorEvents(jwt jwt.Jwt, serviceId string) (result []SensorEvent, access bool, exists bool, err error) {
+	_, access, exists, err = this.ReadService(jwt, serviceId)
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	return this.sensorEvents.list(serviceId), true, true, nil
+}
+
+// returns a channel receiving all following sensor events of the service; unsubscribe has to be called if the events are no longer needed
+func (this *StateRepo) SubscribeSensorEvents(jwt jwt.Jwt, serviceId string) (events <-chan SensorEvent, unsubscribe func(), access bool, exists bool, err error) {
+	_, access, exists, err = this.ReadService(jwt, serviceId)
+	if err != nil || !access || !exists {
+		return events, unsubscribe, access, exists, err
+	}
+	events, unsubscribe = this.sensorEvents.subscribe(serviceId)
+	return events, unsubscribe, true, true, nil
+}
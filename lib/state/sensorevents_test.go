@@ -0,0 +1,65 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/config"
+)
+
+func TestSensorEventHistory(t *testing.T) {
+	repo := &StateRepo{Config: config.Config{SensorEventHistorySize: 3}}
+	device := &Device{Id: "d"}
+	for i := 1; i <= 5; i++ {
+		repo.recordSensorEvent(device, Service{Id: "s"}, i, nil)
+	}
+	repo.recordSensorEvent(device, Service{Id: "other"}, 42, errors.New("kafka unavailable"))
+	events := repo.sensorEvents.list("s")
+	if len(events) != 3 || events[0].Value != 5 || events[2].Value != 3 || events[0].Device != "d" {
+		t.Fatal(events)
+	}
+	events = repo.sensorEvents.list("other")
+	if len(events) != 1 || events[0].Error != "kafka unavailable" {
+		t.Fatal(events)
+	}
+	if events := repo.sensorEvents.list("unknown"); events == nil || len(events) != 0 {
+		t.Fatal(events)
+	}
+}
+
+func TestSensorEventSubscription(t *testing.T) {
+	repo := &StateRepo{}
+	events, unsubscribe := repo.sensorEvents.subscribe("s")
+	repo.recordSensorEvent(&Device{Id: "d"}, Service{Id: "other"}, 1, nil)
+	repo.recordSensorEvent(&Device{Id: "d"}, Service{Id: "s"}, 2, nil)
+	select {
+	case event := <-events:
+		if event.Service != "s" || event.Value != 2 {
+			t.Error(event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("missing event")
+	}
+	unsubscribe()
+	repo.recordSensorEvent(&Device{Id: "d"}, Service{Id: "s"}, 3, nil)
+	if len(events) != 0 || len(repo.sensorEvents.subscriptions) != 0 {
+		t.Error("event received after unsubscribe")
+	}
+}
@@ -0,0 +1,357 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+)
+
+const (
+	valueGeneratorBool   = "bool"   //reads or writes a bool state
+	valueGeneratorNumber = "number" //reads or writes a number state; sensors change the state by a random walk within min and max
+	valueGeneratorString = "string" //reads or writes a string state
+	valueGeneratorTime   = "time"   //current time as ISO 8601 string; no state
+	valueGeneratorFixed  = "fixed"  //fixed value of the content variable; no state
+)
+
+// value generator of a leaf content variable
+type valueGenerator struct {
+	Kind    string
+	Key     string //device state
+	Integer bool
+	Min     float64
+	Max     float64
+	Initial interface{} //initial value of the device state
+	Fixed   interface{}
+}
+
+// returns the characteristic with the given id or false if it is unknown
+type characteristicLookup func(id string) (model.Characteristic, bool)
+
+var jsIdentifierInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+var jsReservedIdentifiers = map[string]bool{
+	"moses": true, "input": true, "output": true, "segments": true, "var": true, "function": true, "return": true, "if": true, "else": true,
+	"for": true, "while": true, "do": true, "new": true, "delete": true, "this": true, "null": true, "true": true, "false": true,
+	"typeof": true, "in": true, "instanceof": true, "switch": true, "case": true, "default": true, "break": true, "continue": true,
+	"Math": true, "Date": true, "undefined": true,
+}
+
+func getValueGenerator(variable model.ContentVariable, characteristic model.Characteristic, known bool) (result valueGenerator) {
+	result.Key = variable.Name
+	if result.Key == "" && known {
+		result.Key = characteristic.Name
+	}
+	if result.Key == "" {
+		result.Key = "value"
+	}
+	if variable.Value != nil {
+		result.Kind = valueGeneratorFixed
+		result.Fixed = variable.Value
+		return result
+	}
+	hint := strings.ToLower(variable.Name)
+	if known {
+		hint = strings.ToLower(characteristic.Name + " " + characteristic.DisplayUnit + " " + variable.Name)
+	}
+	switch variable.Type {
+	case model.Boolean:
+		result.Kind = valueGeneratorBool
+		result.Initial = false
+		if value, ok := characteristic.Value.(bool); ok {
+			result.Initial = value
+		}
+	case model.Integer, model.Float:
+		result.Kind = valueGeneratorNumber
+		result.Integer = variable.Type == model.Integer
+		result.Min, result.Max, result.Initial = getNumberRange(characteristic, known, hint)
+		if result.Integer {
+			result.Initial = float64(int64(result.Initial.(float64) + 0.5))
+		}
+	default:
+		result.Kind = valueGeneratorString
+		result.Initial = ""
+		switch {
+		case known && len(characteristic.AllowedValues) > 0:
+			result.Initial = characteristic.AllowedValues[0]
+		case known && characteristic.Value != nil:
+			result.Initial = characteristic.Value
+		case strings.Contains(hint, "hex") || strings.Contains(hint, "color") || strings.Contains(hint, "colour"):
+			result.Initial = "#ffffff"
+		case strings.Contains(hint, "time") || strings.Contains(hint, "date"):
+			result.Kind = valueGeneratorTime
+			result.Initial = nil
+		}
+	}
+	return result
+}
+
+// returns the range and initial value of a number; the range of the characteristic is used if known, otherwise it is guessed from the unit or name
+func getNumberRange(characteristic model.Characteristic, known bool, hint string) (min float64, max float64, initial float64) {
+	if known {
+		characteristicMin, minOk := toFloat(characteristic.MinValue)
+		characteristicMax, maxOk := toFloat(characteristic.MaxValue)
+		if minOk && maxOk && characteristicMin < characteristicMax {
+			initial = characteristicMin + (characteristicMax-characteristicMin)/2
+			if value, ok := toFloat(characteristic.Value); ok && value >= characteristicMin && value <= characteristicMax {
+				initial = value
+			}
+			return characteristicMin, characteristicMax, initial
+		}
+	}
+	hintWords := strings.FieldsFunc(hint, func(r rune) bool {
+		return r == ' ' || r == '_' || r == '-'
+	})
+	hasWord := func(words ...string) bool {
+		for _, hintWord := range hintWords {
+			for _, word := range words {
+				if hintWord == word {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	switch {
+	case strings.Contains(hint, "°c") || strings.Contains(hint, "celsius") || strings.Contains(hint, "temperature"):
+		return 15, 25, 21
+	case strings.Contains(hint, "°f") || strings.Contains(hint, "fahrenheit"):
+		return 59, 77, 70
+	case strings.Contains(hint, "%") || strings.Contains(hint, "percent") || strings.Contains(hint, "humidity") || strings.Contains(hint, "brightness"):
+		return 0, 100, 50
+	case hasWord("r", "g", "b", "red", "green", "blue"):
+		return 0, 255, 255
+	case strings.Contains(hint, "lux") || hasWord("lx"):
+		return 0, 1000, 300
+	case strings.Contains(hint, "ppm"):
+		return 400, 1200, 600
+	default:
+		return 0, 100, 0
+	}
+}
+
+type serviceCodeBuilder struct {
+	characteristics characteristicLookup
+	sensor          bool //values of sensors change on each call
+	lines           []string
+	variables       map[string]string //device state -> js variable
+	usedVariables   map[string]bool
+	States          map[string]interface{}
+}
+
+func newServiceCodeBuilder(characteristics characteristicLookup, sensor bool) *serviceCodeBuilder {
+	return &serviceCodeBuilder{
+		characteristics: characteristics,
+		sensor:          sensor,
+		variables:       map[string]string{},
+		usedVariables:   map[string]bool{},
+		States:          map[string]interface{}{},
+	}
+}
+
+func (this *serviceCodeBuilder) getGenerator(variable model.ContentVariable) valueGenerator {
+	characteristic, known := model.Characteristic{}, false
+	if variable.CharacteristicId != "" && this.characteristics != nil {
+		characteristic, known = this.characteristics(variable.CharacteristicId)
+	}
+	return getValueGenerator(variable, characteristic, known)
+}
+
+func (this *serviceCodeBuilder) getJsVariable(key string) string {
+	name := jsIdentifierInvalidChars.ReplaceAllString(key, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') || jsReservedIdentifiers[name] {
+		name = "value_" + name
+	}
+	result := name
+	for i := 2; this.usedVariables[result]; i++ {
+		result = name + strconv.Itoa(i)
+	}
+	this.usedVariables[result] = true
+	return result
+}
+
+// adds statements writing all leaf values of the input to device states
+// accessor is the js expression of the variable, guards are js expressions which have to be != null
+func (this *serviceCodeBuilder) addInput(variable model.ContentVariable, accessor string, guards []string) {
+	if variable.IsVoid {
+		return
+	}
+	guards = append(guards, accessor)
+	switch variable.Type {
+	case model.Structure:
+		for _, sub := range variable.SubContentVariables {
+			this.addInput(sub, accessor+"["+jsString(sub.Name)+"]", guards)
+		}
+	case model.List:
+		return
+	default:
+		generator := this.getGenerator(variable)
+		if generator.Kind == valueGeneratorFixed || generator.Kind == valueGeneratorTime {
+			return
+		}
+		if _, ok := this.States[generator.Key]; !ok {
+			this.States[generator.Key] = generator.Initial
+		}
+		this.lines = append(this.lines,
+			"if ("+strings.Join(guards, " != null && ")+" != null) {",
+			"    moses.device.state.set("+jsString(generator.Key)+", "+accessor+");",
+			"}")
+	}
+}
+
+// returns a js expression of the output; statements computing leaf values are added to the code
+func (this *serviceCodeBuilder) addOutput(variable model.ContentVariable) (expression string, err error) {
+	switch variable.Type {
+	case model.Structure:
+		fields := []string{}
+		for _, sub := range variable.SubContentVariables {
+			if sub.IsVoid {
+				continue
+			}
+			subExpression, err := this.addOutput(sub)
+			if err != nil {
+				return expression, err
+			}
+			fields = append(fields, jsString(sub.Name)+": "+subExpression)
+		}
+		return "{" + strings.Join(fields, ", ") + "}", nil
+	case model.List:
+		return inputOutputSkeletonString(variable)
+	default:
+		generator := this.getGenerator(variable)
+		switch generator.Kind {
+		case valueGeneratorFixed:
+			b, err := json.Marshal(generator.Fixed)
+			return string(b), err
+		case valueGeneratorTime:
+			return "new Date().toISOString()", nil
+		}
+		if jsVariable, ok := this.variables[generator.Key]; ok {
+			return jsVariable, nil
+		}
+		if _, ok := this.States[generator.Key]; !ok {
+			this.States[generator.Key] = generator.Initial
+		}
+		jsVariable := this.getJsVariable(generator.Key)
+		this.variables[generator.Key] = jsVariable
+		this.lines = append(this.lines, "var "+jsVariable+" = moses.device.state.get("+jsString(generator.Key)+");")
+		if generator.Kind == valueGeneratorNumber && this.sensor && generator.Max > generator.Min {
+			step := strconv.FormatFloat((generator.Max-generator.Min)/50, 'f', -1, 64)
+			walk := "Math.min(" + formatJsNumber(generator.Max) + ", Math.max(" + formatJsNumber(generator.Min) + ", " + jsVariable + " + (Math.random() - 0.5) * " + step + "))"
+			if generator.Integer {
+				walk = "Math.round(" + walk + ")"
+			}
+			this.lines = append(this.lines,
+				jsVariable+" = "+walk+";",
+				"moses.device.state.set("+jsString(generator.Key)+", "+jsVariable+");")
+		}
+		return jsVariable, nil
+	}
+}
+
+func jsString(value string) string {
+	b, _ := json.Marshal(value)
+	return string(b)
+}
+
+func formatJsNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// generates code for all inputs and outputs of the service and the initial device states used by the code
+// inputs are written to device states; outputs are generated from device states, matching the characteristics of the content variables
+// sensors (services without inputs) change number states by a random walk within the range of the characteristic on each call
+// with segments (more than one configured protocol segment) inputs are read from and outputs are sent in the protocol segment of their content
+func createServiceCode(service model.Service, characteristics characteristicLookup, segments segmentLookup) (code string, states map[string]interface{}, err error) {
+	builder := newServiceCodeBuilder(characteristics, len(service.Inputs) == 0)
+	lines := []string{}
+	if len(service.Inputs) > 0 {
+		for _, input := range service.Inputs {
+			skeleton, err := inputOutputSkeletonString(input.ContentVariable)
+			if err != nil {
+				return code, states, err
+			}
+			lines = append(lines, "/*", strings.TrimSpace(skeleton), "*/")
+		}
+		if segments == nil {
+			lines = append(lines, "var input = moses.service.input;")
+		} else {
+			lines = append(lines, "var segments = moses.service.segments;")
+		}
+		for _, input := range service.Inputs {
+			if segments == nil {
+				builder.addInput(input.ContentVariable, "input", nil)
+			} else {
+				builder.addInput(input.ContentVariable, "segments["+jsString(segments(input.ProtocolSegmentId))+"]", nil)
+			}
+		}
+	}
+	outputs := []string{}
+	outputSegments := []string{}
+	for _, output := range service.Outputs {
+		if output.ContentVariable.IsVoid {
+			continue
+		}
+		expression, err := builder.addOutput(output.ContentVariable)
+		if err != nil {
+			return code, states, err
+		}
+		outputs = append(outputs, expression)
+		if segments != nil {
+			outputSegments = append(outputSegments, segments(output.ProtocolSegmentId))
+		}
+	}
+	lines = append(lines, builder.lines...)
+	if segments == nil {
+		for i, expression := range outputs {
+			if i == 0 {
+				lines = append(lines, "var output = "+expression+";", "moses.service.send(output);")
+				continue
+			}
+			if i == 1 {
+				lines = append(lines, "//only one protocol segment is configured; only the first output is sent")
+			}
+			lines = append(lines, "var output"+strconv.Itoa(i+1)+" = "+expression+";")
+		}
+		return strings.Join(lines, "\n"), builder.States, nil
+	}
+	if len(outputs) == 0 {
+		return strings.Join(lines, "\n"), builder.States, nil
+	}
+	fields := []string{}
+	unsent := []string{}
+	sent := map[string]bool{}
+	for i, expression := range outputs {
+		if sent[outputSegments[i]] {
+			unsent = append(unsent, "var output"+strconv.Itoa(i+1)+" = "+expression+";")
+			continue
+		}
+		sent[outputSegments[i]] = true
+		fields = append(fields, jsString(outputSegments[i])+": "+expression)
+	}
+	lines = append(lines, "var output = {"+strings.Join(fields, ", ")+"};", "moses.service.sendSegments(output);")
+	if len(unsent) > 0 {
+		lines = append(lines, "//a protocol segment holds a single value; only the first output of each segment is sent")
+		lines = append(lines, unsent...)
+	}
+	return strings.Join(lines, "\n"), builder.States, nil
+}
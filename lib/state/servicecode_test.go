@@ -0,0 +1,197 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+)
+
+var testCharacteristics = map[string]model.Characteristic{
+	"celsius": {Id: "celsius", Name: "Celsius", DisplayUnit: "°C", Type: model.Float, MinValue: float64(-10), MaxValue: float64(40)},
+	"on":      {Id: "on", Name: "Boolean", Type: model.Boolean},
+	"percent": {Id: "percent", Name: "Percentage", DisplayUnit: "%", Type: model.Integer},
+	"hex":     {Id: "hex", Name: "Hex", Type: model.String},
+}
+
+func testCharacteristicLookup(id string) (model.Characteristic, bool) {
+	characteristic, ok := testCharacteristics[id]
+	return characteristic, ok
+}
+
+func TestGetValueGenerator(t *testing.T) {
+	generator := getValueGenerator(model.ContentVariable{Name: "temperature", Type: model.Float}, testCharacteristics["celsius"], true)
+	if generator.Kind != valueGeneratorNumber || generator.Min != -10 || generator.Max != 40 || generator.Initial != float64(15) {
+		t.Error(generator)
+	}
+	generator = getValueGenerator(model.ContentVariable{Name: "temp", Type: model.Float}, model.Characteristic{DisplayUnit: "°C"}, true)
+	if generator.Min != 15 || generator.Max != 25 || generator.Initial != float64(21) {
+		t.Error(generator)
+	}
+	generator = getValueGenerator(model.ContentVariable{Name: "brightness", Type: model.Integer}, testCharacteristics["percent"], true)
+	if generator.Min != 0 || generator.Max != 100 || generator.Initial != float64(50) || !generator.Integer {
+		t.Error(generator)
+	}
+	generator = getValueGenerator(model.ContentVariable{Name: "color", Type: model.String}, testCharacteristics["hex"], true)
+	if generator.Kind != valueGeneratorString || generator.Initial != "#ffffff" {
+		t.Error(generator)
+	}
+	generator = getValueGenerator(model.ContentVariable{Name: "time", Type: model.String}, model.Characteristic{}, false)
+	if generator.Kind != valueGeneratorTime {
+		t.Error(generator)
+	}
+	generator = getValueGenerator(model.ContentVariable{Name: "unit", Type: model.String, Value: "°C"}, model.Characteristic{}, false)
+	if generator.Kind != valueGeneratorFixed || generator.Fixed != "°C" {
+		t.Error(generator)
+	}
+}
+
+func TestCreateServiceCodeSensor(t *testing.T) {
+	service := model.Service{Outputs: []model.Content{{ContentVariable: model.ContentVariable{
+		Name: "state",
+		Type: model.Structure,
+		SubContentVariables: []model.ContentVariable{
+			{Name: "temperature", Type: model.Float, CharacteristicId: "celsius"},
+			{Name: "on", Type: model.Boolean, CharacteristicId: "on"},
+			{Name: "unit", Type: model.String, Value: "°C"},
+			{Name: "time", Type: model.String},
+		},
+	}}}}
+	code, states, err := createServiceCode(service, testCharacteristicLookup, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 2 || states["temperature"] != float64(15) || states["on"] != false {
+		t.Error(states)
+	}
+
+	var sent interface{}
+	repo := &StateRepo{Persistence: nopPersistence{}, sensorDataHandler: func(device *Device, service Service, value interface{}) {
+		sent = value
+	}}
+	device := &Device{Id: "d", States: states}
+	room := &Room{Id: "r", Devices: map[string]*Device{"d": device}}
+	world := &World{Id: "w", Rooms: map[string]*Room{"r": room}, mux: &sync.Mutex{}}
+	err = run(code, repo.getJsSensorApi(world, room, device, Service{Id: "s"}), time.Second, world.mux)
+	if err != nil {
+		t.Fatal(err, "\n", code)
+	}
+	output, ok := sent.(map[string]interface{})
+	if !ok {
+		t.Fatal(sent, "\n", code)
+	}
+	temperature, ok := output["temperature"].(float64)
+	if !ok || temperature < 14 || temperature > 16 || device.States["temperature"] != temperature {
+		t.Error(output, device.States)
+	}
+	if output["on"] != false || output["unit"] != "°C" {
+		t.Error(output)
+	}
+	if timeStr, ok := output["time"].(string); !ok || !strings.Contains(timeStr, "T") {
+		t.Error(output)
+	}
+}
+
+func TestCreateServiceCodeActuator(t *testing.T) {
+	service := model.Service{
+		Inputs: []model.Content{{ContentVariable: model.ContentVariable{
+			Name: "command",
+			Type: model.Structure,
+			SubContentVariables: []model.ContentVariable{
+				{Name: "brightness", Type: model.Integer, CharacteristicId: "percent"},
+				{Name: "duration", Type: model.Float},
+			},
+		}}},
+		Outputs: []model.Content{{ContentVariable: model.ContentVariable{Name: "brightness", Type: model.Integer, CharacteristicId: "percent"}}},
+	}
+	code, states, err := createServiceCode(service, testCharacteristicLookup, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if states["brightness"] != float64(50) || states["duration"] != float64(0) {
+		t.Error(states)
+	}
+
+	var response interface{}
+	repo := &StateRepo{Persistence: nopPersistence{}}
+	device := &Device{Id: "d", States: states}
+	room := &Room{Id: "r", Devices: map[string]*Device{"d": device}}
+	world := &World{Id: "w", Rooms: map[string]*Room{"r": room}, mux: &sync.Mutex{}}
+	input := map[string]interface{}{"brightness": float64(80)}
+	err = run(code, repo.getJsCommandApi(world, room, device, Service{Id: "s"}, input, func(respMsg interface{}) {
+		response = respMsg
+	}), time.Second, world.mux)
+	if err != nil {
+		t.Fatal(err, "\n", code)
+	}
+	if device.States["brightness"] != float64(80) || device.States["duration"] != float64(0) {
+		t.Error(device.States)
+	}
+	if response != float64(80) {
+		t.Error(response, "\n", code)
+	}
+}
+
+func TestCreateServiceCodeSegments(t *testing.T) {
+	service := model.Service{
+		Inputs: []model.Content{
+			{ProtocolSegmentId: "seg-payload", ContentVariable: model.ContentVariable{Name: "brightness", Type: model.Integer, CharacteristicId: "percent"}},
+			{ProtocolSegmentId: "seg-metadata", ContentVariable: model.ContentVariable{Name: "source", Type: model.String}},
+		},
+		Outputs: []model.Content{
+			{ProtocolSegmentId: "seg-payload", ContentVariable: model.ContentVariable{Name: "brightness", Type: model.Integer, CharacteristicId: "percent"}},
+			{ProtocolSegmentId: "seg-metadata", ContentVariable: model.ContentVariable{Name: "source", Type: model.String}},
+			{ProtocolSegmentId: "seg-metadata", ContentVariable: model.ContentVariable{Name: "unit", Type: model.String, Value: "%"}},
+		},
+	}
+	repo := &StateRepo{
+		Persistence:           nopPersistence{},
+		Config:                config.Config{ProtocolSegmentName: "payload", ProtocolSegments: []string{"payload", "metadata"}},
+		mosesProtocolSegments: map[string]string{"seg-payload": "payload", "seg-metadata": "metadata"},
+	}
+	code, states, err := createServiceCode(service, testCharacteristicLookup, repo.getSegmentLookup())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(code, "moses.service.sendSegments(") {
+		t.Error(code)
+	}
+
+	var response interface{}
+	device := &Device{Id: "d", States: states}
+	room := &Room{Id: "r", Devices: map[string]*Device{"d": device}}
+	world := &World{Id: "w", Rooms: map[string]*Room{"r": room}, mux: &sync.Mutex{}}
+	input := SegmentedValue{"payload": float64(80), "metadata": "test"}
+	err = run(code, repo.getJsCommandApi(world, room, device, Service{Id: "s"}, input, func(respMsg interface{}) {
+		response = respMsg
+	}), time.Second, world.mux)
+	if err != nil {
+		t.Fatal(err, "\n", code)
+	}
+	if device.States["brightness"] != float64(80) || device.States["source"] != "test" {
+		t.Error(device.States)
+	}
+	if !reflect.DeepEqual(response, SegmentedValue{"payload": float64(80), "metadata": "test"}) {
+		t.Error(response, "\n", code)
+	}
+}
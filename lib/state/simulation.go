@@ -0,0 +1,469 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+)
+
+const (
+	SimulationFormatJsonLines = "jsonl"
+	SimulationFormatCsv       = "csv"
+)
+
+const simulationMaxSteps = 1000000 //max number of state samples and of routine runs of a simulation
+
+const (
+	SimulationRecordSensor = "sensor"
+	SimulationRecordState  = "state"
+	SimulationRecordEffect = "effect"
+)
+
+type SimulationRequest struct {
+	Start          time.Time `json:"start"`           //virtual start time; defaults to now
+	Duration       int64     `json:"duration"`        //simulated time in seconds
+	SampleInterval int64     `json:"sample_interval"` //seconds between state samples; defaults to 60; states are not sampled if < 0
+	Format         string    `json:"format"`          //"jsonl" || "csv"; defaults to "jsonl"
+}
+
+type SimulationRecord struct {
+	Time    time.Time   `json:"time"`
+	Type    string      `json:"type"`     // "sensor" || "state" || "effect"
+	RefType string      `json:"ref_type"` // "world" || "room" || "device"
+	RefId   string      `json:"ref_id"`
+	Service string      `json:"service,omitempty"`
+	Key     string      `json:"key,omitempty"`
+	Value   interface{} `json:"value"`
+	Source  string      `json:"source,omitempty"` //device causing an effect
+}
+
+type simulationRoutine struct {
+	key      string
+	next     time.Time
+	interval time.Duration
+	code     string
+	api      map[string]interface{}
+	info     string
+	fn       func() //used instead of code if set
+}
+
+type simulationRecordWriter interface {
+	Write(record SimulationRecord) error
+	Flush() error
+}
+
+// persistence of simulated worlds; a temporary bolt database which is removed on Close()
+type simulationPersistence struct {
+	*BoltPersistence
+	dir string
+}
+
+func newSimulationPersistence() (result simulationPersistence, err error) {
+	result.dir, err = os.MkdirTemp("", "moses-simulation-")
+	if err != nil {
+		return result, err
+	}
+	bolt, err := NewBoltPersistence(config.Config{
+		WorldCollectionName:     "worlds",
+		GraphCollectionName:     "graphs",
+		TemplateCollectionName:  "templates",
+		ScenarioCollectionName:  "scenarios",
+		SnapshotCollectionName:  "snapshots",
+		BlueprintCollectionName: "blueprints",
+		CommandCollectionName:   "commands",
+		StateCollectionName:     "states",
+		BoltFile:                filepath.Join(result.dir, "simulation.db"),
+	})
+	if err != nil {
+		os.RemoveAll(result.dir)
+		return result, err
+	}
+	bolt.db.NoSync = true //the database is discarded after the simulation
+	result.BoltPersistence = &bolt
+	return result, nil
+}
+
+func (this simulationPersistence) Close() {
+	this.BoltPersistence.Close()
+	err := os.RemoveAll(this.dir)
+	if err != nil {
+		log.Println("WARNING: unable to remove simulation persistence", this.dir, err)
+	}
+}
+
+// runs all change routines and sensor services of a copy of the world against a virtual clock
+// sensor data and sampled states are written to out instead of being sent to the platform
+// the world itself is not changed; the simulation is aborted if ctx is done
+func (this *StateRepo) SimulateWorld(ctx context.Context, jwt jwt.Jwt, worldId string, msg SimulationRequest, out io.Writer) (access bool, exists bool, err error) {
+	worldMsg, access, exists, err := this.ReadWorld(jwt, worldId)
+	if err != nil || !access || !exists {
+		return access, exists, err
+	}
+	writer, err := getSimulationRecordWriter(msg.Format, out)
+	if err != nil {
+		return true, true, err
+	}
+	err = this.simulateWorld(ctx, worldMsg, msg, writer)
+	return true, true, err
+}
+
+func ValidateSimulationRequest(msg SimulationRequest) error {
+	if msg.Duration <= 0 {
+		return errors.New("expect duration > 0")
+	}
+	sampleInterval := msg.SampleInterval
+	if sampleInterval == 0 {
+		sampleInterval = 60
+	}
+	if sampleInterval > 0 && msg.Duration/sampleInterval > simulationMaxSteps {
+		return fmt.Errorf("expect at most %d state samples", simulationMaxSteps)
+	}
+	switch msg.Format {
+	case "", SimulationFormatJsonLines, SimulationFormatCsv:
+	default:
+		return errors.New("unknown format: " + msg.Format)
+	}
+	return nil
+}
+
+func getSimulationRecordWriter(format string, out io.Writer) (simulationRecordWriter, error) {
+	switch format {
+	case "", SimulationFormatJsonLines:
+		return &simulationJsonLinesWriter{encoder: json.NewEncoder(out)}, nil
+	case SimulationFormatCsv:
+		return &simulationCsvWriter{writer: csv.NewWriter(out)}, nil
+	default:
+		return nil, errors.New("unknown format: " + format)
+	}
+}
+
+func (this *StateRepo) simulateWorld(ctx context.Context, worldMsg WorldMsg, msg SimulationRequest, writer simulationRecordWriter) (err error) {
+	err = ValidateSimulationRequest(msg)
+	if err != nil {
+		return err
+	}
+	world, err := worldMsg.ToModel()
+	if err != nil {
+		return err
+	}
+	world.Webhooks = nil //simulated changes are not published
+	removeSimulationExternalRefs(&world)
+	now := msg.Start
+	if now.IsZero() {
+		now = time.Now()
+	}
+	end := now.Add(time.Duration(msg.Duration) * time.Second)
+	sampleInterval := time.Duration(msg.SampleInterval) * time.Second
+	if msg.SampleInterval == 0 {
+		sampleInterval = time.Minute
+	}
+	nextSample := now
+
+	persistence, err := newSimulationPersistence()
+	if err != nil {
+		return err
+	}
+	defer persistence.Close()
+
+	var writeErr error
+	sim := &StateRepo{
+		Config:      this.Config,
+		Persistence: persistence,
+		Worlds:      map[string]*World{world.Id: &world},
+		clock: func() time.Time {
+			return now
+		},
+		sensorDataHandler: func(device *Device, service Service, value interface{}) {
+			if writeErr != nil {
+				return
+			}
+			writeErr = writer.Write(SimulationRecord{
+				Time:    now,
+				Type:    SimulationRecordSensor,
+				RefType: "device",
+				RefId:   device.Id,
+				Service: service.Id,
+				Value:   value,
+			})
+		},
+	}
+
+	routines := sim.getSimulationRoutines(&world, now, writer, &writeErr)
+	steps := int64(0)
+	for _, routine := range routines {
+		steps += int64(end.Sub(now)/routine.interval) + 1
+	}
+	if steps > simulationMaxSteps {
+		return fmt.Errorf("simulation needs %d routine runs; expect at most %d", steps, simulationMaxSteps)
+	}
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var routine *simulationRoutine
+		for _, r := range routines {
+			if routine == nil || r.next.Before(routine.next) {
+				routine = r
+			}
+		}
+		sample := sampleInterval > 0 && (routine == nil || nextSample.Before(routine.next))
+		if sample {
+			if nextSample.After(end) {
+				break
+			}
+			now = nextSample
+			err = writeSimulationStates(&world, now, writer)
+			if err != nil {
+				return err
+			}
+			nextSample = nextSample.Add(sampleInterval)
+			continue
+		}
+		if routine == nil || routine.next.After(end) {
+			break
+		}
+		now = routine.next
+		if routine.fn != nil {
+			routine.fn()
+		} else {
+			runErr := run(routine.code, routine.api, this.Config.JsTimeout, world.mux)
+			if runErr != nil {
+				log.Println("ERROR: simulateWorld()", runErr, "\n", routine.info, "\n", trimCodeDefault(routine.code))
+			}
+		}
+		if writeErr != nil {
+			return writeErr
+		}
+		routine.next = routine.next.Add(routine.interval)
+	}
+	return writer.Flush()
+}
+
+// simulated devices and hubs are not connected to the platform; without external refs their connection states are not logged
+func removeSimulationExternalRefs(world *World) {
+	if world.Hub != nil {
+		world.Hub.ExternalRef = ""
+	}
+	for _, room := range world.Rooms {
+		if room.Hub != nil {
+			room.Hub.ExternalRef = ""
+		}
+		for _, device := range room.Devices {
+			device.ExternalRef = ""
+		}
+	}
+}
+
+// returns all active change routines and sensor services of the world, sorted by key for reproducible results
+// effects are applied before metering; changes caused by effects are written to writer
+func (this *StateRepo) getSimulationRoutines(world *World, start time.Time, writer simulationRecordWriter, writeErr *error) (result []*simulationRoutine) {
+	add := func(key string, interval int64, code string, api map[string]interface{}, info string) {
+		result = append(result, &simulationRoutine{
+			key:      key,
+			next:     start.Add(time.Duration(interval) * time.Second),
+			interval: time.Duration(interval) * time.Second,
+			code:     code,
+			api:      api,
+			info:     info,
+		})
+	}
+	for _, routine := range world.ChangeRoutines {
+		if routine.Interval > 0 && !routine.Disabled {
+			add(routine.Id, routine.Interval, routine.Code, this.getJsWorldApi(world), fmt.Sprintf("world:%s", world.Name))
+		}
+	}
+	for _, room := range world.Rooms {
+		for _, routine := range room.ChangeRoutines {
+			if routine.Interval > 0 && !routine.Disabled {
+				add(routine.Id, routine.Interval, routine.Code, this.getJsRoomApi(world, room), fmt.Sprintf("world: %s, room:%s", world.Name, room.Name))
+			}
+		}
+		for _, device := range room.Devices {
+			for _, routine := range device.ChangeRoutines {
+				if routine.Interval > 0 && !routine.Disabled {
+					add(routine.Id, routine.Interval, routine.Code, this.getJsDeviceApi(world, room, device), fmt.Sprintf("world: %s, room:%s, device:%s", world.Name, room.Name, device.Name))
+				}
+			}
+			for _, service := range device.Services {
+				if service.SensorInterval > 0 {
+					add(service.Id, service.SensorInterval, service.Code, this.getJsSensorApi(world, room, device, service), fmt.Sprintf("world: %s, room:%s, device:%s, service:%s", world.Name, room.Name, device.Name, service.Name))
+				}
+			}
+		}
+	}
+	if hasDeviceEffects(world) {
+		interval := this.getEffectInterval()
+		result = append(result, &simulationRoutine{
+			key:      "",
+			next:     start,
+			interval: interval,
+			info:     fmt.Sprintf("world:%s effects", world.Name),
+			fn: func() {
+				world.mux.Lock()
+				defer world.mux.Unlock()
+				for _, change := range applyWorldEffects(world, interval) {
+					err := writer.Write(SimulationRecord{
+						Time:    this.now(),
+						Type:    SimulationRecordEffect,
+						RefType: change.RefType,
+						RefId:   change.RefId,
+						Key:     change.Key,
+						Value:   change.To,
+						Source:  change.Device,
+					})
+					if err != nil {
+						*writeErr = err
+						return
+					}
+				}
+			},
+		})
+	}
+	if hasPowerModels(world) {
+		interval := this.getMeteringInterval()
+		result = append(result, &simulationRoutine{
+			key:      "",
+			next:     start,
+			interval: interval,
+			info:     fmt.Sprintf("world:%s metering", world.Name),
+			fn: func() {
+				world.mux.Lock()
+				defer world.mux.Unlock()
+				this.updateWorldMetering(world, this.now())
+			},
+		})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].key < result[j].key
+	})
+	return result
+}
+
+func writeSimulationStates(world *World, now time.Time, writer simulationRecordWriter) (err error) {
+	world.mux.Lock()
+	defer world.mux.Unlock()
+	err = writeSimulationStateMap(now, "world", world.Id, world.States, writer)
+	if err != nil {
+		return err
+	}
+	for _, room := range sortedRooms(world.Rooms) {
+		err = writeSimulationStateMap(now, "room", room.Id, room.States, writer)
+		if err != nil {
+			return err
+		}
+		for _, device := range sortedDevices(room.Devices) {
+			err = writeSimulationStateMap(now, "device", device.Id, device.States, writer)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeSimulationStateMap(now time.Time, refType string, refId string, states map[string]interface{}, writer simulationRecordWriter) (err error) {
+	keys := []string{}
+	for key := range states {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		err = writer.Write(SimulationRecord{
+			Time:    now,
+			Type:    SimulationRecordState,
+			RefType: refType,
+			RefId:   refId,
+			Key:     key,
+			Value:   states[key],
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedRooms(rooms map[string]*Room) (result []*Room) {
+	for _, room := range rooms {
+		result = append(result, room)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id < result[j].Id
+	})
+	return result
+}
+
+func sortedDevices(devices map[string]*Device) (result []*Device) {
+	for _, device := range devices {
+		result = append(result, device)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id < result[j].Id
+	})
+	return result
+}
+
+type simulationJsonLinesWriter struct {
+	encoder *json.Encoder
+}
+
+func (this *simulationJsonLinesWriter) Write(record SimulationRecord) error {
+	return this.encoder.Encode(record)
+}
+
+func (this *simulationJsonLinesWriter) Flush() error {
+	return nil
+}
+
+// values are json encoded
+type simulationCsvWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (this *simulationCsvWriter) Write(record SimulationRecord) error {
+	if !this.headerWritten {
+		err := this.writer.Write([]string{"time", "type", "ref_type", "ref_id", "service", "key", "value", "source"})
+		if err != nil {
+			return err
+		}
+		this.headerWritten = true
+	}
+	value, err := json.Marshal(record.Value)
+	if err != nil {
+		return err
+	}
+	return this.writer.Write([]string{record.Time.Format(time.RFC3339Nano), record.Type, record.RefType, record.RefId, record.Service, record.Key, string(value), record.Source})
+}
+
+func (this *simulationCsvWriter) Flush() error {
+	this.writer.Flush()
+	return this.writer.Error()
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/config"
+)
+
+func getSimulationTestWorld() WorldMsg {
+	return WorldMsg{
+		Id:     "w",
+		Name:   "world",
+		States: map[string]interface{}{"temp": float64(10)},
+		ChangeRoutines: map[string]ChangeRoutine{
+			"r1": {Id: "r1", Interval: 3600, Code: `moses.world.state.set("temp", moses.world.state.get("temp") + 1)`},
+			"r2": {Id: "r2", Interval: 60, Code: `moses.world.state.set("never", 1)`, Disabled: true},
+		},
+		Rooms: map[string]RoomMsg{
+			"r": {
+				Id:   "r",
+				Name: "room",
+				Devices: map[string]DeviceMsg{
+					"d": {
+						Id:   "d",
+						Name: "device",
+						Services: map[string]Service{
+							"s": {Id: "s", Name: "sensor", SensorInterval: 1800, Code: `moses.service.send({"temp": moses.world.state.get("temp")})`},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestSimulateWorldJsonLines(t *testing.T) {
+	repo := &StateRepo{Config: config.Config{JsTimeout: time.Second}}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	world := getSimulationTestWorld()
+	out := &bytes.Buffer{}
+	writer, err := getSimulationRecordWriter(SimulationFormatJsonLines, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.simulateWorld(context.Background(), world, SimulationRequest{Start: start, Duration: 2 * 3600, SampleInterval: 3600}, writer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := []SimulationRecord{}
+	decoder := json.NewDecoder(out)
+	for decoder.More() {
+		record := SimulationRecord{}
+		err = decoder.Decode(&record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+
+	sensorValues := []interface{}{}
+	stateValues := []interface{}{}
+	for _, record := range records {
+		switch record.Type {
+		case SimulationRecordSensor:
+			sensorValues = append(sensorValues, record.Value.(map[string]interface{})["temp"])
+		case SimulationRecordState:
+			if record.Key == "never" {
+				t.Error("disabled routine was executed")
+			}
+			if record.RefType == "world" && record.Key == "temp" {
+				stateValues = append(stateValues, record.Value)
+			}
+		}
+	}
+	if len(sensorValues) != 4 || sensorValues[0] != float64(10) || sensorValues[3] != float64(12) {
+		t.Error(sensorValues)
+	}
+	if len(stateValues) != 3 || stateValues[0] != float64(10) || stateValues[2] != float64(12) {
+		t.Error(stateValues)
+	}
+	if world.States["temp"] != float64(10) {
+		t.Error("source world was changed", world.States)
+	}
+}
+
+func TestSimulateWorldCsv(t *testing.T) {
+	repo := &StateRepo{Config: config.Config{JsTimeout: time.Second}}
+	out := &bytes.Buffer{}
+	writer, err := getSimulationRecordWriter(SimulationFormatCsv, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.simulateWorld(context.Background(), getSimulationTestWorld(), SimulationRequest{Duration: 3600, SampleInterval: -1}, writer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || lines[0] != "time,type,ref_type,ref_id,service,key,value,source" {
+		t.Error(lines)
+	}
+	for _, line := range lines[1:] {
+		if !strings.Contains(line, ",sensor,device,d,s,,") {
+			t.Error(line)
+		}
+	}
+}
+
+func TestSimulateWorldLimits(t *testing.T) {
+	if ValidateSimulationRequest(SimulationRequest{Duration: 2 * simulationMaxSteps, SampleInterval: 1}) == nil {
+		t.Error("expected error for too many state samples")
+	}
+	if ValidateSimulationRequest(SimulationRequest{Duration: 2 * simulationMaxSteps, SampleInterval: -1}) != nil {
+		t.Error("unexpected error without state samples")
+	}
+
+	repo := &StateRepo{Config: config.Config{JsTimeout: time.Second}}
+	out := &bytes.Buffer{}
+	writer, err := getSimulationRecordWriter(SimulationFormatJsonLines, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.simulateWorld(context.Background(), getSimulationTestWorld(), SimulationRequest{Duration: 3600 * simulationMaxSteps, SampleInterval: -1}, writer)
+	if err == nil || out.Len() != 0 {
+		t.Error("expected error before the first record for too many routine runs", err, out.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = repo.simulateWorld(ctx, getSimulationTestWorld(), SimulationRequest{Duration: 3600}, writer)
+	if err != context.Canceled {
+		t.Error(err)
+	}
+}
+
+func TestSimulationPersistenceIsRemoved(t *testing.T) {
+	persistence, err := newSimulationPersistence()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = persistence.PersistStates([]EntityStates{newEntityStates("w", "world", "w", map[string]interface{}{"foo": 1})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	persistence.Close()
+	if _, err = os.Stat(persistence.dir); !os.IsNotExist(err) {
+		t.Error("simulation persistence has not been removed", err)
+	}
+}
@@ -0,0 +1,193 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/globalsign/mgo"
+	"github.com/google/uuid"
+)
+
+// WorldSnapshot is a point-in-time copy of a world with its rooms, devices, services, states and change routines
+type WorldSnapshot struct {
+	Id      string    `json:"id" bson:"id"`
+	World   string    `json:"world" bson:"world"`
+	Owner   string    `json:"-" bson:"owner"`
+	Name    string    `json:"name" bson:"name"`
+	Created time.Time `json:"created" bson:"created"`
+	Data    *WorldMsg `json:"data,omitempty" bson:"data,omitempty"`
+}
+
+type CreateSnapshotRequest struct {
+	Name string `json:"name"`
+}
+
+// SnapshotDiff describes a changed value; path elements are separated by '.', e.g. "rooms.<room-id>.states.temperature"
+type SnapshotDiff struct {
+	Path string      `json:"path"`
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+func (this *StateRepo) CreateSnapshot(jwt jwt.Jwt, worldId string, msg CreateSnapshotRequest) (result WorldSnapshot, access bool, exists bool, err error) {
+	world, access, exists, err := this.ReadWorld(jwt, worldId)
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	result = WorldSnapshot{
+		Id:      uuid.NewString(),
+		World:   world.Id,
+		Owner:   world.Owner,
+		Name:    msg.Name,
+		Created: this.now(),
+		Data:    &world,
+	}
+	err = this.Persistence.PersistSnapshot(result)
+	return result, true, true, err
+}
+
+// returns snapshots of the world without data
+func (this *StateRepo) ReadSnapshots(jwt jwt.Jwt, worldId string) (result []WorldSnapshot, access bool, exists bool, err error) {
+	_, access, exists, err = this.ReadWorld(jwt, worldId)
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	result, err = this.Persistence.GetSnapshots(worldId)
+	if result == nil {
+		result = []WorldSnapshot{}
+	}
+	return result, true, true, err
+}
+
+func (this *StateRepo) ReadSnapshot(jwt jwt.Jwt, worldId string, id string) (result WorldSnapshot, access bool, exists bool, err error) {
+	_, access, exists, err = this.ReadWorld(jwt, worldId)
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	result, err = this.Persistence.GetSnapshot(id)
+	if err == mgo.ErrNotFound {
+		return result, true, false, nil
+	}
+	if err != nil {
+		return result, true, false, err
+	}
+	if result.World != worldId || result.Data == nil {
+		return WorldSnapshot{}, true, false, nil
+	}
+	result.Data.Webhooks = maskWebhookSecrets(result.Data.Webhooks)
+	return result, true, true, nil
+}
+
+func (this *StateRepo) DeleteSnapshot(jwt jwt.Jwt, worldId string, id string) (access bool, exists bool, err error) {
+	_, access, exists, err = this.ReadSnapshot(jwt, worldId, id)
+	if err != nil || !access || !exists {
+		return access, exists, err
+	}
+	err = this.Persistence.DeleteSnapshot(id)
+	return true, true, err
+}
+
+// compares the snapshot with the snapshot toId; if toId is empty the snapshot is compared with the current world
+func (this *StateRepo) DiffSnapshot(jwt jwt.Jwt, worldId string, id string, toId string) (result []SnapshotDiff, access bool, exists bool, err error) {
+	from, access, exists, err := this.ReadSnapshot(jwt, worldId, id)
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	var to WorldMsg
+	if toId == "" {
+		to, access, exists, err = this.ReadWorld(jwt, worldId)
+	} else {
+		var toSnapshot WorldSnapshot
+		toSnapshot, access, exists, err = this.ReadSnapshot(jwt, worldId, toId)
+		if toSnapshot.Data != nil {
+			to = *toSnapshot.Data
+		}
+	}
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	result, err = DiffWorlds(*from.Data, to)
+	return result, true, true, err
+}
+
+// replaces the current world with the snapshot; the webhooks of the current world are kept; change routines are restarted
+func (this *StateRepo) RestoreSnapshot(jwt jwt.Jwt, worldId string, id string) (result WorldMsg, access bool, exists bool, err error) {
+	snapshot, access, exists, err := this.ReadSnapshot(jwt, worldId, id)
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	current, access, exists, err := this.ReadWorld(jwt, worldId)
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	result = *snapshot.Data
+	result.Id = worldId
+	result.Owner = jwt.UserId
+	result.Webhooks = current.Webhooks
+	err = this.DevUpdateWorld(result)
+	if err == nil {
+		this.refreshHubs(jwt, worldId)
+	}
+	return result, true, true, err
+}
+
+func DiffWorlds(from WorldMsg, to WorldMsg) (result []SnapshotDiff, err error) {
+	var fromObj, toObj interface{}
+	err = jsonCopy(from, &fromObj)
+	if err != nil {
+		return result, err
+	}
+	err = jsonCopy(to, &toObj)
+	if err != nil {
+		return result, err
+	}
+	result = []SnapshotDiff{}
+	diffValues("", fromObj, toObj, &result)
+	return result, nil
+}
+
+func diffValues(path string, from interface{}, to interface{}, result *[]SnapshotDiff) {
+	fromMap, fromIsMap := from.(map[string]interface{})
+	toMap, toIsMap := to.(map[string]interface{})
+	if fromIsMap && toIsMap {
+		keys := []string{}
+		for key := range fromMap {
+			keys = append(keys, key)
+		}
+		for key := range toMap {
+			if _, ok := fromMap[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			subPath := key
+			if path != "" {
+				subPath = path + "." + key
+			}
+			diffValues(subPath, fromMap[key], toMap[key], result)
+		}
+		return
+	}
+	if !reflect.DeepEqual(from, to) {
+		*result = append(*result, SnapshotDiff{Path: path, From: from, To: to})
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+)
+
+func TestDiffWorlds(t *testing.T) {
+	from := WorldMsg{
+		Id:     "w",
+		States: map[string]interface{}{"temperature": 20, "humidity": 50},
+		Rooms: map[string]RoomMsg{
+			"r1": {Id: "r1", Name: "room", States: map[string]interface{}{"temperature": 21}},
+		},
+	}
+	to := WorldMsg{
+		Id:     "w",
+		States: map[string]interface{}{"temperature": 25, "humidity": 50},
+		Rooms: map[string]RoomMsg{
+			"r1": {Id: "r1", Name: "renamed", States: map[string]interface{}{"temperature": 21}},
+			"r2": {Id: "r2", Name: "new"},
+		},
+	}
+	result, err := DiffWorlds(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{}
+	for _, diff := range result {
+		paths = append(paths, diff.Path)
+	}
+	expected := []string{"rooms.r1.name", "rooms.r2", "states.temperature"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Error(paths, result)
+	}
+	if result[2].From != float64(20) || result[2].To != float64(25) {
+		t.Error(result[2])
+	}
+
+	result, err = DiffWorlds(from, from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 0 {
+		t.Error(result)
+	}
+}
+
+func TestCreateSnapshotUsesClock(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo := &StateRepo{
+		Persistence: nopPersistence{},
+		Worlds:      map[string]*World{"w": {Id: "w", Owner: "user", mux: &sync.Mutex{}, States: map[string]interface{}{}, Rooms: map[string]*Room{}}},
+		clock: func() time.Time {
+			return now
+		},
+	}
+	snapshot, access, exists, err := repo.CreateSnapshot(jwt.Jwt{UserId: "user"}, "w", CreateSnapshotRequest{Name: "snapshot"})
+	if err != nil || !access || !exists {
+		t.Fatal(err, access, exists)
+	}
+	if !snapshot.Created.Equal(now) {
+		t.Error(snapshot.Created)
+	}
+}
@@ -18,14 +18,26 @@ package state
 
 import (
 	"fmt"
-	"log"
 	"time"
 )
 
 func (this *StateRepo) StartWorld(world *World) (tickers []*time.Ticker, stops []chan bool, err error) {
+	if world.Hub != nil {
+		this.logHubConnection(world.Hub)
+	}
+	if hasDeviceEffects(world) {
+		ticker, stop := this.startEffects(world)
+		tickers = append(tickers, ticker)
+		stops = append(stops, stop)
+	}
+	if hasPowerModels(world) {
+		ticker, stop := this.startMetering(world)
+		tickers = append(tickers, ticker)
+		stops = append(stops, stop)
+	}
 	for _, routine := range world.ChangeRoutines {
 		this.changeRoutineIndex[routine.Id] = ChangeRoutineIndexElement{Id: routine.Id, RefType: "world", RefId: world.Id}
-		if routine.Interval > 0 {
+		if routine.Interval > 0 && !routine.Disabled {
 			ticker, stop := startChangeRoutine(
 				routine,
 				this.getJsWorldApi(world),
@@ -49,9 +61,12 @@ func (this *StateRepo) StartWorld(world *World) (tickers []*time.Ticker, stops [
 
 func (this *StateRepo) StartRoom(world *World, room *Room) (tickers []*time.Ticker, stops []chan bool, err error) {
 	this.roomWorldIndex[room.Id] = world
+	if room.Hub != nil {
+		this.logHubConnection(room.Hub)
+	}
 	for _, routine := range room.ChangeRoutines {
 		this.changeRoutineIndex[routine.Id] = ChangeRoutineIndexElement{Id: routine.Id, RefType: "room", RefId: room.Id}
-		if routine.Interval > 0 {
+		if routine.Interval > 0 && !routine.Disabled {
 			ticker, stop := startChangeRoutine(
 				routine,
 				this.getJsRoomApi(world, room),
@@ -74,16 +89,13 @@ func (this *StateRepo) StartRoom(world *World, room *Room) (tickers []*time.Tick
 }
 
 func (this *StateRepo) StartDevice(world *World, room *Room, device *Device) (tickers []*time.Ticker, stops []chan bool, err error) {
-	err = this.StateLogger.LogDeviceConnect(device.ExternalRef)
-	if err != nil {
-		log.Println("WARNING: unable to log device as online", err)
-	}
+	this.logDeviceConnection(world, device)
 	this.externalRefDeviceIndex[device.ExternalRef] = device
 	this.deviceRoomIndex[device.Id] = room
 	this.deviceWorldIndex[device.Id] = world
 	for _, routine := range device.ChangeRoutines {
 		this.changeRoutineIndex[routine.Id] = ChangeRoutineIndexElement{Id: routine.Id, RefType: "device", RefId: device.Id}
-		if routine.Interval > 0 {
+		if routine.Interval > 0 && !routine.Disabled {
 			ticker, stop := startChangeRoutine(
 				routine,
 				this.getJsDeviceApi(world, room, device),
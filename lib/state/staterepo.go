@@ -17,13 +17,11 @@
 package state
 
 import (
-	"encoding/json"
 	"errors"
 	"github.com/SENERGY-Platform/moses/lib/config"
-	platform_connector_lib "github.com/SENERGY-Platform/platform-connector-lib"
 	"github.com/SENERGY-Platform/platform-connector-lib/connectionlog"
-	"github.com/SENERGY-Platform/platform-connector-lib/model"
 	"log"
+	"math/rand"
 	"runtime/debug"
 	"sync"
 	"time"
@@ -35,8 +33,9 @@ type StateRepo struct {
 	Worlds                 map[string]*World
 	Graphs                 map[string]*Graph
 	Persistence            PersistenceInterface
-	Connector              *platform_connector_lib.Connector
+	Connector              PlatformConnector
 	Config                 config.Config
+	Adapters               map[string]OutputAdapter //output adapters by name in addition to the platform adapter
 	changeRoutineIndex     map[string]ChangeRoutineIndexElement
 	externalRefDeviceIndex map[string]*Device
 	serviceDeviceIndex     map[string]*Device
@@ -47,7 +46,31 @@ type StateRepo struct {
 	stopChannels           []chan bool
 	mux                    sync.RWMutex
 	MosesProtocolId        string
+	mosesProtocolSegments  map[string]string //protocol segment id -> name
 	StateLogger            connectionlog.Logger
+	faultStatus            faultStatusRegistry
+	faultRand              *rand.Rand
+	faultRandMux           sync.Mutex
+	connectedDevices       map[string]bool //external device ref -> last logged connection state
+	connectedHubs          map[string]bool //external hub ref -> last logged connection state
+	connectionMux          sync.Mutex
+	scenarioRuns           map[string]*scenarioRun
+	scenarioMux            sync.Mutex
+	metering               meteringRegistry
+	pendingResponses       pendingResponseRegistry
+	sensorEvents           sensorEventRegistry
+	webhookDeadLetters     webhookDeadLetterRegistry
+	webhookQueues          webhookQueueRegistry
+	clock                  func() time.Time                                         //used instead of time.Now() if set; e.g. virtual time of simulations
+	sensorDataHandler      func(device *Device, service Service, value interface{}) //used instead of the connector if set; e.g. to capture simulated sensor data
+}
+
+// returns the current time of the state repo
+func (this *StateRepo) now() time.Time {
+	if this.clock != nil {
+		return this.clock()
+	}
+	return time.Now()
 }
 
 // Update for HTTP-DEV-API
@@ -72,6 +95,7 @@ func (this *StateRepo) DevUpdateWorld(worldMsg WorldMsg) (err error) {
 		}
 		world.Id = uid.String()
 	}
+	this.unmaskWebhookSecrets(&world)
 	err = this.persistWorld(world)
 	if err != nil {
 		log.Println("ERROR: DevUpdateWorld()::this.persistWorld(world)", err)
@@ -87,6 +111,51 @@ func (this *StateRepo) DevUpdateWorld(worldMsg WorldMsg) (err error) {
 	return
 }
 
+// Update for HTTP-DEV-API
+// persists all given worlds and restarts the change routines once
+// all worlds are converted before the first is persisted; if persisting fails, the already persisted worlds are restored
+// requests a mutex lock on the state repo
+func (this *StateRepo) DevUpdateWorlds(worldMsgs []WorldMsg) (err error) {
+	worlds := []World{}
+	for _, worldMsg := range worldMsgs {
+		if worldMsg.Id == "" {
+			return errors.New("missing world id")
+		}
+		world, err := worldMsg.ToModel()
+		if err != nil {
+			log.Println("ERROR: DevUpdateWorlds()::worldMsg.ToModel()", err)
+			return err
+		}
+		worlds = append(worlds, world)
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for i := range worlds {
+		this.unmaskWebhookSecrets(&worlds[i])
+	}
+	for i, world := range worlds {
+		err = this.persistWorld(world)
+		if err != nil {
+			log.Println("ERROR: DevUpdateWorlds()::this.persistWorld(world)", err)
+			this.restorePersistedWorlds(worlds[:i])
+			return err
+		}
+	}
+	err = this.Stop()
+	if err != nil {
+		log.Println("ERROR: DevUpdateWorlds()::this.Stop()", err)
+		return err
+	}
+	if this.Worlds == nil {
+		this.Worlds = map[string]*World{}
+	}
+	for i := range worlds {
+		this.Worlds[worlds[i].Id] = &worlds[i]
+	}
+	this.Start()
+	return
+}
+
 func (this *StateRepo) DevGetWorld(id string) (world WorldMsg, exist bool, err error) {
 	this.mux.Lock()
 	defer this.mux.Unlock()
@@ -223,11 +292,16 @@ func (this *StateRepo) Load() (err error) {
 		debug.PrintStack()
 		return err
 	}
-	this.MosesProtocolId, err = this.EnsureProtocol(this.Config.Protocol, []model.ProtocolSegment{{Name: this.Config.ProtocolSegmentName}})
+	protocol, err := this.EnsureProtocol(this.Config.Protocol, getProtocolSegments(this.Config))
 	if err != nil {
 		debug.PrintStack()
 		return err
 	}
+	this.MosesProtocolId = protocol.Id
+	this.mosesProtocolSegments = map[string]string{}
+	for _, segment := range protocol.ProtocolSegments {
+		this.mosesProtocolSegments[segment.Id] = segment.Name
+	}
 	this.Worlds, err = this.Persistence.LoadWorlds()
 	if err != nil {
 		debug.PrintStack()
@@ -278,35 +352,12 @@ func (this *StateRepo) Start() {
 		this.changeRoutinesTickers = append(this.changeRoutinesTickers, tickers...)
 		this.stopChannels = append(this.stopChannels, stops...)
 	}
+	this.refreshPendingResponses()
+	this.stopRemovedWebhookQueues()
+	this.logRemovedDevicesDisconnected()
+	this.logRemovedHubsDisconnected()
 
-	this.Connector.SetAsyncCommandHandler(func(commandRequest model.ProtocolMsg, requestMsg platform_connector_lib.CommandRequestMsg, t time.Time) (err error) {
-		msg := map[string]interface{}{}
-		for key, value := range requestMsg {
-			var msgPart interface{}
-			err = json.Unmarshal([]byte(value), &msgPart)
-			if err != nil {
-				msgPart = value
-			}
-			msg[key] = msgPart
-		}
-		this.HandleCommand(commandRequest.Metadata.Device.Id, commandRequest.Metadata.Service.Id, msg[this.Config.ProtocolSegmentName], func(respMsg interface{}) {
-			msg := platform_connector_lib.CommandResponseMsg{}
-			msgStr, err := json.Marshal(respMsg)
-			if err != nil {
-				log.Println("ERROR: ", err)
-				debug.PrintStack()
-				return
-			}
-			msg[this.Config.ProtocolSegmentName] = string(msgStr)
-			err = this.Connector.HandleCommandResponse(commandRequest, msg, platform_connector_lib.Sync)
-			if err != nil {
-				log.Println("ERROR: ", err)
-				debug.PrintStack()
-				return
-			}
-		})
-		return nil
-	})
+	this.startAdapters()
 	return
 }
 
@@ -315,37 +366,55 @@ func (this *StateRepo) persistWorld(world World) (err error) {
 	return this.Persistence.PersistWorld(world)
 }
 
-func (this *StateRepo) sendSensorData(device *Device, service Service, value interface{}) {
-	if this.Config.Debug {
-		log.Println("DEBUG: send sensor data for", device.Id, service.Id, value)
-	}
-	if device.ExternalRef == "" {
-		log.Println("WARNING: no external ref for device")
-		return
+// persists the current version of the given worlds again; worlds which are unknown to the repo are deleted
+// expects the state repo to be locked
+func (this *StateRepo) restorePersistedWorlds(worlds []World) {
+	for _, world := range worlds {
+		var err error
+		current, ok := this.Worlds[world.Id]
+		if ok {
+			current.mux.Lock()
+			err = this.persistWorld(*current)
+			current.mux.Unlock()
+		} else {
+			err = this.Persistence.DeleteWorld(world.Id)
+		}
+		if err != nil {
+			log.Println("ERROR: unable to restore persisted world", world.Id, err)
+		}
 	}
-	if service.ExternalRef == "" {
-		log.Println("WARNING: no external ref for service")
+}
+
+// expects the world to be locked
+func (this *StateRepo) sendSensorData(world *World, device *Device, service Service, value interface{}) {
+	if !isDeviceReachable(world, device) {
+		if this.Config.Debug {
+			log.Println("DEBUG: suppress sensor data of unreachable device", device.Id, service.Id)
+		}
 		return
 	}
-	token, err := this.Connector.Security().Access()
-	if err != nil {
-		log.Println("ERROR: ", err)
-		debug.PrintStack()
-		return
+	for _, faultyValue := range this.applyFaults(device, service.Id, value) {
+		if this.sensorDataHandler != nil {
+			this.sensorDataHandler(device, service, faultyValue)
+			continue
+		}
+		err := this.sendSensorValue(world, device, service, faultyValue)
+		this.recordSensorEvent(device, service, faultyValue, err)
+		this.notifySensorEvent(world, device, service, faultyValue)
 	}
+}
 
-	msg := platform_connector_lib.CommandResponseMsg{}
-	msgStr, err := json.Marshal(value)
-	if err != nil {
-		log.Println("ERROR: ", err)
-		debug.PrintStack()
-		return
+func (this *StateRepo) sendSensorValue(world *World, device *Device, service Service, value interface{}) (err error) {
+	if this.Config.Debug {
+		log.Println("DEBUG: send sensor data for", device.Id, service.Id, value)
 	}
-	msg[this.Config.ProtocolSegmentName] = string(msgStr)
-	err = this.Connector.HandleDeviceEventWithAuthToken(token, device.ExternalRef, service.ExternalRef, msg, platform_connector_lib.Sync)
-	if err != nil {
-		log.Println("ERROR: while sending sensor data", value, device.ExternalRef, service.ExternalRef, err)
+	name := getDeviceAdapterName(world, device)
+	adapter, ok := this.getAdapter(name)
+	if !ok {
+		log.Println("WARNING: unknown adapter", name, device.Id)
+		return errors.New("unknown adapter: " + name)
 	}
+	return adapter.SendSensorData(getAdapterTarget(world, device, service), value)
 }
 
 func (this *StateRepo) HandleCommand(externalDeviceRef string, externalServiceRef string, cmdMsg interface{}, responder func(respMsg interface{})) {
@@ -366,20 +435,57 @@ func (this *StateRepo) HandleCommand(externalDeviceRef string, externalServiceRe
 		log.Println("WARNING: no room for device found ", device.Id, " ", externalDeviceRef)
 		return
 	}
+	if name := getDeviceAdapterName(world, device); name != AdapterPlatform {
+		log.Println("WARNING: ignore platform command for device with adapter", name, device.Id, externalDeviceRef)
+		return
+	}
 
 	for _, service := range device.Services {
 		if service.ExternalRef == externalServiceRef {
-			err := run(service.Code, this.getJsCommandApi(world, room, device, cmdMsg, responder), this.Config.JsTimeout, world.mux)
-			if err != nil {
-				log.Println("ERROR: while handling command in jsvm", err, device.Name, service.Name)
-			}
+			this.runCommand(world, room, device, service, cmdMsg, responder)
 			return
 		}
 	}
 	log.Println("WARNING: no matching service for device found ", externalServiceRef)
 }
 
+// expects a read lock on the state repo
+func (this *StateRepo) runCommand(world *World, room *Room, device *Device, service Service, cmdMsg interface{}, responder func(respMsg interface{})) {
+	if !isDeviceReachableLocked(world, device) {
+		log.Println("WARNING: ignore command for unreachable device", device.Id, device.ExternalRef)
+		return
+	}
+	if this.isInFaultOutage(device, service.Id) {
+		if this.Config.Debug {
+			log.Println("DEBUG: ignore command while device is in fault outage", device.Id, service.Id)
+		}
+		return
+	}
+	recorder := this.newCommandRecorder(device, service, cmdMsg)
+	err := run(service.Code, this.getJsCommandApi(world, room, device, service, cmdMsg, recorder.wrap(responder)), this.Config.JsTimeout, world.mux)
+	recorder.finish(err)
+	if err != nil {
+		log.Println("ERROR: while handling command in jsvm", err, device.Name, service.Name)
+	}
+}
+
 func (this *StateRepo) RunService(serviceId string, cmdMsg interface{}) (resp interface{}, err error) {
+	responses, deviceId, start, err := this.runService(serviceId, cmdMsg)
+	if responses == nil {
+		return resp, err
+	}
+	if err != nil {
+		select {
+		case resp = <-responses:
+		default:
+		}
+		return resp, err
+	}
+	return this.awaitServiceResponse(responses, deviceId, serviceId, start), nil
+}
+
+// runs the service code; the returned channel receives the first response of the service
+func (this *StateRepo) runService(serviceId string, cmdMsg interface{}) (responses chan interface{}, deviceId string, start time.Time, err error) {
 	this.mux.RLock()
 	defer this.mux.RUnlock()
 	device, ok := this.serviceDeviceIndex[serviceId]
@@ -404,8 +510,51 @@ func (this *StateRepo) RunService(serviceId string, cmdMsg interface{}) (resp in
 		log.Println("WARNING: no room for device found ", device.Id, " ", serviceId)
 		return
 	}
-	err = run(service.Code, this.getJsCommandApi(world, room, device, cmdMsg, func(respMsg interface{}) {
-		resp = respMsg
-	}), this.Config.JsTimeout, world.mux)
-	return
+	if !isDeviceReachableLocked(world, device) {
+		return responses, device.Id, start, errors.New("device is offline or behind an offline hub")
+	}
+	if this.isInFaultOutage(device, service.Id) {
+		return responses, device.Id, start, errors.New("device is in fault outage")
+	}
+	//responses sent later (e.g. by moses.service.sendLater()) are received after the js run
+	responses = make(chan interface{}, 1)
+	start = time.Now()
+	recorder := this.newCommandRecorder(device, service, cmdMsg)
+	err = run(service.Code, this.getJsCommandApi(world, room, device, service, cmdMsg, recorder.wrap(func(respMsg interface{}) {
+		select {
+		case responses <- respMsg:
+		default:
+			log.Println("WARNING: RunService() ignores additional response", serviceId)
+		}
+	})), this.Config.JsTimeout, world.mux)
+	recorder.finish(err)
+	return responses, device.Id, start, err
+}
+
+// additional wait time for responses which are due, to let their timers deliver them
+const serviceResponseGracePeriod = 100 * time.Millisecond
+
+// returns the first response of the channel; waits for pending responses of the service created since start
+// deferred responses without timeout are awaited for at most the js timeout
+func (this *StateRepo) awaitServiceResponse(responses chan interface{}, deviceId string, serviceId string, start time.Time) (resp interface{}) {
+	select {
+	case resp = <-responses:
+		return resp
+	default:
+	}
+	due, pending, unbounded := this.pendingResponses.latestDue(deviceId, serviceId, start)
+	if !pending {
+		return nil
+	}
+	wait := time.Until(due) + serviceResponseGracePeriod
+	if unbounded && wait < this.Config.JsTimeout {
+		wait = this.Config.JsTimeout
+	}
+	select {
+	case resp = <-responses:
+		return resp
+	case <-time.After(wait):
+		log.Println("WARNING: RunService() no response until due time", serviceId)
+		return nil
+	}
 }
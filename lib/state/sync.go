@@ -0,0 +1,371 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+	"github.com/google/uuid"
+)
+
+// issues of a device in a sync report
+const (
+	SyncIssueUnlinked        = "unlinked"         //device has no external ref
+	SyncIssueMissing         = "missing"          //platform device does not exist
+	SyncIssueRenamed         = "renamed"          //platform device has another name
+	SyncIssueTypeChanged     = "type_changed"     //platform device has another device type
+	SyncIssueServicesChanged = "services_changed" //services reference unknown services of the device type
+)
+
+// actions to fix sync issues
+const (
+	SyncActionRename     = "rename"      //use the name of the platform device
+	SyncActionUpdateType = "update_type" //use the device type of the platform device and regenerate missing services
+	SyncActionRecreate   = "recreate"    //create a new platform device with the current name and device type
+)
+
+type SyncReport struct {
+	World   string             `json:"world"`
+	Devices []DeviceSyncReport `json:"devices"`
+	Ok      int                `json:"ok"`
+	Issues  int                `json:"issues"` //count of devices with issues
+	Errors  int                `json:"errors"` //count of devices which could not be compared
+}
+
+type DeviceSyncReport struct {
+	Room                 string   `json:"room"`
+	Device               string   `json:"device"`
+	Name                 string   `json:"name"`
+	ExternalRef          string   `json:"external_ref"`
+	ExternalTypeId       string   `json:"external_type_id"`
+	Issues               []string `json:"issues"`
+	Actions              []string `json:"actions"`
+	PlatformName         string   `json:"platform_name,omitempty"`
+	PlatformDeviceTypeId string   `json:"platform_device_type_id,omitempty"`
+	UnknownServiceRefs   []string `json:"unknown_service_refs,omitempty"`
+	PlatformLookupError  string   `json:"platform_lookup_error,omitempty"`
+}
+
+// {actions: [{device: "", action: "rename"}]}
+type SyncWorldRequest struct {
+	World   string       `json:"world"`
+	Actions []SyncAction `json:"actions"`
+}
+
+type SyncAction struct {
+	Device string `json:"device"`
+	Action string `json:"action"` // "rename" || "update_type" || "recreate"
+}
+
+// access to the platform devices and device types
+type syncSource interface {
+	GetDevice(id string) (device model.Device, exists bool, err error)
+	GetDeviceType(id string) (deviceType model.DeviceType, exists bool, err error)
+}
+
+type iotSyncSource struct {
+	repo  *StateRepo
+	token jwt.JwtImpersonate
+	types map[string]model.DeviceType
+}
+
+func (this *StateRepo) getSyncSource(token jwt.JwtImpersonate) syncSource {
+	return &iotSyncSource{repo: this, token: token, types: map[string]model.DeviceType{}}
+}
+
+func (this *iotSyncSource) GetDevice(id string) (device model.Device, exists bool, err error) {
+	return this.repo.Connector.LookupDevice(this.token, id)
+}
+
+func (this *iotSyncSource) GetDeviceType(id string) (deviceType model.DeviceType, exists bool, err error) {
+	if deviceType, ok := this.types[id]; ok {
+		return deviceType, true, nil
+	}
+	deviceType, exists, err = this.repo.Connector.LookupDeviceType(this.token, id)
+	if err != nil || !exists {
+		return deviceType, exists, err
+	}
+	this.types[id] = deviceType
+	return deviceType, true, nil
+}
+
+// compares all devices of the world with the platform; devices are sorted by room and id
+func getSyncReport(world WorldMsg, source syncSource) (result SyncReport) {
+	result = SyncReport{World: world.Id, Devices: []DeviceSyncReport{}}
+	for _, room := range world.Rooms {
+		for _, device := range room.Devices {
+			report := getDeviceSyncReport(device, source)
+			report.Room = room.Id
+			switch {
+			case report.PlatformLookupError != "":
+				result.Errors++
+			case len(report.Issues) > 0:
+				result.Issues++
+			default:
+				result.Ok++
+			}
+			result.Devices = append(result.Devices, report)
+		}
+	}
+	sort.Slice(result.Devices, func(i, j int) bool {
+		if result.Devices[i].Room != result.Devices[j].Room {
+			return result.Devices[i].Room < result.Devices[j].Room
+		}
+		return result.Devices[i].Device < result.Devices[j].Device
+	})
+	return result
+}
+
+func getDeviceSyncReport(device DeviceMsg, source syncSource) (result DeviceSyncReport) {
+	result = DeviceSyncReport{
+		Device:         device.Id,
+		Name:           device.Name,
+		ExternalRef:    device.ExternalRef,
+		ExternalTypeId: device.ExternalTypeId,
+		Issues:         []string{},
+		Actions:        []string{},
+	}
+	if device.ExternalRef == "" {
+		result.Issues = append(result.Issues, SyncIssueUnlinked)
+		if device.ExternalTypeId != "" {
+			result.Actions = append(result.Actions, SyncActionRecreate)
+		}
+		return result
+	}
+	external, exists, err := source.GetDevice(device.ExternalRef)
+	if err != nil {
+		result.PlatformLookupError = err.Error()
+		return result
+	}
+	if !exists {
+		result.Issues = append(result.Issues, SyncIssueMissing)
+		if device.ExternalTypeId != "" {
+			result.Actions = append(result.Actions, SyncActionRecreate)
+		}
+		return result
+	}
+	result.PlatformName = external.Name
+	result.PlatformDeviceTypeId = external.DeviceTypeId
+	if external.Name != device.Name {
+		result.Issues = append(result.Issues, SyncIssueRenamed)
+		result.Actions = append(result.Actions, SyncActionRename)
+	}
+	if external.DeviceTypeId != device.ExternalTypeId {
+		result.Issues = append(result.Issues, SyncIssueTypeChanged)
+	}
+	deviceType, exists, err := source.GetDeviceType(external.DeviceTypeId)
+	if err != nil {
+		result.PlatformLookupError = err.Error()
+		return result
+	}
+	if exists {
+		known := map[string]bool{}
+		for _, service := range deviceType.Services {
+			known[service.Id] = true
+		}
+		for _, service := range device.Services {
+			if service.ExternalRef != "" && !known[service.ExternalRef] {
+				result.UnknownServiceRefs = append(result.UnknownServiceRefs, service.ExternalRef)
+			}
+		}
+		sort.Strings(result.UnknownServiceRefs)
+		if len(result.UnknownServiceRefs) > 0 {
+			result.Issues = append(result.Issues, SyncIssueServicesChanged)
+		}
+	}
+	if external.DeviceTypeId != device.ExternalTypeId || len(result.UnknownServiceRefs) > 0 {
+		result.Actions = append(result.Actions, SyncActionUpdateType)
+	}
+	return result
+}
+
+func (this *StateRepo) GetWorldSyncReport(jwt jwt.Jwt, worldId string) (result SyncReport, access bool, exists bool, err error) {
+	world, access, exists, err := this.ReadWorld(jwt, worldId)
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	return getSyncReport(world, this.getSyncSource(jwt.Impersonate)), true, true, nil
+}
+
+// applies the actions to the devices of the world and returns the new sync report
+func (this *StateRepo) SyncWorld(jwt jwt.Jwt, msg SyncWorldRequest) (result SyncReport, access bool, exists bool, err error) {
+	world, access, exists, err := this.ReadWorld(jwt, msg.World)
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	source := this.getSyncSource(jwt.Impersonate)
+	createdExternalDevices := []string{}
+	defer func() {
+		if err != nil {
+			this.removeExternalDevices(jwt, createdExternalDevices)
+		}
+	}()
+	for _, action := range msg.Actions {
+		roomId, device, ok := findWorldDevice(world, action.Device)
+		if !ok {
+			return result, true, true, errors.New("unknown device in world: " + action.Device)
+		}
+		switch action.Action {
+		case SyncActionRename:
+			external, exists, err := source.GetDevice(device.ExternalRef)
+			if err != nil {
+				return result, true, true, err
+			}
+			if !exists {
+				return result, true, true, errors.New("platform device of " + device.Id + " does not exist")
+			}
+			device.Name = external.Name
+		case SyncActionUpdateType:
+			external, exists, err := source.GetDevice(device.ExternalRef)
+			if err != nil {
+				return result, true, true, err
+			}
+			if !exists {
+				return result, true, true, errors.New("platform device of " + device.Id + " does not exist")
+			}
+			device, err = this.updateDeviceServices(jwt, device, external.DeviceTypeId)
+			if err != nil {
+				return result, true, true, err
+			}
+		case SyncActionRecreate:
+			if device.ExternalTypeId == "" {
+				return result, true, true, errors.New("missing external_type_id of " + device.Id)
+			}
+			externalDevice, err := this.GenerateExternalDevice(jwt, CreateDeviceByTypeRequest{DeviceTypeId: device.ExternalTypeId, Name: device.Name})
+			if err != nil {
+				return result, true, true, err
+			}
+			createdExternalDevices = append(createdExternalDevices, externalDevice.Id)
+			device.ExternalRef = externalDevice.Id
+		default:
+			return result, true, true, errors.New("unknown sync action: " + action.Action)
+		}
+		world.Rooms[roomId].Devices[device.Id] = device
+	}
+	err = this.DevUpdateWorld(world)
+	if err != nil {
+		return result, true, true, err
+	}
+	this.refreshHubs(jwt, world.Id)
+	return getSyncReport(world, source), true, true, nil
+}
+
+func findWorldDevice(world WorldMsg, deviceId string) (roomId string, device DeviceMsg, ok bool) {
+	for roomId, room := range world.Rooms {
+		if device, ok := room.Devices[deviceId]; ok {
+			return roomId, device, true
+		}
+	}
+	return "", device, false
+}
+
+// sets the device type and replaces the services by the services of the device type
+// existing services with a matching external ref keep their id, code and sensor interval; states used by new services are added
+func (this *StateRepo) updateDeviceServices(jwt jwt.Jwt, device DeviceMsg, deviceTypeId string) (result DeviceMsg, err error) {
+	services, states, err := this.prepareServices(jwt, deviceTypeId)
+	if err != nil {
+		return device, err
+	}
+	existing := map[string]Service{}
+	for _, service := range device.Services {
+		if service.ExternalRef != "" {
+			existing[service.ExternalRef] = service
+		}
+	}
+	result = device
+	result.ExternalTypeId = deviceTypeId
+	result.States = map[string]interface{}{}
+	for key, value := range device.States {
+		result.States[key] = value
+	}
+	for key, value := range states {
+		if _, ok := result.States[key]; !ok {
+			result.States[key] = value
+		}
+	}
+	result.Services = map[string]Service{}
+	for _, service := range services {
+		if old, ok := existing[service.ExternalRef]; ok {
+			service = old
+		}
+		if service.Id == "" {
+			service.Id = uuid.NewString()
+		}
+		result.Services[service.Id] = service
+	}
+	return result, nil
+}
+
+// compares all worlds with the platform every sync_interval seconds and logs devices with issues
+// does nothing if sync_interval <= 0
+func (this *StateRepo) StartReconciliation(ctx context.Context) {
+	if this.Config.SyncInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(this.Config.SyncInterval) * time.Second)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				this.reconcile()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (this *StateRepo) reconcile() {
+	token, err := this.Connector.Access()
+	if err != nil {
+		log.Println("ERROR: reconcile()", err)
+		return
+	}
+	source := this.getSyncSource(token)
+	this.mux.RLock()
+	worldIds := []string{}
+	for id := range this.Worlds {
+		worldIds = append(worldIds, id)
+	}
+	this.mux.RUnlock()
+	for _, id := range worldIds {
+		world, exists, err := this.DevGetWorld(id)
+		if err != nil {
+			log.Println("ERROR: reconcile()", err)
+			continue
+		}
+		if !exists {
+			continue
+		}
+		report := getSyncReport(world, source)
+		for _, device := range report.Devices {
+			if len(device.Issues) > 0 {
+				log.Println("WARNING: device out of sync with platform:", "world="+world.Id, "device="+device.Device, "external_ref="+device.ExternalRef, device.Issues)
+			}
+			if device.PlatformLookupError != "" {
+				log.Println("ERROR: reconcile()", device.Device, device.PlatformLookupError)
+			}
+		}
+	}
+}
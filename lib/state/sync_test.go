@@ -0,0 +1,148 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/SENERGY-Platform/platform-connector-lib/model"
+)
+
+type testSyncSource struct {
+	devices map[string]model.Device
+	types   map[string]model.DeviceType
+}
+
+func (this testSyncSource) GetDevice(id string) (device model.Device, exists bool, err error) {
+	if id == "broken" {
+		return device, false, errors.New("lookup failed")
+	}
+	device, exists = this.devices[id]
+	return device, exists, nil
+}
+
+func (this testSyncSource) GetDeviceType(id string) (deviceType model.DeviceType, exists bool, err error) {
+	deviceType, exists = this.types[id]
+	return deviceType, exists, nil
+}
+
+func TestGetSyncReport(t *testing.T) {
+	source := testSyncSource{
+		devices: map[string]model.Device{
+			"ext-ok":      {Id: "ext-ok", Name: "lamp", DeviceTypeId: "dt1"},
+			"ext-renamed": {Id: "ext-renamed", Name: "new name", DeviceTypeId: "dt1"},
+			"ext-type":    {Id: "ext-type", Name: "heater", DeviceTypeId: "dt2"},
+		},
+		types: map[string]model.DeviceType{
+			"dt1": {Id: "dt1", Services: []model.Service{{Id: "s1"}}},
+			"dt2": {Id: "dt2", Services: []model.Service{{Id: "s2"}}},
+		},
+	}
+	world := WorldMsg{
+		Id: "w",
+		Rooms: map[string]RoomMsg{"r": {Id: "r", Devices: map[string]DeviceMsg{
+			"d1": {Id: "d1", Name: "lamp", ExternalRef: "ext-ok", ExternalTypeId: "dt1", Services: map[string]Service{"a": {Id: "a", ExternalRef: "s1"}}},
+			"d2": {Id: "d2", Name: "old name", ExternalRef: "ext-renamed", ExternalTypeId: "dt1"},
+			"d3": {Id: "d3", Name: "heater", ExternalRef: "ext-type", ExternalTypeId: "dt1", Services: map[string]Service{"b": {Id: "b", ExternalRef: "s1"}}},
+			"d4": {Id: "d4", Name: "gone", ExternalRef: "ext-deleted", ExternalTypeId: "dt1"},
+			"d5": {Id: "d5", Name: "local"},
+			"d6": {Id: "d6", Name: "unknown", ExternalRef: "broken"},
+		}}},
+	}
+	report := getSyncReport(world, source)
+	if report.World != "w" || report.Ok != 1 || report.Issues != 4 || report.Errors != 1 || len(report.Devices) != 6 {
+		t.Fatal(report)
+	}
+	expected := []struct {
+		issues  []string
+		actions []string
+	}{
+		{[]string{}, []string{}},
+		{[]string{SyncIssueRenamed}, []string{SyncActionRename}},
+		{[]string{SyncIssueTypeChanged, SyncIssueServicesChanged}, []string{SyncActionUpdateType}},
+		{[]string{SyncIssueMissing}, []string{SyncActionRecreate}},
+		{[]string{SyncIssueUnlinked}, []string{}},
+		{[]string{}, []string{}},
+	}
+	for i, device := range report.Devices {
+		if !reflect.DeepEqual(device.Issues, expected[i].issues) || !reflect.DeepEqual(device.Actions, expected[i].actions) {
+			t.Error(device.Device, device.Issues, device.Actions)
+		}
+	}
+	if report.Devices[1].PlatformName != "new name" {
+		t.Error(report.Devices[1])
+	}
+	if !reflect.DeepEqual(report.Devices[2].UnknownServiceRefs, []string{"s1"}) {
+		t.Error(report.Devices[2].UnknownServiceRefs)
+	}
+	if report.Devices[5].PlatformLookupError == "" {
+		t.Error(report.Devices[5])
+	}
+}
+
+func TestSyncWorldRemovesRecreatedDevicesOnError(t *testing.T) {
+	connector, err := NewLocalConnector(config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deviceType, err := connector.SetDeviceType(model.DeviceType{Name: "lamp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &StateRepo{
+		Connector:   connector,
+		Persistence: nopPersistence{},
+		StateLogger: nopLogger{},
+		Worlds: map[string]*World{"w": {Id: "w", Owner: "user", mux: &sync.Mutex{}, Rooms: map[string]*Room{
+			"r": {Id: "r", Devices: map[string]*Device{
+				"d1": {Id: "d1", Name: "lamp", ExternalTypeId: deviceType.Id, ExternalRef: "deleted", States: map[string]interface{}{}},
+				"d2": {Id: "d2", Name: "other", ExternalRef: "missing", States: map[string]interface{}{}},
+			}},
+		}}},
+	}
+	repo.Start()
+	defer repo.Stop()
+	user := jwt.Jwt{UserId: "user"}
+
+	_, _, _, err = repo.SyncWorld(user, SyncWorldRequest{World: "w", Actions: []SyncAction{
+		{Device: "d1", Action: SyncActionRecreate},
+		{Device: "d2", Action: SyncActionRename},
+	}})
+	if err == nil {
+		t.Fatal("expected error for missing platform device")
+	}
+	if len(connector.ListDevices()) != 0 {
+		t.Error("recreated platform device should be removed", connector.ListDevices())
+	}
+	if repo.Worlds["w"].Rooms["r"].Devices["d1"].ExternalRef != "deleted" {
+		t.Error("world should not change")
+	}
+
+	_, _, _, err = repo.SyncWorld(user, SyncWorldRequest{World: "w", Actions: []SyncAction{{Device: "d1", Action: SyncActionRecreate}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	devices := connector.ListDevices()
+	if len(devices) != 1 || repo.Worlds["w"].Rooms["r"].Devices["d1"].ExternalRef != devices[0].Id {
+		t.Error(devices)
+	}
+}
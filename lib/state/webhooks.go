@@ -0,0 +1,501 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/google/uuid"
+)
+
+// types of webhook events
+const (
+	WebhookEventStateChange = "state_change"
+	WebhookEventSensor      = "sensor_event"
+)
+
+const defaultWebhookRetries = 5
+const defaultWebhookRetryDelay = time.Second
+const webhookTimeout = 10 * time.Second
+const webhookDeadLetterLimit = 100
+const webhookQueueSize = 100 //events of a webhook waiting for delivery; further events are dead-lettered
+const maskedWebhookSecret = "********"
+
+// Webhook receives WebhookEvent messages of its world as http POST requests
+// empty filters match everything
+type Webhook struct {
+	Id      string   `json:"id" bson:"id"`
+	Url     string   `json:"url" bson:"url"`
+	Secret  string   `json:"secret,omitempty" bson:"secret"` //key of the hmac-sha256 signature in the X-Moses-Signature header; unsigned if empty
+	Events  []string `json:"events" bson:"events"`           //WebhookEventStateChange || WebhookEventSensor
+	Entity  string   `json:"entity" bson:"entity"`           //id of the world, room or device of the changed state or of the device of the sensor event
+	Key     string   `json:"key" bson:"key"`                 //state key
+	Service string   `json:"service" bson:"service"`         //sensor service id
+}
+
+type CreateWebhookRequest struct {
+	World   string   `json:"world"`
+	Url     string   `json:"url"`
+	Secret  string   `json:"secret"`
+	Events  []string `json:"events"`
+	Entity  string   `json:"entity"`
+	Key     string   `json:"key"`
+	Service string   `json:"service"`
+}
+
+// WebhookEvent is the body of webhook requests
+type WebhookEvent struct {
+	Id      string      `json:"id"`
+	Type    string      `json:"type"`
+	Time    time.Time   `json:"time"`
+	World   string      `json:"world"`
+	RefType string      `json:"ref_type"` // "world" || "room" || "device"
+	RefId   string      `json:"ref_id"`
+	Key     string      `json:"key,omitempty"`
+	Service string      `json:"service,omitempty"`
+	Value   interface{} `json:"value"`
+}
+
+// WebhookDeadLetter is an event which could not be delivered after all retries
+type WebhookDeadLetter struct {
+	Webhook  string          `json:"webhook"`
+	Url      string          `json:"url"`
+	Event    json.RawMessage `json:"event"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error"`
+	Time     time.Time       `json:"time"`
+}
+
+// bounded in memory list of dead letters per world id
+type webhookDeadLetterRegistry struct {
+	mux         sync.Mutex
+	deadLetters map[string][]WebhookDeadLetter
+}
+
+func (this *webhookDeadLetterRegistry) add(worldId string, deadLetter WebhookDeadLetter) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.deadLetters == nil {
+		this.deadLetters = map[string][]WebhookDeadLetter{}
+	}
+	deadLetters := append(this.deadLetters[worldId], deadLetter)
+	if len(deadLetters) > webhookDeadLetterLimit {
+		deadLetters = deadLetters[len(deadLetters)-webhookDeadLetterLimit:]
+	}
+	this.deadLetters[worldId] = deadLetters
+}
+
+// returns the dead letters of the world, newest first
+func (this *webhookDeadLetterRegistry) list(worldId string) (result []WebhookDeadLetter) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	deadLetters := this.deadLetters[worldId]
+	result = make([]WebhookDeadLetter, 0, len(deadLetters))
+	for i := len(deadLetters) - 1; i >= 0; i-- {
+		result = append(result, deadLetters[i])
+	}
+	return result
+}
+
+func (this *webhookDeadLetterRegistry) clear(worldId string) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	delete(this.deadLetters, worldId)
+}
+
+type webhookDelivery struct {
+	worldId string
+	webhook Webhook
+	event   WebhookEvent
+	payload []byte
+}
+
+// bounded queue of a webhook with a single worker; the worker ends when ctx is canceled
+type webhookQueue struct {
+	deliveries chan webhookDelivery
+	ctx        context.Context
+	cancel     context.CancelFunc
+}
+
+type webhookQueueRegistry struct {
+	mux    sync.Mutex
+	queues map[string]*webhookQueue
+}
+
+// returns the queue of the webhook; a new queue is passed to start
+func (this *webhookQueueRegistry) get(webhookId string, start func(queue *webhookQueue)) *webhookQueue {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.queues == nil {
+		this.queues = map[string]*webhookQueue{}
+	}
+	queue, ok := this.queues[webhookId]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		queue = &webhookQueue{deliveries: make(chan webhookDelivery, webhookQueueSize), ctx: ctx, cancel: cancel}
+		this.queues[webhookId] = queue
+		start(queue)
+	}
+	return queue
+}
+
+// stops the queues of webhooks which are not kept; queued events are dropped
+func (this *webhookQueueRegistry) stopIf(remove func(webhookId string) bool) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for id, queue := range this.queues {
+		if remove(id) {
+			queue.cancel()
+			delete(this.queues, id)
+		}
+	}
+}
+
+func (this *webhookQueueRegistry) stopAll() {
+	this.stopIf(func(string) bool {
+		return true
+	})
+}
+
+// stops the queues of deleted webhooks
+// expects the state repo to be locked
+func (this *StateRepo) stopRemovedWebhookQueues() {
+	known := map[string]bool{}
+	for _, world := range this.Worlds {
+		for id := range world.Webhooks {
+			known[id] = true
+		}
+	}
+	this.webhookQueues.stopIf(func(webhookId string) bool {
+		return !known[webhookId]
+	})
+}
+
+// returns a copy of the webhooks with masked secrets; used for read responses
+func maskWebhookSecrets(webhooks map[string]Webhook) map[string]Webhook {
+	if webhooks == nil {
+		return nil
+	}
+	result := map[string]Webhook{}
+	for id, webhook := range webhooks {
+		if webhook.Secret != "" {
+			webhook.Secret = maskedWebhookSecret
+		}
+		result[id] = webhook
+	}
+	return result
+}
+
+// replaces masked secrets (e.g. of a world which has been read and is written back) with the secrets of the current world
+// expects the state repo to be locked
+func (this *StateRepo) unmaskWebhookSecrets(world *World) {
+	current, ok := this.Worlds[world.Id]
+	for id, webhook := range world.Webhooks {
+		if webhook.Secret != maskedWebhookSecret {
+			continue
+		}
+		webhook.Secret = ""
+		if ok {
+			webhook.Secret = current.Webhooks[id].Secret
+		}
+		world.Webhooks[id] = webhook
+	}
+}
+
+func ValidateWebhook(webhook Webhook) error {
+	parsed, err := url.ParseRequestURI(webhook.Url)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return errors.New("expect http or https webhook url")
+	}
+	for _, event := range webhook.Events {
+		switch event {
+		case WebhookEventStateChange, WebhookEventSensor:
+		default:
+			return errors.New("unknown webhook event: " + event)
+		}
+	}
+	return nil
+}
+
+func (this Webhook) matches(event WebhookEvent) bool {
+	if len(this.Events) > 0 {
+		found := false
+		for _, eventType := range this.Events {
+			if eventType == event.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if this.Entity != "" && this.Entity != event.RefId {
+		return false
+	}
+	if this.Key != "" && this.Key != event.Key {
+		return false
+	}
+	if this.Service != "" && this.Service != event.Service {
+		return false
+	}
+	return true
+}
+
+// returns the hex encoded hmac-sha256 of the payload
+func signWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (this *StateRepo) getWebhookRetries() int {
+	if this.Config.WebhookRetries > 0 {
+		return int(this.Config.WebhookRetries)
+	}
+	return defaultWebhookRetries
+}
+
+func (this *StateRepo) getWebhookRetryDelay() time.Duration {
+	if this.Config.WebhookRetryDelay > 0 {
+		return time.Duration(this.Config.WebhookRetryDelay) * time.Millisecond
+	}
+	return defaultWebhookRetryDelay
+}
+
+// expects the world to be locked
+func (this *StateRepo) notifyStateChange(world *World, refType string, refId string, key string, value interface{}) {
+	if world == nil {
+		return
+	}
+	this.dispatchWebhooks(world, WebhookEvent{Type: WebhookEventStateChange, RefType: refType, RefId: refId, Key: key, Value: value})
+}
+
+// expects the world to be locked
+func (this *StateRepo) notifySensorEvent(world *World, device *Device, service Service, value interface{}) {
+	if world == nil {
+		return
+	}
+	this.dispatchWebhooks(world, WebhookEvent{Type: WebhookEventSensor, RefType: "device", RefId: device.Id, Service: service.Id, Value: value})
+}
+
+// the payload is encoded immediately; delivery is asynchronous and ordered per webhook
+// expects the world to be locked
+func (this *StateRepo) dispatchWebhooks(world *World, event WebhookEvent) {
+	if len(world.Webhooks) == 0 {
+		return
+	}
+	event.Id = uuid.NewString()
+	event.Time = this.now()
+	event.World = world.Id
+	var payload []byte
+	for _, webhook := range world.Webhooks {
+		if !webhook.matches(event) {
+			continue
+		}
+		if payload == nil {
+			var err error
+			payload, err = json.Marshal(event)
+			if err != nil {
+				log.Println("ERROR: unable to marshal webhook event", err)
+				return
+			}
+		}
+		this.enqueueWebhook(webhookDelivery{worldId: world.Id, webhook: webhook, event: event, payload: payload})
+	}
+}
+
+// never blocks; events of a webhook with a full queue are dead-lettered without delivery attempt
+func (this *StateRepo) enqueueWebhook(delivery webhookDelivery) {
+	queue := this.webhookQueues.get(delivery.webhook.Id, func(queue *webhookQueue) {
+		go this.runWebhookQueue(queue)
+	})
+	select {
+	case queue.deliveries <- delivery:
+	default:
+		log.Println("WARNING: webhook queue is full", delivery.webhook.Id, delivery.event.Id)
+		this.addWebhookDeadLetter(delivery, 0, errors.New("webhook queue is full"))
+	}
+}
+
+func (this *StateRepo) runWebhookQueue(queue *webhookQueue) {
+	for {
+		select {
+		case <-queue.ctx.Done():
+			return
+		case delivery := <-queue.deliveries:
+			this.deliverWebhook(queue.ctx, delivery)
+		}
+	}
+}
+
+// retries failed deliveries with exponential backoff and adds a dead letter after the last attempt
+// gives up without dead letter if ctx is canceled
+func (this *StateRepo) deliverWebhook(ctx context.Context, delivery webhookDelivery) {
+	retries := this.getWebhookRetries()
+	delay := this.getWebhookRetryDelay()
+	var err error
+	attempt := 0
+	for {
+		attempt++
+		err = sendWebhook(ctx, delivery.webhook, delivery.event, delivery.payload)
+		if err == nil {
+			return
+		}
+		if attempt > retries {
+			break
+		}
+		if this.Config.Debug {
+			log.Println("DEBUG: retry webhook", delivery.webhook.Id, "in", delay, err)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		delay = delay * 2
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	log.Println("WARNING: unable to deliver webhook event", delivery.webhook.Id, delivery.event.Id, err)
+	this.addWebhookDeadLetter(delivery, attempt, err)
+}
+
+func (this *StateRepo) addWebhookDeadLetter(delivery webhookDelivery, attempts int, err error) {
+	this.webhookDeadLetters.add(delivery.worldId, WebhookDeadLetter{
+		Webhook:  delivery.webhook.Id,
+		Url:      delivery.webhook.Url,
+		Event:    delivery.payload,
+		Attempts: attempts,
+		Error:    err.Error(),
+		Time:     this.now(),
+	})
+}
+
+func sendWebhook(ctx context.Context, webhook Webhook, event WebhookEvent, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.Url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Moses-Event", event.Type)
+	req.Header.Set("X-Moses-Delivery", event.Id)
+	if webhook.Secret != "" {
+		req.Header.Set("X-Moses-Signature", "sha256="+signWebhookPayload(webhook.Secret, payload))
+	}
+	client := &http.Client{Timeout: webhookTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return errors.New("unexpected webhook response status: " + strconv.Itoa(resp.StatusCode))
+	}
+	return nil
+}
+
+func (this *StateRepo) ReadWebhooks(jwt jwt.Jwt, worldId string) (result []Webhook, access bool, exists bool, err error) {
+	world, access, exists, err := this.ReadWorld(jwt, worldId)
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	result = []Webhook{}
+	for _, webhook := range world.Webhooks {
+		result = append(result, webhook)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id < result[j].Id
+	})
+	return result, true, true, nil
+}
+
+func (this *StateRepo) CreateWebhook(jwt jwt.Jwt, msg CreateWebhookRequest) (result Webhook, access bool, exists bool, err error) {
+	world, access, exists, err := this.ReadWorld(jwt, msg.World)
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	result = Webhook{
+		Id:      uuid.NewString(),
+		Url:     msg.Url,
+		Secret:  msg.Secret,
+		Events:  msg.Events,
+		Entity:  msg.Entity,
+		Key:     msg.Key,
+		Service: msg.Service,
+	}
+	err = ValidateWebhook(result)
+	if err != nil {
+		return result, true, true, err
+	}
+	if world.Webhooks == nil {
+		world.Webhooks = map[string]Webhook{}
+	}
+	world.Webhooks[result.Id] = result
+	err = this.DevUpdateWorld(world)
+	return result, true, true, err
+}
+
+func (this *StateRepo) DeleteWebhook(jwt jwt.Jwt, worldId string, webhookId string) (access bool, exists bool, err error) {
+	world, access, exists, err := this.ReadWorld(jwt, worldId)
+	if err != nil || !access || !exists {
+		return access, exists, err
+	}
+	if _, ok := world.Webhooks[webhookId]; !ok {
+		return true, false, nil
+	}
+	delete(world.Webhooks, webhookId)
+	err = this.DevUpdateWorld(world)
+	return true, true, err
+}
+
+func (this *StateRepo) ReadWebhookDeadLetters(jwt jwt.Jwt, worldId string) (result []WebhookDeadLetter, access bool, exists bool, err error) {
+	_, access, exists, err = this.ReadWorld(jwt, worldId)
+	if err != nil || !access || !exists {
+		return result, access, exists, err
+	}
+	return this.webhookDeadLetters.list(worldId), true, true, nil
+}
+
+func (this *StateRepo) ClearWebhookDeadLetters(jwt jwt.Jwt, worldId string) (access bool, exists bool, err error) {
+	_, access, exists, err = this.ReadWorld(jwt, worldId)
+	if err != nil || !access || !exists {
+		return access, exists, err
+	}
+	this.webhookDeadLetters.clear(worldId)
+	return true, true, nil
+}
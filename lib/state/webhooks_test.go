@@ -0,0 +1,299 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/SENERGY-Platform/moses/lib/jwt"
+	"github.com/globalsign/mgo"
+)
+
+type testWebhookRequest struct {
+	header http.Header
+	event  WebhookEvent
+	body   []byte
+}
+
+func newTestWebhookServer(t *testing.T, status int) (server *httptest.Server, requests chan testWebhookRequest) {
+	requests = make(chan testWebhookRequest, 10)
+	server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, err := io.ReadAll(request.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		event := WebhookEvent{}
+		err = json.Unmarshal(body, &event)
+		if err != nil {
+			t.Error(err)
+		}
+		requests <- testWebhookRequest{header: request.Header, event: event, body: body}
+		writer.WriteHeader(status)
+	}))
+	return server, requests
+}
+
+func TestWebhookStateChange(t *testing.T) {
+	server, requests := newTestWebhookServer(t, http.StatusOK)
+	defer server.Close()
+	repo := &StateRepo{Persistence: nopPersistence{}}
+	device := &Device{Id: "d", States: map[string]interface{}{}}
+	world := &World{Id: "w", mux: &sync.Mutex{}, Rooms: map[string]*Room{"r": {Id: "r", Devices: map[string]*Device{"d": device}}}, Webhooks: map[string]Webhook{
+		"h": {Id: "h", Url: server.URL, Secret: "secret", Events: []string{WebhookEventStateChange}, Entity: "d", Key: "on"},
+	}}
+	err := run(`moses.device.state.set("other", 1); moses.room.state.set("on", 1); moses.device.state.set("on", true);`, repo.getJsDeviceApi(world, world.Rooms["r"], device), 2*time.Second, world.mux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case request := <-requests:
+		if request.event.Type != WebhookEventStateChange || request.event.World != "w" || request.event.RefType != "device" || request.event.RefId != "d" || request.event.Key != "on" || request.event.Value != true {
+			t.Error(request.event)
+		}
+		if request.header.Get("X-Moses-Signature") != "sha256="+signWebhookPayload("secret", request.body) {
+			t.Error(request.header)
+		}
+		if request.header.Get("X-Moses-Delivery") != request.event.Id || request.header.Get("X-Moses-Event") != WebhookEventStateChange {
+			t.Error(request.header)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("missing webhook request")
+	}
+	select {
+	case request := <-requests:
+		t.Error("unexpected webhook request", request.event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWebhookSensorEvent(t *testing.T) {
+	server, requests := newTestWebhookServer(t, http.StatusOK)
+	defer server.Close()
+	repo := &StateRepo{Persistence: nopPersistence{}}
+	device := &Device{Id: "d", States: map[string]interface{}{}}
+	world := &World{Id: "w", mux: &sync.Mutex{}, Rooms: map[string]*Room{"r": {Id: "r", Devices: map[string]*Device{"d": device}}}, Webhooks: map[string]Webhook{
+		"h": {Id: "h", Url: server.URL, Service: "s"},
+	}}
+	repo.sendSensorData(world, device, Service{Id: "other"}, 1)
+	repo.sendSensorData(world, device, Service{Id: "s"}, 2)
+	select {
+	case request := <-requests:
+		if request.event.Type != WebhookEventSensor || request.event.RefId != "d" || request.event.Service != "s" || request.event.Value != float64(2) {
+			t.Error(request.event)
+		}
+		if request.header.Get("X-Moses-Signature") != "" {
+			t.Error(request.header)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("missing webhook request")
+	}
+}
+
+func TestWebhookDeadLetter(t *testing.T) {
+	server, requests := newTestWebhookServer(t, http.StatusInternalServerError)
+	defer server.Close()
+	repo := &StateRepo{Config: config.Config{WebhookRetries: 2, WebhookRetryDelay: 1}}
+	world := &World{Id: "w", Webhooks: map[string]Webhook{"h": {Id: "h", Url: server.URL}}}
+	repo.notifyStateChange(world, "world", "w", "temperature", 21)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-requests:
+		case <-time.After(time.Second):
+			t.Fatal("missing webhook attempt", i)
+		}
+	}
+	var deadLetters []WebhookDeadLetter
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(10 * time.Millisecond) {
+		deadLetters = repo.webhookDeadLetters.list("w")
+		if len(deadLetters) > 0 {
+			break
+		}
+	}
+	if len(deadLetters) != 1 || deadLetters[0].Webhook != "h" || deadLetters[0].Attempts != 3 || deadLetters[0].Error == "" {
+		t.Fatal(deadLetters)
+	}
+	event := WebhookEvent{}
+	err := json.Unmarshal(deadLetters[0].Event, &event)
+	if err != nil || event.Key != "temperature" {
+		t.Error(event, err)
+	}
+	repo.webhookDeadLetters.clear("w")
+	if len(repo.webhookDeadLetters.list("w")) != 0 {
+		t.Error(repo.webhookDeadLetters.list("w"))
+	}
+}
+
+func TestValidateWebhook(t *testing.T) {
+	if err := ValidateWebhook(Webhook{Url: "https://example.com/hook", Events: []string{WebhookEventSensor}}); err != nil {
+		t.Error(err)
+	}
+	if err := ValidateWebhook(Webhook{Url: "ftp://example.com/hook"}); err == nil {
+		t.Error("expected error for ftp url")
+	}
+	if err := ValidateWebhook(Webhook{Url: "https://example.com/hook", Events: []string{"unknown"}}); err == nil {
+		t.Error("expected error for unknown event")
+	}
+}
+
+func TestWebhookQueueLimit(t *testing.T) {
+	release := make(chan bool)
+	received := make(chan bool, webhookQueueSize+10)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		received <- true
+		select {
+		case <-release:
+		case <-request.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+	repo := &StateRepo{Config: config.Config{WebhookRetries: 1, WebhookRetryDelay: 1}}
+	world := &World{Id: "w", Webhooks: map[string]Webhook{"h": {Id: "h", Url: server.URL}}}
+
+	repo.notifyStateChange(world, "world", "w", "n", 0)
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("missing webhook request")
+	}
+	//the worker is blocked by the first delivery
+	for i := 1; i <= webhookQueueSize+2; i++ {
+		repo.notifyStateChange(world, "world", "w", "n", i)
+	}
+	deadLetters := repo.webhookDeadLetters.list("w")
+	if len(deadLetters) != 2 || deadLetters[0].Attempts != 0 || deadLetters[0].Error != "webhook queue is full" {
+		t.Fatal(deadLetters)
+	}
+
+	repo.webhookQueues.stopAll()
+	time.Sleep(100 * time.Millisecond)
+	if len(received) != 0 {
+		t.Error("stopped queue should not deliver queued events", len(received))
+	}
+	if len(repo.webhookDeadLetters.list("w")) != 2 {
+		t.Error("canceled deliveries should not be dead-lettered", repo.webhookDeadLetters.list("w"))
+	}
+}
+
+type webhookTestPersistence struct {
+	nopPersistence
+	mux       sync.Mutex
+	snapshots map[string]WorldSnapshot
+}
+
+func (this *webhookTestPersistence) PersistSnapshot(snapshot WorldSnapshot) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.snapshots[snapshot.Id] = snapshot
+	return nil
+}
+
+func (this *webhookTestPersistence) GetSnapshot(id string) (WorldSnapshot, error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	snapshot, ok := this.snapshots[id]
+	if !ok {
+		return snapshot, mgo.ErrNotFound
+	}
+	return snapshot, nil
+}
+
+func TestWebhookSecretMasking(t *testing.T) {
+	repo := &StateRepo{
+		Persistence: &webhookTestPersistence{snapshots: map[string]WorldSnapshot{}},
+		StateLogger: nopLogger{},
+		Worlds: map[string]*World{"w": {Id: "w", Owner: "user", mux: &sync.Mutex{}, Rooms: map[string]*Room{}, Webhooks: map[string]Webhook{
+			"h1": {Id: "h1", Url: "http://localhost/h1", Secret: "secret1"},
+		}}},
+	}
+	repo.Start()
+	defer repo.Shutdown()
+	user := jwt.Jwt{UserId: "user"}
+
+	world, _, _, err := repo.ReadWorld(user, "w")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if world.Webhooks["h1"].Secret != maskedWebhookSecret {
+		t.Error(world.Webhooks)
+	}
+	worlds, err := repo.ReadWorlds(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(worlds) != 1 || worlds[0].Webhooks["h1"].Secret != maskedWebhookSecret {
+		t.Error(worlds)
+	}
+	hooks, _, _, err := repo.ReadWebhooks(user, "w")
+	if err != nil || len(hooks) != 1 || hooks[0].Secret != maskedWebhookSecret {
+		t.Error(hooks, err)
+	}
+
+	//writing a read world back keeps the secret
+	_, _, _, err = repo.UpdateWorld(user, UpdateWorldRequest{Id: "w", Name: "renamed", States: map[string]interface{}{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Worlds["w"].Webhooks["h1"].Secret != "secret1" {
+		t.Error(repo.Worlds["w"].Webhooks)
+	}
+
+	snapshot, _, _, err := repo.CreateSnapshot(user, "w", CreateSnapshotRequest{Name: "before"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, _, _, err := repo.ReadSnapshot(user, "w", snapshot.Id)
+	if err != nil || read.Data.Webhooks["h1"].Secret != maskedWebhookSecret {
+		t.Error(read.Data, err)
+	}
+
+	//restore keeps the current webhooks
+	_, _, _, err = repo.CreateWebhook(user, CreateWebhookRequest{World: "w", Url: "http://localhost/h2", Secret: "secret2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = repo.DeleteWebhook(user, "w", "h1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, _, _, err := repo.RestoreSnapshot(user, "w", snapshot.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := repo.Worlds["w"].Webhooks
+	if len(current) != 1 || len(restored.Webhooks) != 1 {
+		t.Fatal(current, restored.Webhooks)
+	}
+	for _, webhook := range current {
+		if webhook.Url != "http://localhost/h2" || webhook.Secret != "secret2" {
+			t.Error(webhook)
+		}
+	}
+	for _, webhook := range restored.Webhooks {
+		if webhook.Secret != maskedWebhookSecret {
+			t.Error(webhook)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/SENERGY-Platform/moses/lib/config"
+	"github.com/SENERGY-Platform/moses/lib/state"
+	"github.com/SENERGY-Platform/moses/lib/test/server"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMqttAdapter(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	port, _, err := server.Mqtt(ctx, wg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	brokerUrl := "tcp://localhost:" + port
+
+	adapter, err := state.NewMqttAdapter(config.Config{MqttBrokerUrl: brokerUrl, MqttClientId: "moses-test", MqttQos: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer adapter.Close()
+
+	client := paho.NewClient(paho.NewClientOptions().AddBroker(brokerUrl).SetClientID("moses-test-client"))
+	token := client.Connect()
+	if !token.WaitTimeout(10*time.Second) || token.Error() != nil {
+		t.Fatal("unable to connect test client", token.Error())
+	}
+	defer client.Disconnect(250)
+
+	messages := make(chan paho.Message, 10)
+	token = client.Subscribe("moses/#", 1, func(client paho.Client, message paho.Message) {
+		messages <- message
+	})
+	if !token.WaitTimeout(10*time.Second) || token.Error() != nil {
+		t.Fatal("unable to subscribe", token.Error())
+	}
+	next := func(topic string) (payload interface{}) {
+		for {
+			select {
+			case message := <-messages:
+				if message.Topic() != topic {
+					continue
+				}
+				err := json.Unmarshal(message.Payload(), &payload)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return payload
+			case <-time.After(10 * time.Second):
+				t.Fatal("missing mqtt message on", topic)
+				return nil
+			}
+		}
+	}
+
+	target := state.AdapterTarget{World: "w", Device: "d", Service: "s"}
+
+	t.Run("sensor data", func(t *testing.T) {
+		err := adapter.SendSensorData(target, map[string]interface{}{"temperature": 21})
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload := next("moses/d/s/event")
+		if payload.(map[string]interface{})["temperature"] != float64(21) {
+			t.Error(payload)
+		}
+	})
+
+	t.Run("command", func(t *testing.T) {
+		err := adapter.SetCommandTargets([]state.AdapterTarget{target}, func(target state.AdapterTarget, cmdMsg interface{}, responder func(respMsg interface{})) {
+			responder(map[string]interface{}{"service": target.Service, "input": cmdMsg})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		token := client.Publish("moses/d/s/cmd", 1, false, `{"on":true}`)
+		if !token.WaitTimeout(10*time.Second) || token.Error() != nil {
+			t.Fatal("unable to publish command", token.Error())
+		}
+		payload := next("moses/d/s/resp").(map[string]interface{})
+		if payload["service"] != "s" || payload["input"].(map[string]interface{})["on"] != true {
+			t.Error(payload)
+		}
+	})
+
+	t.Run("removed command target", func(t *testing.T) {
+		err := adapter.SetCommandTargets([]state.AdapterTarget{}, func(target state.AdapterTarget, cmdMsg interface{}, responder func(respMsg interface{})) {
+			t.Error("unexpected command", target, cmdMsg)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		token := client.Publish("moses/d/s/cmd", 1, false, `{"on":false}`)
+		if !token.WaitTimeout(10*time.Second) || token.Error() != nil {
+			t.Fatal("unable to publish command", token.Error())
+		}
+		time.Sleep(time.Second)
+	})
+}
@@ -18,6 +18,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"github.com/SENERGY-Platform/moses/lib"
 	"github.com/SENERGY-Platform/moses/lib/config"
 	"log"
@@ -34,6 +35,22 @@ func main() {
 		log.Fatal("unable to load config: ", err)
 	}
 
+	if args := flag.Args(); len(args) > 0 && args[0] == "migrate" {
+		switch {
+		case len(args) == 2 && args[1] == "states":
+			err = lib.MigrateStates(config)
+		case len(args) == 3:
+			err = lib.Migrate(config, args[1], args[2])
+		default:
+			log.Fatal("usage: moses migrate <from> <to> || moses migrate states; e.g. moses migrate mongodb bolt")
+		}
+		if err != nil {
+			log.Fatal("unable to migrate: ", err)
+		}
+		log.Println("migration finished")
+		return
+	}
+
 	time.Sleep(5 * time.Second) //wait for routing tables in cluster
 
 	ctx, cancel := context.WithCancel(context.Background())